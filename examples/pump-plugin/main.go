@@ -0,0 +1,77 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// An iam-pump plugin which appends the analytics records to a file as JSON lines.
+//
+// Usage:
+//	go build -o /usr/local/bin/iam-pump-ndjson-plugin ./examples/pump-plugin
+//
+// and configure the pump in iam-pump.yaml:
+//
+//	pumps:
+//	  ndjson:
+//	    type: plugin
+//	    meta:
+//	      command: /usr/local/bin/iam-pump-ndjson-plugin
+//	      config:
+//	        path: /var/log/iam/analytics.ndjson
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/marmotedu/iam/pkg/pump/plugin"
+)
+
+type ndjsonPump struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Init opens the file of the path config, the records are written to stdout without it.
+func (p *ndjsonPump) Init(ctx context.Context, config map[string]interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	path, _ := config["path"].(string)
+	if path == "" {
+		p.file = os.Stdout
+
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	p.file = file
+
+	return nil
+}
+
+func (p *ndjsonPump) WriteData(ctx context.Context, records []plugin.Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	encoder := json.NewEncoder(p.file)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	if err := plugin.Serve(&ndjsonPump{}); err != nil {
+		log.Fatal(err)
+	}
+}
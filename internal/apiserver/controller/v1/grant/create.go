@@ -0,0 +1,125 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package grant
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	"github.com/marmotedu/component-base/pkg/core"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/pkg/grant"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// CreateGrantRequest defines the CreateGrantRequest data format.
+type CreateGrantRequest struct {
+	// Name of the policy which stores the grant.
+	// Required: true
+	Name string `json:"name" binding:"required"`
+
+	// The user who is granted the access.
+	// Required: true
+	Username string `json:"username" binding:"required,username"`
+
+	// The granted ladon policy.
+	// Required: true
+	Policy ladon.DefaultPolicy `json:"policy"`
+
+	// The grant does not take effect before this time.
+	// Required: false
+	NotBefore time.Time `json:"notBefore"`
+
+	// The grant expires at this time.
+	// Required: true
+	NotAfter time.Time `json:"notAfter" binding:"required"`
+
+	// Why the access is granted.
+	// Required: true
+	Justification string `json:"justification" binding:"required"`
+}
+
+// Create creates a time-bound access grant.
+// The grant is stored as a policy of the granted user, and the authenticated user is recorded as approver.
+// Only administrators can approve grants.
+func (g *GrantController) Create(c *gin.Context) {
+	log.L(c).Info("create grant function called.")
+
+	approver := c.GetString(middleware.UsernameKey)
+	user, err := g.srv.Users().Get(c, approver, metav1.GetOptions{})
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	if user.IsAdmin != 1 {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied,
+			"user %s is not a administrator, only administrators can approve grants", approver), nil)
+
+		return
+	}
+
+	var r CreateGrantRequest
+	if err := c.ShouldBindJSON(&r); err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+
+		return
+	}
+
+	if r.NotBefore.IsZero() {
+		r.NotBefore = time.Now()
+	}
+
+	if !r.NotAfter.After(r.NotBefore) {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "notAfter must be later than notBefore"), nil)
+
+		return
+	}
+
+	if approver == r.Username {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "a grant can not be approved by its grantee"), nil)
+
+		return
+	}
+
+	policy := v1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: r.Name},
+		Username:   r.Username,
+		Policy:     v1.AuthzPolicy{DefaultPolicy: r.Policy},
+	}
+
+	gr := &grant.Grant{
+		NotBefore:     r.NotBefore,
+		NotAfter:      r.NotAfter,
+		Approver:      approver,
+		Justification: r.Justification,
+	}
+	if err := gr.ApplyTo(&policy.Policy.DefaultPolicy); err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrEncodingJSON, err.Error()), nil)
+
+		return
+	}
+
+	if errs := policy.Validate(); len(errs) != 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, errs.ToAggregate().Error()), nil)
+
+		return
+	}
+
+	if err := g.srv.Policies().Create(c, &policy, metav1.CreateOptions{}); err != nil {
+		core.WriteResponse(c, err, nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, policy)
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package grant
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/ory/ladon"
+
+	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+	_ "github.com/marmotedu/iam/pkg/validator"
+)
+
+func TestGrantController_Create(t *testing.T) {
+	body, _ := json.Marshal(CreateGrantRequest{
+		Name:     "oncall",
+		Username: "peter",
+		Policy: ladon.DefaultPolicy{
+			Subjects:  []string{"users:peter"},
+			Resources: []string{"resources:articles:<.*>"},
+			Actions:   []string{"delete"},
+			Effect:    ladon.AllowAccess,
+		},
+		NotAfter:      time.Now().Add(time.Hour),
+		Justification: "incident 42",
+	})
+
+	tests := []struct {
+		name       string
+		caller     *v1.User
+		wantCreate bool
+		wantCode   int
+	}{
+		{
+			name:     "not a administrator",
+			caller:   &v1.User{IsAdmin: 0},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:       "administrator",
+			caller:     &v1.User{IsAdmin: 1},
+			wantCreate: true,
+			wantCode:   http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := srvv1.NewMockService(ctrl)
+			mockUserSrv := srvv1.NewMockUserSrv(ctrl)
+			mockUserSrv.EXPECT().Get(gomock.Any(), gomock.Eq("colin"), gomock.Any()).Return(tt.caller, nil)
+			mockService.EXPECT().Users().Return(mockUserSrv)
+			if tt.wantCreate {
+				mockPolicySrv := srvv1.NewMockPolicySrv(ctrl)
+				mockPolicySrv.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				mockService.EXPECT().Policies().Return(mockPolicySrv)
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest(http.MethodPost, "/v1/grants", bytes.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Set(middleware.UsernameKey, "colin")
+
+			g := &GrantController{srv: mockService}
+			g.Create(c)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("GrantController.Create() returned %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package grant
+
+import (
+	srvv1 "github.com/marmotedu/iam/internal/apiserver/service/v1"
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+// GrantController create a grant handler used to handle request for grant resource.
+type GrantController struct {
+	srv srvv1.Service
+}
+
+// NewGrantController creates a grant handler.
+func NewGrantController(store store.Factory) *GrantController {
+	return &GrantController{
+		srv: srvv1.NewService(store),
+	}
+}
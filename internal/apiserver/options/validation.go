@@ -17,6 +17,7 @@ func (o *Options) Validate() []error {
 	errs = append(errs, o.JwtOptions.Validate()...)
 	errs = append(errs, o.Log.Validate()...)
 	errs = append(errs, o.FeatureOptions.Validate()...)
+	errs = append(errs, o.TracingOptions.Validate()...)
 
 	return errs
 }
@@ -9,10 +9,11 @@ import (
 	"github.com/marmotedu/component-base/pkg/core"
 	"github.com/marmotedu/errors"
 
+	"github.com/marmotedu/iam/internal/apiserver/controller/v1/grant"
 	"github.com/marmotedu/iam/internal/apiserver/controller/v1/policy"
 	"github.com/marmotedu/iam/internal/apiserver/controller/v1/secret"
 	"github.com/marmotedu/iam/internal/apiserver/controller/v1/user"
-	"github.com/marmotedu/iam/internal/apiserver/store/mysql"
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/internal/pkg/middleware"
 	"github.com/marmotedu/iam/internal/pkg/middleware/auth"
@@ -43,7 +44,7 @@ func installController(g *gin.Engine) *gin.Engine {
 	})
 
 	// v1 handlers, requiring authentication
-	storeIns, _ := mysql.GetMySQLFactoryOr(nil)
+	storeIns := store.Client()
 	v1 := g.Group("/v1")
 	{
 		// user RESTful resource
@@ -77,6 +78,14 @@ func installController(g *gin.Engine) *gin.Engine {
 			policyv1.GET(":name", policyController.Get)
 		}
 
+		// grant RESTful resource
+		grantv1 := v1.Group("/grants", middleware.Publish())
+		{
+			grantController := grant.NewGrantController(storeIns)
+
+			grantv1.POST("", grantController.Create)
+		}
+
 		// secret RESTful resource
 		secretv1 := v1.Group("/secrets", middleware.Publish())
 		{
@@ -9,6 +9,7 @@ import (
 	"fmt"
 
 	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
@@ -17,8 +18,10 @@ import (
 	cachev1 "github.com/marmotedu/iam/internal/apiserver/controller/v1/cache"
 	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/apiserver/store/mysql"
+	"github.com/marmotedu/iam/internal/apiserver/store/tracing"
 	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
 	genericapiserver "github.com/marmotedu/iam/internal/pkg/server"
+	"github.com/marmotedu/iam/internal/pkg/server/healthz"
 	"github.com/marmotedu/iam/pkg/log"
 	"github.com/marmotedu/iam/pkg/shutdown"
 	"github.com/marmotedu/iam/pkg/shutdown/shutdownmanagers/posixsignal"
@@ -28,6 +31,7 @@ import (
 type apiServer struct {
 	gs               *shutdown.GracefulShutdown
 	redisOptions     *genericoptions.RedisOptions
+	tracingOptions   *genericoptions.TracingOptions
 	gRPCAPIServer    *grpcAPIServer
 	genericAPIServer *genericapiserver.GenericAPIServer
 }
@@ -71,6 +75,7 @@ func createAPIServer(cfg *config.Config) (*apiServer, error) {
 	server := &apiServer{
 		gs:               gs,
 		redisOptions:     cfg.RedisOptions,
+		tracingOptions:   cfg.TracingOptions,
 		genericAPIServer: genericServer,
 		gRPCAPIServer:    extraServer,
 	}
@@ -79,25 +84,45 @@ func createAPIServer(cfg *config.Config) (*apiServer, error) {
 }
 
 func (s *apiServer) PrepareRun() preparedAPIServer {
+	shutdownTracing, err := s.tracingOptions.Install("iam-apiserver")
+	if err != nil {
+		log.Fatalf("failed to install tracer provider: %s", err.Error())
+	}
+
 	initRouter(s.genericAPIServer.Engine)
 
 	s.initRedisStore()
 
+	s.genericAPIServer.AddReadyzChecks(healthz.RedisCheck())
+	if pinger, ok := s.mysqlStore().(healthz.Pinger); ok {
+		s.genericAPIServer.AddReadyzChecks(healthz.PingCheck("mysql", pinger))
+	}
+
 	s.gs.AddShutdownCallback(shutdown.ShutdownFunc(func(string) error {
-		mysqlStore, _ := mysql.GetMySQLFactoryOr(nil)
-		if mysqlStore != nil {
+		if mysqlStore := s.mysqlStore(); mysqlStore != nil {
 			_ = mysqlStore.Close()
 		}
 
 		s.gRPCAPIServer.Close()
 		s.genericAPIServer.Close()
 
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Warnf("failed to shutdown tracer provider: %s", err.Error())
+		}
+
 		return nil
 	}))
 
 	return preparedAPIServer{s}
 }
 
+// mysqlStore returns the underlying mysql store, not the traced one returned by store.Client().
+func (s *apiServer) mysqlStore() store.Factory {
+	mysqlStore, _ := mysql.GetMySQLFactoryOr(nil)
+
+	return mysqlStore
+}
+
 func (s preparedAPIServer) Run() error {
 	go s.gRPCAPIServer.Run()
 
@@ -128,11 +153,16 @@ func (c *completedExtraConfig) New() (*grpcAPIServer, error) {
 	if err != nil {
 		log.Fatalf("Failed to generate credentials %s", err.Error())
 	}
-	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(c.MaxMsgSize), grpc.Creds(creds)}
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(c.MaxMsgSize),
+		grpc.Creds(creds),
+		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
+	}
 	grpcServer := grpc.NewServer(opts...)
 
 	storeIns, _ := mysql.GetMySQLFactoryOr(c.mysqlOptions)
 	// storeIns, _ := etcd.GetEtcdFactoryOr(c.etcdOptions, nil)
+	storeIns = tracing.NewFactory(storeIns)
 	store.SetClient(storeIns)
 	cacheIns, err := cachev1.GetCacheInsOr(storeIns)
 	if err != nil {
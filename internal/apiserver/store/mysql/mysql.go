@@ -5,6 +5,7 @@
 package mysql
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -51,6 +52,16 @@ func (ds *datastore) Close() error {
 	return db.Close()
 }
 
+// Ping verifies the connection to the database is alive.
+func (ds *datastore) Ping(ctx context.Context) error {
+	db, err := ds.db.DB()
+	if err != nil {
+		return errors.Wrap(err, "get gorm db instance failed")
+	}
+
+	return db.PingContext(ctx)
+}
+
 var (
 	mysqlFactory store.Factory
 	once         sync.Once
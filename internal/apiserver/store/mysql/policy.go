@@ -27,12 +27,12 @@ func newPolicies(ds *datastore) *policies {
 
 // Create creates a new ladon policy.
 func (p *policies) Create(ctx context.Context, policy *v1.Policy, opts metav1.CreateOptions) error {
-	return p.db.Create(&policy).Error
+	return p.db.WithContext(ctx).Create(&policy).Error
 }
 
 // Update updates policy by the policy identifier.
 func (p *policies) Update(ctx context.Context, policy *v1.Policy, opts metav1.UpdateOptions) error {
-	return p.db.Save(policy).Error
+	return p.db.WithContext(ctx).Save(policy).Error
 }
 
 // Delete deletes the policy by the policy identifier.
@@ -41,7 +41,7 @@ func (p *policies) Delete(ctx context.Context, username, name string, opts metav
 		p.db = p.db.Unscoped()
 	}
 
-	err := p.db.Where("username = ? and name = ?", username, name).Delete(&v1.Policy{}).Error
+	err := p.db.WithContext(ctx).Where("username = ? and name = ?", username, name).Delete(&v1.Policy{}).Error
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return errors.WithCode(code.ErrDatabase, err.Error())
 	}
@@ -55,7 +55,7 @@ func (p *policies) DeleteByUser(ctx context.Context, username string, opts metav
 		p.db = p.db.Unscoped()
 	}
 
-	return p.db.Where("username = ?", username).Delete(&v1.Policy{}).Error
+	return p.db.WithContext(ctx).Where("username = ?", username).Delete(&v1.Policy{}).Error
 }
 
 // DeleteCollection batch deletes policies by policies ids.
@@ -69,7 +69,7 @@ func (p *policies) DeleteCollection(
 		p.db = p.db.Unscoped()
 	}
 
-	return p.db.Where("username = ? and name in (?)", username, names).Delete(&v1.Policy{}).Error
+	return p.db.WithContext(ctx).Where("username = ? and name in (?)", username, names).Delete(&v1.Policy{}).Error
 }
 
 // DeleteCollectionByUser batch deletes policies usernames.
@@ -78,13 +78,13 @@ func (p *policies) DeleteCollectionByUser(ctx context.Context, usernames []strin
 		p.db = p.db.Unscoped()
 	}
 
-	return p.db.Where("username in (?)", usernames).Delete(&v1.Policy{}).Error
+	return p.db.WithContext(ctx).Where("username in (?)", usernames).Delete(&v1.Policy{}).Error
 }
 
 // Get return policy by the policy identifier.
 func (p *policies) Get(ctx context.Context, username, name string, opts metav1.GetOptions) (*v1.Policy, error) {
 	policy := &v1.Policy{}
-	err := p.db.Where("username = ? and name = ?", username, name).First(&policy).Error
+	err := p.db.WithContext(ctx).Where("username = ? and name = ?", username, name).First(&policy).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.WithCode(code.ErrPolicyNotFound, err.Error())
@@ -108,7 +108,7 @@ func (p *policies) List(ctx context.Context, username string, opts metav1.ListOp
 	selector, _ := fields.ParseSelector(opts.FieldSelector)
 	name, _ := selector.RequiresExactMatch("name")
 
-	d := p.db.Where("name like ?", "%"+name+"%").
+	d := p.db.WithContext(ctx).Where("name like ?", "%"+name+"%").
 		Offset(ol.Offset).
 		Limit(ol.Limit).
 		Order("id desc").
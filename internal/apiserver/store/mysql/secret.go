@@ -27,12 +27,12 @@ func newSecrets(ds *datastore) *secrets {
 
 // Create creates a new secret.
 func (s *secrets) Create(ctx context.Context, secret *v1.Secret, opts metav1.CreateOptions) error {
-	return s.db.Create(&secret).Error
+	return s.db.WithContext(ctx).Create(&secret).Error
 }
 
 // Update updates an secret information by the secret identifier.
 func (s *secrets) Update(ctx context.Context, secret *v1.Secret, opts metav1.UpdateOptions) error {
-	return s.db.Save(secret).Error
+	return s.db.WithContext(ctx).Save(secret).Error
 }
 
 // Delete deletes the secret by the secret identifier.
@@ -41,7 +41,7 @@ func (s *secrets) Delete(ctx context.Context, username, name string, opts metav1
 		s.db = s.db.Unscoped()
 	}
 
-	err := s.db.Where("username = ? and name = ?", username, name).Delete(&v1.Secret{}).Error
+	err := s.db.WithContext(ctx).Where("username = ? and name = ?", username, name).Delete(&v1.Secret{}).Error
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return errors.WithCode(code.ErrDatabase, err.Error())
 	}
@@ -60,13 +60,13 @@ func (s *secrets) DeleteCollection(
 		s.db = s.db.Unscoped()
 	}
 
-	return s.db.Where("username = ? and name in (?)", username, names).Delete(&v1.Secret{}).Error
+	return s.db.WithContext(ctx).Where("username = ? and name in (?)", username, names).Delete(&v1.Secret{}).Error
 }
 
 // Get return an secret by the secret identifier.
 func (s *secrets) Get(ctx context.Context, username, name string, opts metav1.GetOptions) (*v1.Secret, error) {
 	secret := &v1.Secret{}
-	err := s.db.Where("username = ? and name= ?", username, name).First(&secret).Error
+	err := s.db.WithContext(ctx).Where("username = ? and name= ?", username, name).First(&secret).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.WithCode(code.ErrSecretNotFound, err.Error())
@@ -90,7 +90,7 @@ func (s *secrets) List(ctx context.Context, username string, opts metav1.ListOpt
 	selector, _ := fields.ParseSelector(opts.FieldSelector)
 	name, _ := selector.RequiresExactMatch("name")
 
-	d := s.db.Where(" name like ?", "%"+name+"%").
+	d := s.db.WithContext(ctx).Where(" name like ?", "%"+name+"%").
 		Offset(ol.Offset).
 		Limit(ol.Limit).
 		Order("id desc").
@@ -27,12 +27,12 @@ func newUsers(ds *datastore) *users {
 
 // Create creates a new user account.
 func (u *users) Create(ctx context.Context, user *v1.User, opts metav1.CreateOptions) error {
-	return u.db.Create(&user).Error
+	return u.db.WithContext(ctx).Create(&user).Error
 }
 
 // Update updates an user account information.
 func (u *users) Update(ctx context.Context, user *v1.User, opts metav1.UpdateOptions) error {
-	return u.db.Save(user).Error
+	return u.db.WithContext(ctx).Save(user).Error
 }
 
 // Delete deletes the user by the user identifier.
@@ -47,7 +47,7 @@ func (u *users) Delete(ctx context.Context, username string, opts metav1.DeleteO
 		u.db = u.db.Unscoped()
 	}
 
-	err := u.db.Where("name = ?", username).Delete(&v1.User{}).Error
+	err := u.db.WithContext(ctx).Where("name = ?", username).Delete(&v1.User{}).Error
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return errors.WithCode(code.ErrDatabase, err.Error())
 	}
@@ -67,13 +67,13 @@ func (u *users) DeleteCollection(ctx context.Context, usernames []string, opts m
 		u.db = u.db.Unscoped()
 	}
 
-	return u.db.Where("name in (?)", usernames).Delete(&v1.User{}).Error
+	return u.db.WithContext(ctx).Where("name in (?)", usernames).Delete(&v1.User{}).Error
 }
 
 // Get return an user by the user identifier.
 func (u *users) Get(ctx context.Context, username string, opts metav1.GetOptions) (*v1.User, error) {
 	user := &v1.User{}
-	err := u.db.Where("name = ? and status = 1", username).First(&user).Error
+	err := u.db.WithContext(ctx).Where("name = ? and status = 1", username).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.WithCode(code.ErrUserNotFound, err.Error())
@@ -92,7 +92,7 @@ func (u *users) List(ctx context.Context, opts metav1.ListOptions) (*v1.UserList
 
 	selector, _ := fields.ParseSelector(opts.FieldSelector)
 	username, _ := selector.RequiresExactMatch("name")
-	d := u.db.Where("name like ? and status = 1", "%"+username+"%").
+	d := u.db.WithContext(ctx).Where("name like ? and status = 1", "%"+username+"%").
 		Offset(ol.Offset).
 		Limit(ol.Limit).
 		Order("id desc").
@@ -119,7 +119,7 @@ func (u *users) ListOptional(ctx context.Context, opts metav1.ListOptions) (*v1.
 		where.Name = username
 	}
 
-	d := u.db.Where(where).
+	d := u.db.WithContext(ctx).Where(where).
 		Not(whereNot).
 		Offset(ol.Offset).
 		Limit(ol.Limit).
@@ -0,0 +1,73 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"context"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	generictracing "github.com/marmotedu/iam/internal/pkg/tracing"
+)
+
+type policies struct {
+	store.PolicyStore
+}
+
+// Create traces PolicyStore.Create.
+func (s *policies) Create(ctx context.Context, policy *v1.Policy, opts metav1.CreateOptions) error {
+	ctx, span := generictracing.Start(ctx, "store.policies.Create")
+	err := s.PolicyStore.Create(ctx, policy, opts)
+	generictracing.End(span, err)
+
+	return err
+}
+
+// Update traces PolicyStore.Update.
+func (s *policies) Update(ctx context.Context, policy *v1.Policy, opts metav1.UpdateOptions) error {
+	ctx, span := generictracing.Start(ctx, "store.policies.Update")
+	err := s.PolicyStore.Update(ctx, policy, opts)
+	generictracing.End(span, err)
+
+	return err
+}
+
+// Delete traces PolicyStore.Delete.
+func (s *policies) Delete(ctx context.Context, username, name string, opts metav1.DeleteOptions) error {
+	ctx, span := generictracing.Start(ctx, "store.policies.Delete")
+	err := s.PolicyStore.Delete(ctx, username, name, opts)
+	generictracing.End(span, err)
+
+	return err
+}
+
+// DeleteCollection traces PolicyStore.DeleteCollection.
+func (s *policies) DeleteCollection(ctx context.Context, username string, names []string, opts metav1.DeleteOptions) error {
+	ctx, span := generictracing.Start(ctx, "store.policies.DeleteCollection")
+	err := s.PolicyStore.DeleteCollection(ctx, username, names, opts)
+	generictracing.End(span, err)
+
+	return err
+}
+
+// Get traces PolicyStore.Get.
+func (s *policies) Get(ctx context.Context, username, name string, opts metav1.GetOptions) (*v1.Policy, error) {
+	ctx, span := generictracing.Start(ctx, "store.policies.Get")
+	ret, err := s.PolicyStore.Get(ctx, username, name, opts)
+	generictracing.End(span, err)
+
+	return ret, err
+}
+
+// List traces PolicyStore.List.
+func (s *policies) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.PolicyList, error) {
+	ctx, span := generictracing.Start(ctx, "store.policies.List")
+	ret, err := s.PolicyStore.List(ctx, username, opts)
+	generictracing.End(span, err)
+
+	return ret, err
+}
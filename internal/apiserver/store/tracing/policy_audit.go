@@ -0,0 +1,25 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"context"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	generictracing "github.com/marmotedu/iam/internal/pkg/tracing"
+)
+
+type policyAudits struct {
+	store.PolicyAuditStore
+}
+
+// ClearOutdated traces PolicyAuditStore.ClearOutdated.
+func (s *policyAudits) ClearOutdated(ctx context.Context, maxReserveDays int) (int64, error) {
+	ctx, span := generictracing.Start(ctx, "store.policyAudits.ClearOutdated")
+	ret, err := s.PolicyAuditStore.ClearOutdated(ctx, maxReserveDays)
+	generictracing.End(span, err)
+
+	return ret, err
+}
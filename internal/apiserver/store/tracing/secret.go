@@ -0,0 +1,73 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"context"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	generictracing "github.com/marmotedu/iam/internal/pkg/tracing"
+)
+
+type secrets struct {
+	store.SecretStore
+}
+
+// Create traces SecretStore.Create.
+func (s *secrets) Create(ctx context.Context, secret *v1.Secret, opts metav1.CreateOptions) error {
+	ctx, span := generictracing.Start(ctx, "store.secrets.Create")
+	err := s.SecretStore.Create(ctx, secret, opts)
+	generictracing.End(span, err)
+
+	return err
+}
+
+// Update traces SecretStore.Update.
+func (s *secrets) Update(ctx context.Context, secret *v1.Secret, opts metav1.UpdateOptions) error {
+	ctx, span := generictracing.Start(ctx, "store.secrets.Update")
+	err := s.SecretStore.Update(ctx, secret, opts)
+	generictracing.End(span, err)
+
+	return err
+}
+
+// Delete traces SecretStore.Delete.
+func (s *secrets) Delete(ctx context.Context, username, secretID string, opts metav1.DeleteOptions) error {
+	ctx, span := generictracing.Start(ctx, "store.secrets.Delete")
+	err := s.SecretStore.Delete(ctx, username, secretID, opts)
+	generictracing.End(span, err)
+
+	return err
+}
+
+// DeleteCollection traces SecretStore.DeleteCollection.
+func (s *secrets) DeleteCollection(ctx context.Context, username string, secretIDs []string, opts metav1.DeleteOptions) error {
+	ctx, span := generictracing.Start(ctx, "store.secrets.DeleteCollection")
+	err := s.SecretStore.DeleteCollection(ctx, username, secretIDs, opts)
+	generictracing.End(span, err)
+
+	return err
+}
+
+// Get traces SecretStore.Get.
+func (s *secrets) Get(ctx context.Context, username, secretID string, opts metav1.GetOptions) (*v1.Secret, error) {
+	ctx, span := generictracing.Start(ctx, "store.secrets.Get")
+	ret, err := s.SecretStore.Get(ctx, username, secretID, opts)
+	generictracing.End(span, err)
+
+	return ret, err
+}
+
+// List traces SecretStore.List.
+func (s *secrets) List(ctx context.Context, username string, opts metav1.ListOptions) (*v1.SecretList, error) {
+	ctx, span := generictracing.Start(ctx, "store.secrets.List")
+	ret, err := s.SecretStore.List(ctx, username, opts)
+	generictracing.End(span, err)
+
+	return ret, err
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"github.com/marmotedu/iam/internal/apiserver/store"
+)
+
+type datastore struct {
+	store.Factory
+}
+
+// NewFactory returns a store.Factory which starts a child span around every
+// method of the stores returned by factory.
+func NewFactory(factory store.Factory) store.Factory {
+	return &datastore{factory}
+}
+
+func (ds *datastore) Users() store.UserStore {
+	return &users{ds.Factory.Users()}
+}
+
+func (ds *datastore) Secrets() store.SecretStore {
+	return &secrets{ds.Factory.Secrets()}
+}
+
+func (ds *datastore) Policies() store.PolicyStore {
+	return &policies{ds.Factory.Policies()}
+}
+
+func (ds *datastore) PolicyAudits() store.PolicyAuditStore {
+	return &policyAudits{ds.Factory.PolicyAudits()}
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"context"
+
+	v1 "github.com/marmotedu/api/apiserver/v1"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	"github.com/marmotedu/iam/internal/apiserver/store"
+	generictracing "github.com/marmotedu/iam/internal/pkg/tracing"
+)
+
+type users struct {
+	store.UserStore
+}
+
+// Create traces UserStore.Create.
+func (s *users) Create(ctx context.Context, user *v1.User, opts metav1.CreateOptions) error {
+	ctx, span := generictracing.Start(ctx, "store.users.Create")
+	err := s.UserStore.Create(ctx, user, opts)
+	generictracing.End(span, err)
+
+	return err
+}
+
+// Update traces UserStore.Update.
+func (s *users) Update(ctx context.Context, user *v1.User, opts metav1.UpdateOptions) error {
+	ctx, span := generictracing.Start(ctx, "store.users.Update")
+	err := s.UserStore.Update(ctx, user, opts)
+	generictracing.End(span, err)
+
+	return err
+}
+
+// Delete traces UserStore.Delete.
+func (s *users) Delete(ctx context.Context, username string, opts metav1.DeleteOptions) error {
+	ctx, span := generictracing.Start(ctx, "store.users.Delete")
+	err := s.UserStore.Delete(ctx, username, opts)
+	generictracing.End(span, err)
+
+	return err
+}
+
+// DeleteCollection traces UserStore.DeleteCollection.
+func (s *users) DeleteCollection(ctx context.Context, usernames []string, opts metav1.DeleteOptions) error {
+	ctx, span := generictracing.Start(ctx, "store.users.DeleteCollection")
+	err := s.UserStore.DeleteCollection(ctx, usernames, opts)
+	generictracing.End(span, err)
+
+	return err
+}
+
+// Get traces UserStore.Get.
+func (s *users) Get(ctx context.Context, username string, opts metav1.GetOptions) (*v1.User, error) {
+	ctx, span := generictracing.Start(ctx, "store.users.Get")
+	ret, err := s.UserStore.Get(ctx, username, opts)
+	generictracing.End(span, err)
+
+	return ret, err
+}
+
+// List traces UserStore.List.
+func (s *users) List(ctx context.Context, opts metav1.ListOptions) (*v1.UserList, error) {
+	ctx, span := generictracing.Start(ctx, "store.users.List")
+	ret, err := s.UserStore.List(ctx, opts)
+	generictracing.End(span, err)
+
+	return ret, err
+}
@@ -6,171 +6,86 @@
 package analytics
 
 import (
-	"sync"
+	"io"
 	"sync/atomic"
 	"time"
 
-	"github.com/vmihailenco/msgpack/v5"
-
+	genericanalytics "github.com/marmotedu/iam/pkg/analytics"
 	"github.com/marmotedu/iam/pkg/log"
 	"github.com/marmotedu/iam/pkg/storage"
 )
 
-const analyticsKeyName = "iam-system-analytics"
-
-const (
-	recordsBufferForcedFlushInterval = 1 * time.Second
-)
-
 // AnalyticsRecord encodes the details of a authorization request.
-type AnalyticsRecord struct {
-	TimeStamp  int64     `json:"timestamp"`
-	Username   string    `json:"username"`
-	Effect     string    `json:"effect"`
-	Conclusion string    `json:"conclusion"`
-	Request    string    `json:"request"`
-	Policies   string    `json:"policies"`
-	Deciders   string    `json:"deciders"`
-	ExpireAt   time.Time `json:"expireAt"   bson:"expireAt"`
-}
-
-var analytics *Analytics
+type AnalyticsRecord = genericanalytics.Record
 
-// SetExpiry set expiration time to a key.
-func (a *AnalyticsRecord) SetExpiry(expiresInSeconds int64) {
-	expiry := time.Duration(expiresInSeconds) * time.Second
-	if expiresInSeconds == 0 {
-		// Expiry is set to 100 years
-		expiry = 24 * 365 * 100 * time.Hour
-	}
-
-	t := time.Now()
-	t2 := t.Add(expiry)
-	a.ExpireAt = t2
-}
+// analytics holds the *Analytics created last, it is read by the metrics concurrently.
+var analytics atomic.Value
 
-// Analytics will record analytics data to a redis back end as defined in the Config object.
+// Analytics will record analytics data to a redis, file or kafka back end as defined in the Config object.
 type Analytics struct {
-	store                      storage.AnalyticsHandler
-	poolSize                   int
-	recordsChan                chan *AnalyticsRecord
-	workerBufferSize           uint64
-	recordsBufferFlushInterval uint64
-	shouldStop                 uint32
-	poolWg                     sync.WaitGroup
+	store    storage.AnalyticsHandler
+	recorder *genericanalytics.Recorder
 }
 
 // NewAnalytics returns a new analytics instance.
 func NewAnalytics(options *AnalyticsOptions, store storage.AnalyticsHandler) *Analytics {
-	ps := options.PoolSize
-	recordsBufferSize := options.RecordsBufferSize
-	workerBufferSize := recordsBufferSize / uint64(ps)
-	log.Debug("Analytics pool worker buffer size", log.Uint64("workerBufferSize", workerBufferSize))
-
-	recordsChan := make(chan *AnalyticsRecord, recordsBufferSize)
-
-	analytics = &Analytics{
-		store:                      store,
-		poolSize:                   ps,
-		recordsChan:                recordsChan,
-		workerBufferSize:           workerBufferSize,
-		recordsBufferFlushInterval: options.FlushInterval,
+	recorder := genericanalytics.NewRecorder(&genericanalytics.Options{
+		PoolSize:                options.PoolSize,
+		RecordsBufferSize:       options.RecordsBufferSize,
+		FlushInterval:           options.FlushInterval,
+		StorageExpirationTime:   options.StorageExpirationTime,
+		EnableDetailedRecording: options.EnableDetailedRecording,
+		OverflowPolicy:          options.OverflowPolicy,
+		SampleRate:              options.SampleRate,
+		UserSampleRates:         options.UserSampleRates,
+	}, store).WithDropObserver(func(reason string) {
+		droppedRecords.WithLabelValues(reason).Inc()
+	})
+
+	ins := &Analytics{
+		store:    store,
+		recorder: recorder,
 	}
+	analytics.Store(ins)
 
-	return analytics
+	return ins
 }
 
 // GetAnalytics returns the existed analytics instance.
 // Need to initialize `analytics` instance before calling GetAnalytics.
 func GetAnalytics() *Analytics {
-	return analytics
+	ins, _ := analytics.Load().(*Analytics)
+
+	return ins
 }
 
 // Start start the analytics service.
 func (r *Analytics) Start() {
 	r.store.Connect()
-
-	// start worker pool
-	atomic.SwapUint32(&r.shouldStop, 0)
-	for i := 0; i < r.poolSize; i++ {
-		r.poolWg.Add(1)
-		go r.recordWorker()
-	}
+	r.recorder.Start()
 }
 
 // Stop stop the analytics service.
 func (r *Analytics) Stop() {
-	// flag to stop sending records into channel
-	atomic.SwapUint32(&r.shouldStop, 1)
+	r.recorder.Stop()
 
-	// close channel to stop workers
-	close(r.recordsChan)
-
-	// wait for all workers to be done
-	r.poolWg.Wait()
+	// flush the records buffered by the store, e.g. the active spool segment
+	if closer, ok := r.store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Errorf("Error closing analytics store: %s", err.Error())
+		}
+	}
 }
 
 // RecordHit will store an AnalyticsRecord in Redis.
+// Allowed decisions may be sampled out, and records may be dropped when the buffer is full
+// depending on the overflow policy.
 func (r *Analytics) RecordHit(record *AnalyticsRecord) error {
-	// check if we should stop sending records 1st
-	if atomic.LoadUint32(&r.shouldStop) > 0 {
-		return nil
-	}
-
-	// just send record to channel consumed by pool of workers
-	// leave all data crunching and Redis I/O work for pool workers
-	r.recordsChan <- record
+	r.recorder.Record(record)
 
 	return nil
 }
 
-func (r *Analytics) recordWorker() {
-	defer r.poolWg.Done()
-
-	// this is buffer to send one pipelined command to redis
-	// use r.recordsBufferSize as cap to reduce slice re-allocations
-	recordsBuffer := make([][]byte, 0, r.workerBufferSize)
-
-	// read records from channel and process
-	lastSentTS := time.Now()
-	for {
-		var readyToSend bool
-		select {
-		case record, ok := <-r.recordsChan:
-			// check if channel was closed and it is time to exit from worker
-			if !ok {
-				// send what is left in buffer
-				r.store.AppendToSetPipelined(analyticsKeyName, recordsBuffer)
-
-				return
-			}
-
-			// we have new record - prepare it and add to buffer
-
-			if encoded, err := msgpack.Marshal(record); err != nil {
-				log.Errorf("Error encoding analytics data: %s", err.Error())
-			} else {
-				recordsBuffer = append(recordsBuffer, encoded)
-			}
-
-			// identify that buffer is ready to be sent
-			readyToSend = uint64(len(recordsBuffer)) == r.workerBufferSize
-
-		case <-time.After(time.Duration(r.recordsBufferFlushInterval) * time.Millisecond):
-			// nothing was received for that period of time
-			// anyways send whatever we have, don't hold data too long in buffer
-			readyToSend = true
-		}
-
-		// send data to Redis and reset buffer
-		if len(recordsBuffer) > 0 && (readyToSend || time.Since(lastSentTS) >= recordsBufferForcedFlushInterval) {
-			r.store.AppendToSetPipelined(analyticsKeyName, recordsBuffer)
-			recordsBuffer = recordsBuffer[:0]
-			lastSentTS = time.Now()
-		}
-	}
-}
-
 // DurationToMillisecond convert time duration type to float64.
 func DurationToMillisecond(d time.Duration) float64 {
 	return float64(d) / 1e6
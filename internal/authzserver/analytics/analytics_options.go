@@ -9,16 +9,45 @@ import (
 	"time"
 
 	"github.com/spf13/pflag"
+
+	genericanalytics "github.com/marmotedu/iam/pkg/analytics"
+)
+
+// Supported behaviors when the records buffer is full.
+const (
+	// OverflowBlock blocks the authorization request until there is room in the buffer.
+	OverflowBlock = genericanalytics.OverflowBlock
+	// OverflowDropNewest drops the record being recorded.
+	OverflowDropNewest = genericanalytics.OverflowDropNewest
+	// OverflowDropOldest drops the oldest buffered record to make room for the new one.
+	OverflowDropOldest = genericanalytics.OverflowDropOldest
+)
+
+// Supported analytics sinks.
+const (
+	// SinkRedis writes records to a redis list consumed by iam-pump.
+	SinkRedis = "redis"
+	// SinkFile appends records to a local spool directory consumed by iam-pump.
+	SinkFile = "file"
+	// SinkKafka produces records to a kafka topic consumed by iam-pump.
+	SinkKafka = "kafka"
 )
 
 // AnalyticsOptions contains configuration items related to analytics.
 type AnalyticsOptions struct {
-	PoolSize                int           `json:"pool-size"                 mapstructure:"pool-size"`
-	RecordsBufferSize       uint64        `json:"records-buffer-size"       mapstructure:"records-buffer-size"`
-	FlushInterval           uint64        `json:"flush-interval"            mapstructure:"flush-interval"`
-	StorageExpirationTime   time.Duration `json:"storage-expiration-time"   mapstructure:"storage-expiration-time"`
-	Enable                  bool          `json:"enable"                    mapstructure:"enable"`
-	EnableDetailedRecording bool          `json:"enable-detailed-recording" mapstructure:"enable-detailed-recording"`
+	PoolSize                int                `json:"pool-size"                 mapstructure:"pool-size"`
+	RecordsBufferSize       uint64             `json:"records-buffer-size"       mapstructure:"records-buffer-size"`
+	FlushInterval           uint64             `json:"flush-interval"            mapstructure:"flush-interval"`
+	StorageExpirationTime   time.Duration      `json:"storage-expiration-time"   mapstructure:"storage-expiration-time"`
+	Enable                  bool               `json:"enable"                    mapstructure:"enable"`
+	EnableDetailedRecording bool               `json:"enable-detailed-recording" mapstructure:"enable-detailed-recording"`
+	OverflowPolicy          string             `json:"overflow-policy"           mapstructure:"overflow-policy"`
+	SampleRate              float64            `json:"sample-rate"               mapstructure:"sample-rate"`
+	UserSampleRates         map[string]float64 `json:"user-sample-rates"         mapstructure:"user-sample-rates"`
+	Sink                    string             `json:"sink"                      mapstructure:"sink"`
+	SpoolDir                string             `json:"spool-dir"                 mapstructure:"spool-dir"`
+	KafkaBrokers            []string           `json:"kafka-brokers"             mapstructure:"kafka-brokers"`
+	KafkaTopic              string             `json:"kafka-topic"               mapstructure:"kafka-topic"`
 }
 
 // NewAnalyticsOptions creates a AnalyticsOptions object with default parameters.
@@ -30,6 +59,13 @@ func NewAnalyticsOptions() *AnalyticsOptions {
 		FlushInterval:           200,
 		EnableDetailedRecording: true,
 		StorageExpirationTime:   time.Duration(24) * time.Hour,
+		OverflowPolicy:          OverflowBlock,
+		SampleRate:              1,
+		UserSampleRates:         map[string]float64{},
+		Sink:                    SinkRedis,
+		SpoolDir:                "/var/lib/iam/analytics-spool",
+		KafkaBrokers:            []string{},
+		KafkaTopic:              "iam-analytics",
 	}
 }
 
@@ -45,6 +81,37 @@ func (o *AnalyticsOptions) Validate() []error {
 		errors = append(errors, fmt.Errorf("--analytics.flush-interval %v must be between 1 and 1000", o.FlushInterval))
 	}
 
+	switch o.OverflowPolicy {
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+	default:
+		errors = append(errors, fmt.Errorf("--analytics.overflow-policy must be one of %s, %s and %s",
+			OverflowBlock, OverflowDropNewest, OverflowDropOldest))
+	}
+
+	switch o.Sink {
+	case SinkRedis:
+	case SinkFile:
+		if o.SpoolDir == "" {
+			errors = append(errors, fmt.Errorf("--analytics.spool-dir is required by the file sink"))
+		}
+	case SinkKafka:
+		if len(o.KafkaBrokers) == 0 || o.KafkaTopic == "" {
+			errors = append(errors, fmt.Errorf("--analytics.kafka-brokers and --analytics.kafka-topic are required by the kafka sink"))
+		}
+	default:
+		errors = append(errors, fmt.Errorf("--analytics.sink must be one of %s, %s and %s", SinkRedis, SinkFile, SinkKafka))
+	}
+
+	if o.SampleRate < 0 || o.SampleRate > 1 {
+		errors = append(errors, fmt.Errorf("--analytics.sample-rate %v must be between 0 and 1", o.SampleRate))
+	}
+
+	for username, rate := range o.UserSampleRates {
+		if rate < 0 || rate > 1 {
+			errors = append(errors, fmt.Errorf("analytics.user-sample-rates of %s %v must be between 0 and 1", username, rate))
+		}
+	}
+
 	return errors
 }
 
@@ -70,4 +137,25 @@ func (o *AnalyticsOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.DurationVar(&o.StorageExpirationTime, "analytics.storage-expiration-time", o.StorageExpirationTime, ""+
 		"Set to a value larger than the Pump's purge_delay. "+
 		"This allows the analytics data to exist long enough in Redis to be processed by the Pump.")
+
+	fs.StringVar(&o.OverflowPolicy, "analytics.overflow-policy", o.OverflowPolicy, ""+
+		"What to do with a new record when the records buffer is full, one of block, drop-newest and drop-oldest. "+
+		"block slows down authorization requests when redis is slow.")
+
+	fs.Float64Var(&o.SampleRate, "analytics.sample-rate", o.SampleRate, ""+
+		"The ratio of allowed decisions to record, denied decisions are always recorded. "+
+		"Can be overridden per user by analytics.user-sample-rates in the configuration file.")
+
+	fs.StringVar(&o.Sink, "analytics.sink", o.Sink, ""+
+		"Where to write analytics records, one of redis, file and kafka. "+
+		"iam-pump must read from the same place by --input.type.")
+
+	fs.StringVar(&o.SpoolDir, "analytics.spool-dir", o.SpoolDir, ""+
+		"The directory analytics records are appended to when the sink is file.")
+
+	fs.StringSliceVar(&o.KafkaBrokers, "analytics.kafka-brokers", o.KafkaBrokers, ""+
+		"The kafka brokers analytics records are produced to when the sink is kafka.")
+
+	fs.StringVar(&o.KafkaTopic, "analytics.kafka-topic", o.KafkaTopic, ""+
+		"The kafka topic analytics records are produced to when the sink is kafka.")
 }
@@ -0,0 +1,98 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ory/ladon"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	genericanalytics "github.com/marmotedu/iam/pkg/analytics"
+)
+
+// fakeHandler is an analytics handler which keeps the appended values in memory.
+type fakeHandler struct {
+	lock   sync.Mutex
+	values map[string][][]byte
+	closed bool
+}
+
+func (h *fakeHandler) Connect() bool { return true }
+
+func (h *fakeHandler) AppendToSetPipelined(key string, values [][]byte) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.values[key] = append(h.values[key], values...)
+}
+
+func (h *fakeHandler) GetAndDeleteSet(string) []interface{} { return nil }
+
+func (h *fakeHandler) SetExp(string, time.Duration) error { return nil }
+
+func (h *fakeHandler) GetExp(string) (int64, error) { return 0, nil }
+
+func (h *fakeHandler) Close() error {
+	h.closed = true
+
+	return nil
+}
+
+func TestAnalytics_StartStop(t *testing.T) {
+	opts := NewAnalyticsOptions()
+	opts.PoolSize = 1
+	opts.RecordsBufferSize = 10
+	opts.SampleRate = 0
+
+	store := &fakeHandler{values: map[string][][]byte{}}
+	r := NewAnalytics(opts, store)
+	if GetAnalytics() != r {
+		t.Fatal("GetAnalytics() does not return the new instance")
+	}
+	r.Start()
+
+	sampled := testutil.ToFloat64(droppedRecords.WithLabelValues(genericanalytics.DropReasonSampled))
+	_ = r.RecordHit(&AnalyticsRecord{Username: "colin", Effect: ladon.AllowAccess})
+	_ = r.RecordHit(&AnalyticsRecord{Username: "colin", Effect: ladon.DenyAccess})
+	r.Stop()
+
+	if got := len(store.values[genericanalytics.KeyName]); got != 1 {
+		t.Errorf("written records = %d, want 1", got)
+	}
+	if got := testutil.ToFloat64(droppedRecords.WithLabelValues(genericanalytics.DropReasonSampled)); got != sampled+1 {
+		t.Errorf("sampled out records = %v, want %v", got, sampled+1)
+	}
+	if !store.closed {
+		t.Error("store is not closed")
+	}
+}
+
+func TestQueuedRecords(t *testing.T) {
+	opts := NewAnalyticsOptions()
+	opts.PoolSize = 1
+	opts.RecordsBufferSize = 10
+
+	// the gauge is collected while the instance is replaced.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 100; i++ {
+			_ = testutil.ToFloat64(queuedRecords)
+		}
+	}()
+
+	r := NewAnalytics(opts, &fakeHandler{values: map[string][][]byte{}})
+	_ = r.RecordHit(&AnalyticsRecord{Username: "colin", Effect: ladon.DenyAccess})
+	wg.Wait()
+
+	if got := testutil.ToFloat64(queuedRecords); got != 1 {
+		t.Errorf("queued records = %v, want 1", got)
+	}
+}
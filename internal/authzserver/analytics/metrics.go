@@ -0,0 +1,28 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	queuedRecords = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "iam_authz_analytics_records_queued",
+		Help: "Number of analytics records waiting in the channel to be written to redis.",
+	}, func() float64 {
+		ins := GetAnalytics()
+		if ins == nil {
+			return 0
+		}
+
+		return float64(ins.recorder.Queued())
+	})
+	droppedRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "iam_authz_analytics_records_dropped_total",
+		Help: "Total number of analytics records which are not written to redis by reason (stopped, sampled, overflow, encoding).",
+	}, []string{"reason"})
+)
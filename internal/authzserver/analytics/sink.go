@@ -0,0 +1,25 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// NewSink returns the storage analytics records are written to according to options.Sink.
+// redisKeyPrefix is only used by the redis sink.
+func NewSink(options *AnalyticsOptions, redisKeyPrefix string) storage.AnalyticsHandler {
+	switch options.Sink {
+	case SinkFile:
+		return storage.NewSpool(options.SpoolDir)
+	case SinkKafka:
+		return storage.NewKafka(storage.KafkaConfig{
+			Brokers: options.KafkaBrokers,
+			Topic:   options.KafkaTopic,
+		})
+	default:
+		return &storage.RedisCluster{KeyPrefix: redisKeyPrefix}
+	}
+}
@@ -5,6 +5,8 @@
 package authorization
 
 import (
+	"time"
+
 	authzv1 "github.com/marmotedu/api/authz/v1"
 	"github.com/ory/ladon"
 
@@ -22,6 +24,7 @@ func NewAuthorizer(authorizationClient AuthorizationInterface) *Authorizer {
 	return &Authorizer{
 		warden: &ladon.Ladon{
 			Manager:     NewPolicyManager(authorizationClient),
+			Matcher:     CompiledMatcher{},
 			AuditLogger: NewAuditLogger(authorizationClient),
 		},
 	}
@@ -31,7 +34,12 @@ func NewAuthorizer(authorizationClient AuthorizationInterface) *Authorizer {
 func (a *Authorizer) Authorize(request *ladon.Request) *authzv1.Response {
 	log.Debug("authorize request", log.Any("request", request))
 
-	if err := a.warden.IsAllowed(request); err != nil {
+	start := time.Now()
+	err := a.warden.IsAllowed(request)
+	evaluationDuration.Observe(time.Since(start).Seconds())
+	decisions.WithLabelValues(effect(err == nil)).Inc()
+
+	if err != nil {
 		return &authzv1.Response{
 			Denied: true,
 			Reason: err.Error(),
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	authzv1 "github.com/marmotedu/api/authz/v1"
 	"github.com/marmotedu/component-base/pkg/json"
 	"github.com/ory/ladon"
 
@@ -22,6 +23,11 @@ type PolicyGetter interface {
 	GetPolicy(key string) ([]*ladon.DefaultPolicy, error)
 }
 
+// CompiledPolicyGetter defines function to get compiled policies for a given user.
+type CompiledPolicyGetter interface {
+	GetCompiledPolicy(key string) ([]*authorization.CompiledPolicy, error)
+}
+
 // Authorization implements authorization.AuthorizationInterface interface.
 type Authorization struct {
 	getter PolicyGetter
@@ -67,8 +73,28 @@ func (auth *Authorization) List(username string) ([]*ladon.DefaultPolicy, error)
 	return auth.getter.GetPolicy(username)
 }
 
+// ListCompiled returns all the compiled policies under the username.
+// Policies are compiled on the fly if the getter does not provide compiled policies.
+func (auth *Authorization) ListCompiled(username string) ([]*authorization.CompiledPolicy, error) {
+	if getter, ok := auth.getter.(CompiledPolicyGetter); ok {
+		return getter.GetCompiledPolicy(username)
+	}
+
+	policies, err := auth.getter.GetPolicy(username)
+	if err != nil {
+		return nil, err
+	}
+
+	return authorization.CompilePolicies(policies)
+}
+
 // LogRejectedAccessRequest write rejected subject access to redis.
 func (auth *Authorization) LogRejectedAccessRequest(r *ladon.Request, p ladon.Policies, d ladon.Policies) {
+	// analytics is not initialized if it is disabled.
+	if analytics.GetAnalytics() == nil {
+		return
+	}
+
 	var conclusion string
 	if len(d) > 1 {
 		allowed := joinPoliciesNames(d[0 : len(d)-1])
@@ -92,12 +118,16 @@ func (auth *Authorization) LogRejectedAccessRequest(r *ladon.Request, p ladon.Po
 		Deciders:   dstring,
 	}
 
-	record.SetExpiry(0)
 	_ = analytics.GetAnalytics().RecordHit(&record)
 }
 
 // LogGrantedAccessRequest write granted subject access to redis.
 func (auth *Authorization) LogGrantedAccessRequest(r *ladon.Request, p ladon.Policies, d ladon.Policies) {
+	// analytics is not initialized if it is disabled.
+	if analytics.GetAnalytics() == nil {
+		return
+	}
+
 	conclusion := fmt.Sprintf("policies %s allow access", joinPoliciesNames(d))
 	rstring, pstring, dstring := convertToString(r, p, d)
 	record := analytics.AnalyticsRecord{
@@ -110,7 +140,31 @@ func (auth *Authorization) LogGrantedAccessRequest(r *ladon.Request, p ladon.Pol
 		Deciders:   dstring,
 	}
 
-	record.SetExpiry(0)
+	_ = analytics.GetAnalytics().RecordHit(&record)
+}
+
+// LogCachedAccessRequest write subject access decided by the decision cache to redis.
+// The policies and deciders are not known for a cached decision, so they are left empty.
+func LogCachedAccessRequest(r *ladon.Request, rsp *authzv1.Response) {
+	// analytics is not initialized if it is disabled.
+	if analytics.GetAnalytics() == nil {
+		return
+	}
+
+	effect, conclusion := ladon.AllowAccess, "cached decision allows access"
+	if !rsp.Allowed {
+		effect, conclusion = ladon.DenyAccess, "cached decision denies access: "+rsp.Reason
+	}
+
+	rbytes, _ := json.Marshal(r)
+	record := analytics.AnalyticsRecord{
+		TimeStamp:  time.Now().Unix(),
+		Username:   r.Context["username"].(string),
+		Effect:     effect,
+		Conclusion: conclusion,
+		Request:    string(rbytes),
+	}
+
 	_ = analytics.GetAnalytics().RecordHit(&record)
 }
 
@@ -5,6 +5,7 @@
 package authorization
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -35,6 +36,7 @@ func TestNewAuthorizer(t *testing.T) {
 			want: &Authorizer{
 				warden: &ladon.Ladon{
 					Manager:     NewPolicyManager(mockAuthz),
+					Matcher:     CompiledMatcher{},
 					AuditLogger: NewAuditLogger(mockAuthz),
 				},
 			},
@@ -157,3 +159,129 @@ func TestAuthorizer_Authorize(t *testing.T) {
 		})
 	}
 }
+
+// compiledAuthorization provides compiled policies like the authz-server cache does.
+type compiledAuthorization struct {
+	*MockAuthorizationInterface
+	compiled []*CompiledPolicy
+}
+
+func (c *compiledAuthorization) ListCompiled(username string) ([]*CompiledPolicy, error) {
+	return c.compiled, nil
+}
+
+func TestAuthorizer_AuthorizeInvalidDenyPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockAuthz := NewMockAuthorizationInterface(ctrl)
+	mockAuthz.EXPECT().LogRejectedAccessRequest(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+	mockAuthz.EXPECT().LogGrantedAccessRequest(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+	compiled, err := CompilePolicies([]*ladon.DefaultPolicy{
+		{
+			ID:        "allow-all",
+			Subjects:  []string{"users:<.*>"},
+			Resources: []string{"resources:<.*>"},
+			Actions:   []string{"<.*>"},
+			Effect:    ladon.AllowAccess,
+		},
+		{
+			ID:        "deny-invalid",
+			Subjects:  []string{"users:peter"},
+			Resources: []string{"resources:articles:<[>"},
+			Actions:   []string{"delete"},
+			Effect:    ladon.DenyAccess,
+		},
+	})
+	if err == nil {
+		t.Fatal("CompilePolicies() error = nil, want error")
+	}
+	if len(compiled) != 2 {
+		t.Fatalf("CompilePolicies() returned %d policies, want the invalid policy kept", len(compiled))
+	}
+
+	a := NewAuthorizer(&compiledAuthorization{mockAuthz, compiled})
+	got := a.Authorize(&ladon.Request{
+		Subject:  "users:peter",
+		Action:   "delete",
+		Resource: "resources:articles:ladon-introduction",
+		Context:  ladon.Context{"username": "colin"},
+	})
+	if !got.Denied {
+		t.Errorf("Authorizer.Authorize() = %v, want denied", got)
+	}
+}
+
+func newBenchmarkPolicies(n int) []*ladon.DefaultPolicy {
+	policies := make([]*ladon.DefaultPolicy, 0, n)
+	for i := 0; i < n; i++ {
+		policies = append(policies, &ladon.DefaultPolicy{
+			ID:        fmt.Sprintf("policy-%d", i),
+			Subjects:  []string{fmt.Sprintf("users:<peter|ken|user-%d>", i)},
+			Resources: []string{fmt.Sprintf("resources:articles:<.*>:%d", i)},
+			Actions:   []string{fmt.Sprintf("<create|update|action-%d>", i)},
+			Effect:    ladon.AllowAccess,
+		})
+	}
+
+	return policies
+}
+
+func benchmarkAuthorize(b *testing.B, a *Authorizer, n int) {
+	b.Helper()
+
+	request := &ladon.Request{
+		Subject:  "users:peter",
+		Action:   "update",
+		Resource: fmt.Sprintf("resources:articles:ladon-introduction:%d", n-1),
+		Context:  ladon.Context{"username": "colin"},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if rsp := a.Authorize(request); !rsp.Allowed {
+			b.Fatalf("Authorizer.Authorize() = %v, want allowed", rsp)
+		}
+	}
+}
+
+func BenchmarkAuthorizer_Authorize(b *testing.B) {
+	for _, n := range []int{10, 100, 500} {
+		policies := newBenchmarkPolicies(n)
+
+		b.Run(fmt.Sprintf("regexp_matcher_%d_policies", n), func(b *testing.B) {
+			ctrl := gomock.NewController(b)
+			defer ctrl.Finish()
+
+			mockAuthz := NewMockAuthorizationInterface(ctrl)
+			mockAuthz.EXPECT().List(gomock.Any()).Return(policies, nil).AnyTimes()
+			mockAuthz.EXPECT().LogGrantedAccessRequest(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+			// this is how requests were evaluated before policies were compiled at reload time.
+			a := &Authorizer{
+				warden: &ladon.Ladon{
+					Manager:     NewPolicyManager(mockAuthz),
+					Matcher:     ladon.NewRegexpMatcher(512),
+					AuditLogger: NewAuditLogger(mockAuthz),
+				},
+			}
+			benchmarkAuthorize(b, a, n)
+		})
+
+		b.Run(fmt.Sprintf("compiled_matcher_%d_policies", n), func(b *testing.B) {
+			ctrl := gomock.NewController(b)
+			defer ctrl.Finish()
+
+			mockAuthz := NewMockAuthorizationInterface(ctrl)
+			mockAuthz.EXPECT().LogGrantedAccessRequest(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+			compiled, err := CompilePolicies(policies)
+			if err != nil {
+				b.Fatalf("CompilePolicies() error = %v", err)
+			}
+
+			benchmarkAuthorize(b, NewAuthorizer(&compiledAuthorization{mockAuthz, compiled}), n)
+		})
+	}
+}
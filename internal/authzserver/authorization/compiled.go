@@ -0,0 +1,109 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authorization
+
+import (
+	"strings"
+
+	"github.com/dlclark/regexp2"
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/compiler"
+
+	"github.com/marmotedu/iam/pkg/grant"
+)
+
+// CompiledPolicy is a ladon policy whose subject, action and resource patterns
+// are compiled once, so that evaluating a request does not compile any regular expression.
+type CompiledPolicy struct {
+	*ladon.DefaultPolicy
+
+	// Grant is the parsed time-bound grant of the policy, nil for ordinary policies.
+	Grant *grant.Grant
+
+	// patterns maps every pattern which contains a regular expression to its compiled form.
+	patterns map[string]*regexp2.Regexp
+}
+
+// CompilePolicy compiles all the patterns of the given policy.
+func CompilePolicy(policy *ladon.DefaultPolicy) (*CompiledPolicy, error) {
+	cp := &CompiledPolicy{
+		DefaultPolicy: policy,
+		Grant:         grant.FromPolicy(policy),
+		patterns:      make(map[string]*regexp2.Regexp),
+	}
+
+	for _, haystack := range [][]string{policy.Subjects, policy.Actions, policy.Resources} {
+		for _, pattern := range haystack {
+			if _, ok := cp.patterns[pattern]; ok || !strings.ContainsRune(pattern, rune(policy.GetStartDelimiter())) {
+				continue
+			}
+
+			reg, err := compiler.CompileRegex(pattern, policy.GetStartDelimiter(), policy.GetEndDelimiter())
+			if err != nil {
+				return nil, errors.Wrapf(err, "compile pattern %s of policy %s failed", pattern, policy.GetID())
+			}
+
+			cp.patterns[pattern] = reg
+		}
+	}
+
+	return cp, nil
+}
+
+// CompilePolicies compiles the given policies. Policies which can not be compiled are
+// reported by the returned error and kept uncompiled, so that matching them fails
+// like it does with ladon's default matcher, instead of a broken deny policy being dropped.
+func CompilePolicies(policies []*ladon.DefaultPolicy) ([]*CompiledPolicy, error) {
+	var errs []error
+
+	ret := make([]*CompiledPolicy, 0, len(policies))
+	for _, policy := range policies {
+		cp, err := CompilePolicy(policy)
+		if err != nil {
+			errs = append(errs, err)
+			cp = &CompiledPolicy{DefaultPolicy: policy, Grant: grant.FromPolicy(policy)}
+		}
+
+		ret = append(ret, cp)
+	}
+
+	return ret, errors.NewAggregate(errs)
+}
+
+// CompiledMatcher matches requests against compiled policies without compiling regular expressions.
+// Policies which are not compiled, or failed to compile, are delegated to ladon's default matcher.
+type CompiledMatcher struct{}
+
+// Matches a needle with an array of patterns and returns true if a match was found.
+func (CompiledMatcher) Matches(p ladon.Policy, haystack []string, needle string) (bool, error) {
+	cp, ok := p.(*CompiledPolicy)
+	if !ok || cp.patterns == nil {
+		return ladon.DefaultMatcher.Matches(p, haystack, needle)
+	}
+
+	for _, h := range haystack {
+		reg, ok := cp.patterns[h]
+		if !ok {
+			// the pattern does not contain a regular expression
+			if h == needle {
+				return true, nil
+			}
+
+			continue
+		}
+
+		matched, err := reg.MatchString(needle)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,251 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authorization
+
+import (
+	"sort"
+
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+)
+
+// Permission describes how a single policy applies to an inspected resource.
+type Permission struct {
+	Policy      string   `json:"policy"`
+	Username    string   `json:"username,omitempty"`
+	Effect      string   `json:"effect"`
+	Subjects    []string `json:"subjects"`
+	Actions     []string `json:"actions"`
+	Conditional bool     `json:"conditional"`
+	Conditions  []string `json:"conditions,omitempty"`
+}
+
+// EffectivePermissions describes what actions a user's policies grant or deny on a resource.
+// Actions are reported as they are written in the policies, so they may contain
+// ladon regular expressions. Deny always overrides allow at evaluation time.
+type EffectivePermissions struct {
+	Username             string       `json:"username"`
+	Subject              string       `json:"subject,omitempty"`
+	Resource             string       `json:"resource"`
+	Allowed              []string     `json:"allowed"`
+	Denied               []string     `json:"denied"`
+	ConditionallyAllowed []string     `json:"conditionallyAllowed"`
+	ConditionallyDenied  []string     `json:"conditionallyDenied"`
+	Permissions          []Permission `json:"permissions"`
+}
+
+// ResourceAccess describes which principals are allowed or denied to perform an action on a resource.
+type ResourceAccess struct {
+	Resource string       `json:"resource"`
+	Action   string       `json:"action"`
+	Allowed  []Permission `json:"allowed"`
+	Denied   []Permission `json:"denied"`
+}
+
+// Inspector answers reverse queries over a set of policies using the same
+// matcher ladon uses to evaluate requests.
+type Inspector struct {
+	matcher *ladon.RegexpMatcher
+}
+
+// NewInspector creates a policy inspector.
+func NewInspector() *Inspector {
+	return &Inspector{
+		matcher: ladon.DefaultMatcher,
+	}
+}
+
+// EffectivePermissions returns the actions the given policies allow or deny on resource.
+// If subject is not empty, only the policies which match the subject are considered.
+// Allowed actions which an unconditional deny overrides are not reported as allowed.
+func (i *Inspector) EffectivePermissions(
+	username, subject, resource string,
+	policies []*ladon.DefaultPolicy,
+) (*EffectivePermissions, error) {
+	ret := &EffectivePermissions{
+		Username:             username,
+		Subject:              subject,
+		Resource:             resource,
+		Allowed:              []string{},
+		Denied:               []string{},
+		ConditionallyAllowed: []string{},
+		ConditionallyDenied:  []string{},
+		Permissions:          []Permission{},
+	}
+
+	var denies []*ladon.DefaultPolicy
+	for _, policy := range policies {
+		matched, err := i.matches(policy, policy.GetResources(), resource)
+		if err != nil {
+			return nil, err
+		} else if !matched {
+			continue
+		}
+
+		if subject != "" {
+			if matched, err = i.matches(policy, policy.GetSubjects(), subject); err != nil {
+				return nil, err
+			} else if !matched {
+				continue
+			}
+		}
+
+		perm := newPermission("", policy)
+		ret.Permissions = append(ret.Permissions, perm)
+
+		switch {
+		case policy.AllowAccess() && !perm.Conditional:
+			ret.Allowed = append(ret.Allowed, perm.Actions...)
+		case policy.AllowAccess():
+			ret.ConditionallyAllowed = append(ret.ConditionallyAllowed, perm.Actions...)
+		case !perm.Conditional:
+			ret.Denied = append(ret.Denied, perm.Actions...)
+			denies = append(denies, policy)
+		default:
+			ret.ConditionallyDenied = append(ret.ConditionallyDenied, perm.Actions...)
+		}
+	}
+
+	var err error
+	if ret.Allowed, err = i.withoutDenied(ret.Allowed, denies); err != nil {
+		return nil, err
+	}
+	if ret.ConditionallyAllowed, err = i.withoutDenied(ret.ConditionallyAllowed, denies); err != nil {
+		return nil, err
+	}
+
+	ret.Allowed = uniqueStrings(ret.Allowed)
+	ret.Denied = uniqueStrings(ret.Denied)
+	ret.ConditionallyAllowed = uniqueStrings(ret.ConditionallyAllowed)
+	ret.ConditionallyDenied = uniqueStrings(ret.ConditionallyDenied)
+
+	return ret, nil
+}
+
+// ResourceAccess returns the principals which are allowed or denied to perform action on resource.
+// The given policies are grouped by the username which owns them.
+func (i *Inspector) ResourceAccess(
+	resource, action string,
+	policies map[string][]*ladon.DefaultPolicy,
+) (*ResourceAccess, error) {
+	ret := &ResourceAccess{
+		Resource: resource,
+		Action:   action,
+		Allowed:  []Permission{},
+		Denied:   []Permission{},
+	}
+
+	usernames := make([]string, 0, len(policies))
+	for username := range policies {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+
+	for _, username := range usernames {
+		for _, policy := range policies[username] {
+			matched, err := i.matches(policy, policy.GetResources(), resource)
+			if err != nil {
+				return nil, err
+			} else if !matched {
+				continue
+			}
+
+			if action != "" {
+				if matched, err = i.matches(policy, policy.GetActions(), action); err != nil {
+					return nil, err
+				} else if !matched {
+					continue
+				}
+			}
+
+			perm := newPermission(username, policy)
+			if policy.AllowAccess() {
+				ret.Allowed = append(ret.Allowed, perm)
+			} else {
+				ret.Denied = append(ret.Denied, perm)
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// withoutDenied removes the actions matched by the actions of the given deny policies.
+func (i *Inspector) withoutDenied(actions []string, denies []*ladon.DefaultPolicy) ([]string, error) {
+	ret := make([]string, 0, len(actions))
+	for _, action := range actions {
+		denied, err := i.denied(action, denies)
+		if err != nil {
+			return nil, err
+		}
+
+		if !denied {
+			ret = append(ret, action)
+		}
+	}
+
+	return ret, nil
+}
+
+// denied reports whether action, which may be a pattern itself, is the same as or is matched by
+// an action of the given deny policies.
+func (i *Inspector) denied(action string, denies []*ladon.DefaultPolicy) (bool, error) {
+	for _, policy := range denies {
+		for _, denied := range policy.GetActions() {
+			if denied == action {
+				return true, nil
+			}
+		}
+
+		matched, err := i.matches(policy, policy.GetActions(), action)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+
+	return false, nil
+}
+
+func (i *Inspector) matches(policy ladon.Policy, haystack []string, needle string) (bool, error) {
+	matched, err := i.matcher.Matches(policy, haystack, needle)
+	if err != nil {
+		return false, errors.Wrapf(err, "match policy %s failed", policy.GetID())
+	}
+
+	return matched, nil
+}
+
+func newPermission(username string, policy *ladon.DefaultPolicy) Permission {
+	conditions := make([]string, 0, len(policy.Conditions))
+	for key, condition := range policy.Conditions {
+		conditions = append(conditions, key+":"+condition.GetName())
+	}
+	sort.Strings(conditions)
+
+	return Permission{
+		Policy:      policy.GetID(),
+		Username:    username,
+		Effect:      policy.GetEffect(),
+		Subjects:    policy.GetSubjects(),
+		Actions:     policy.GetActions(),
+		Conditional: len(conditions) > 0,
+		Conditions:  conditions,
+	}
+}
+
+func uniqueStrings(items []string) []string {
+	seen := make(map[string]struct{}, len(items))
+	ret := make([]string, 0, len(items))
+	for _, item := range items {
+		if _, ok := seen[item]; ok {
+			continue
+		}
+
+		seen[item] = struct{}{}
+		ret = append(ret, item)
+	}
+
+	return ret
+}
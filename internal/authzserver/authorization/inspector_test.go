@@ -0,0 +1,163 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authorization
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ory/ladon"
+)
+
+var inspectorPolicies = []*ladon.DefaultPolicy{
+	{
+		ID:        "allow-articles",
+		Subjects:  []string{"users:<peter|ken>"},
+		Resources: []string{"resources:articles:<.*>"},
+		Actions:   []string{"delete", "<create|update>"},
+		Effect:    ladon.AllowAccess,
+	},
+	{
+		ID:         "deny-delete-from-outside",
+		Subjects:   []string{"users:ken"},
+		Resources:  []string{"resources:articles:<.*>"},
+		Actions:    []string{"delete"},
+		Effect:     ladon.DenyAccess,
+		Conditions: ladon.Conditions{"remoteIPAddress": &ladon.CIDRCondition{CIDR: "192.168.0.1/16"}},
+	},
+	{
+		ID:        "deny-update-drafts",
+		Subjects:  []string{"users:peter"},
+		Resources: []string{"resources:articles:drafts"},
+		Actions:   []string{"<create|update>"},
+		Effect:    ladon.DenyAccess,
+	},
+	{
+		ID:        "allow-printer",
+		Subjects:  []string{"users:maria"},
+		Resources: []string{"resources:printer"},
+		Actions:   []string{"print"},
+		Effect:    ladon.AllowAccess,
+	},
+}
+
+func TestInspector_EffectivePermissions(t *testing.T) {
+	tests := []struct {
+		name                 string
+		subject              string
+		resource             string
+		wantAllowed          []string
+		wantConditionallyDen []string
+		wantPolicies         int
+	}{
+		{
+			name:                 "all subjects",
+			resource:             "resources:articles:ladon",
+			wantAllowed:          []string{"delete", "<create|update>"},
+			wantConditionallyDen: []string{"delete"},
+			wantPolicies:         2,
+		},
+		{
+			name:                 "subject filtered",
+			subject:              "users:peter",
+			resource:             "resources:articles:ladon",
+			wantAllowed:          []string{"delete", "<create|update>"},
+			wantConditionallyDen: []string{},
+			wantPolicies:         1,
+		},
+		{
+			name:                 "unconditional deny overrides allow",
+			subject:              "users:peter",
+			resource:             "resources:articles:drafts",
+			wantAllowed:          []string{"delete"},
+			wantConditionallyDen: []string{},
+			wantPolicies:         2,
+		},
+		{
+			name:                 "no match",
+			resource:             "resources:unknown",
+			wantAllowed:          []string{},
+			wantConditionallyDen: []string{},
+			wantPolicies:         0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewInspector().EffectivePermissions("colin", tt.subject, tt.resource, inspectorPolicies)
+			if err != nil {
+				t.Fatalf("Inspector.EffectivePermissions() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Allowed, tt.wantAllowed) {
+				t.Errorf("Inspector.EffectivePermissions().Allowed = %v, want %v", got.Allowed, tt.wantAllowed)
+			}
+			if !reflect.DeepEqual(got.ConditionallyDenied, tt.wantConditionallyDen) {
+				t.Errorf("Inspector.EffectivePermissions().ConditionallyDenied = %v, want %v",
+					got.ConditionallyDenied, tt.wantConditionallyDen)
+			}
+			if len(got.Permissions) != tt.wantPolicies {
+				t.Errorf("Inspector.EffectivePermissions() got %d permissions, want %d", len(got.Permissions), tt.wantPolicies)
+			}
+		})
+	}
+}
+
+func TestInspector_ResourceAccess(t *testing.T) {
+	policies := map[string][]*ladon.DefaultPolicy{
+		"colin": inspectorPolicies[:2],
+		"admin": inspectorPolicies[3:],
+	}
+
+	tests := []struct {
+		name        string
+		resource    string
+		action      string
+		wantAllowed []string
+		wantDenied  []string
+	}{
+		{
+			name:        "delete article",
+			resource:    "resources:articles:ladon",
+			action:      "delete",
+			wantAllowed: []string{"allow-articles"},
+			wantDenied:  []string{"deny-delete-from-outside"},
+		},
+		{
+			name:        "update article",
+			resource:    "resources:articles:ladon",
+			action:      "update",
+			wantAllowed: []string{"allow-articles"},
+			wantDenied:  []string{},
+		},
+		{
+			name:        "any action",
+			resource:    "resources:printer",
+			wantAllowed: []string{"allow-printer"},
+			wantDenied:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewInspector().ResourceAccess(tt.resource, tt.action, policies)
+			if err != nil {
+				t.Fatalf("Inspector.ResourceAccess() error = %v", err)
+			}
+			if ids := permissionIDs(got.Allowed); !reflect.DeepEqual(ids, tt.wantAllowed) {
+				t.Errorf("Inspector.ResourceAccess().Allowed = %v, want %v", ids, tt.wantAllowed)
+			}
+			if ids := permissionIDs(got.Denied); !reflect.DeepEqual(ids, tt.wantDenied) {
+				t.Errorf("Inspector.ResourceAccess().Denied = %v, want %v", ids, tt.wantDenied)
+			}
+		})
+	}
+}
+
+func permissionIDs(perms []Permission) []string {
+	ids := []string{}
+	for _, perm := range perms {
+		ids = append(ids, perm.Policy)
+	}
+
+	return ids
+}
@@ -5,8 +5,12 @@
 package authorization
 
 import (
+	"time"
+
 	"github.com/marmotedu/errors"
 	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/pkg/grant"
 )
 
 // PolicyManager is a mysql implementation for Manager to store
@@ -58,6 +62,28 @@ func (m *PolicyManager) FindRequestCandidates(r *ladon.Request) (ladon.Policies,
 		username = user
 	}
 
+	now := time.Now()
+
+	if lister, ok := m.client.(CompiledPolicyLister); ok {
+		policies, err := lister.ListCompiled(username)
+		if err != nil {
+			return nil, errors.Wrap(err, "list policies failed")
+		}
+
+		ret := make([]ladon.Policy, 0, len(policies))
+		for _, policy := range policies {
+			// time-bound grants only take effect within their validity window.
+			if policy.Grant != nil && !policy.Grant.Active(now) {
+				continue
+			}
+
+			ret = append(ret, policy)
+		}
+		candidatePolicies.Observe(float64(len(ret)))
+
+		return ret, nil
+	}
+
 	policies, err := m.client.List(username)
 	if err != nil {
 		return nil, errors.Wrap(err, "list policies failed")
@@ -65,8 +91,14 @@ func (m *PolicyManager) FindRequestCandidates(r *ladon.Request) (ladon.Policies,
 
 	ret := make([]ladon.Policy, 0, len(policies))
 	for _, policy := range policies {
+		// time-bound grants only take effect within their validity window.
+		if !grant.IsActive(policy, now) {
+			continue
+		}
+
 		ret = append(ret, policy)
 	}
+	candidatePolicies.Observe(float64(len(ret)))
 
 	return ret, nil
 }
@@ -0,0 +1,42 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authorization
+
+import (
+	"github.com/ory/ladon"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	decisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "iam_authz_decisions_total",
+		Help: "Total number of authorization decisions by effect.",
+	}, []string{"effect"})
+	evaluationDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "iam_authz_evaluation_duration_seconds",
+		Help:    "Time spent evaluating an authorization request against the policies.",
+		Buckets: []float64{.00005, .0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1},
+	})
+	candidatePolicies = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "iam_authz_candidate_policies",
+		Help:    "Number of candidate policies evaluated for an authorization request.",
+		Buckets: []float64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000},
+	})
+)
+
+// ObserveDecision counts an authorization decision which is not made by Authorizer,
+// e.g. a decision served from the decision cache.
+func ObserveDecision(allowed bool) {
+	decisions.WithLabelValues(effect(allowed)).Inc()
+}
+
+func effect(allowed bool) string {
+	if allowed {
+		return ladon.AllowAccess
+	}
+
+	return ladon.DenyAccess
+}
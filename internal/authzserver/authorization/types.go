@@ -23,3 +23,9 @@ type AuthorizationInterface interface {
 	LogRejectedAccessRequest(request *ladon.Request, pool ladon.Policies, deciders ladon.Policies)
 	LogGrantedAccessRequest(request *ladon.Request, pool ladon.Policies, deciders ladon.Policies)
 }
+
+// CompiledPolicyLister can be implemented by an AuthorizationInterface to provide
+// policies which are compiled in advance.
+type CompiledPolicyLister interface {
+	ListCompiled(username string) ([]*CompiledPolicy, error)
+}
@@ -6,6 +6,8 @@
 package authorize
 
 import (
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"github.com/marmotedu/component-base/pkg/core"
 	"github.com/marmotedu/errors"
@@ -13,18 +15,33 @@ import (
 
 	"github.com/marmotedu/iam/internal/authzserver/authorization"
 	"github.com/marmotedu/iam/internal/authzserver/authorization/authorizer"
+	"github.com/marmotedu/iam/internal/authzserver/decision"
 	"github.com/marmotedu/iam/internal/pkg/code"
 )
 
+const (
+	// XDecisionCacheKey defines the response header which reports how the decision cache was used.
+	XDecisionCacheKey = "X-Decision-Cache"
+)
+
+// PolicyStore defines functions to get the cached policies and their reload generation.
+type PolicyStore interface {
+	authorizer.PolicyGetter
+	Generation() uint64
+}
+
 // AuthzController create a authorize handler used to handle authorize request.
 type AuthzController struct {
-	store authorizer.PolicyGetter
+	store     PolicyStore
+	decisions *decision.Cache
 }
 
 // NewAuthzController creates a authorize handler.
-func NewAuthzController(store authorizer.PolicyGetter) *AuthzController {
+// decisions is optional, authorization decisions will not be cached if it is nil.
+func NewAuthzController(store PolicyStore, decisions *decision.Cache) *AuthzController {
 	return &AuthzController{
-		store: store,
+		store:     store,
+		decisions: decisions,
 	}
 }
 
@@ -38,13 +55,51 @@ func (a *AuthzController) Authorize(c *gin.Context) {
 		return
 	}
 
-	auth := authorization.NewAuthorizer(authorizer.NewAuthorization(a.store))
 	if r.Context == nil {
 		r.Context = ladon.Context{}
 	}
 
-	r.Context["username"] = c.GetString("username")
-	rsp := auth.Authorize(&r)
+	username := c.GetString("username")
+	r.Context["username"] = username
+
+	if a.decisions == nil {
+		rsp := authorization.NewAuthorizer(authorizer.NewAuthorization(a.store)).Authorize(&r)
+		core.WriteResponse(c, nil, rsp)
+
+		return
+	}
+
+	// the generation must be read before evaluating, so that a decision made with
+	// stale policies is never cached under a newer generation.
+	generation := a.store.Generation()
+	if !bypassCache(c) {
+		if rsp, ok := a.decisions.Get(generation, username, &r); ok {
+			authorizer.LogCachedAccessRequest(&r, rsp)
+			authorization.ObserveDecision(rsp.Allowed)
+			c.Header(XDecisionCacheKey, "hit")
+			core.WriteResponse(c, nil, rsp)
+
+			return
+		}
+
+		c.Header(XDecisionCacheKey, "miss")
+	} else {
+		c.Header(XDecisionCacheKey, "bypass")
+	}
+
+	rsp := authorization.NewAuthorizer(authorizer.NewAuthorization(a.store)).Authorize(&r)
+	a.decisions.Set(generation, username, &r, rsp)
 
 	core.WriteResponse(c, nil, rsp)
 }
+
+// bypassCache returns true if the client asks for a fresh decision with `Cache-Control: no-cache`.
+func bypassCache(c *gin.Context) bool {
+	for _, directive := range strings.Split(c.GetHeader("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
+	}
+
+	return false
+}
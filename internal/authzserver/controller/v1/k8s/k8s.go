@@ -0,0 +1,24 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package k8s implements the kubernetes authorization webhook handlers.
+package k8s
+
+import (
+	"github.com/marmotedu/iam/internal/authzserver/authorization/authorizer"
+)
+
+// WebhookController create a kubernetes webhook handler used to handle SubjectAccessReview request.
+type WebhookController struct {
+	store  authorizer.PolicyGetter
+	mapper *Mapper
+}
+
+// NewWebhookController creates a kubernetes webhook handler.
+func NewWebhookController(store authorizer.PolicyGetter, opts *WebhookOptions) *WebhookController {
+	return &WebhookController{
+		store:  store,
+		mapper: NewMapper(opts),
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package k8s
+
+import (
+	"strings"
+
+	"github.com/ory/ladon"
+)
+
+// Mapper maps a SubjectAccessReview to ladon requests.
+type Mapper struct {
+	opts *WebhookOptions
+}
+
+// NewMapper creates a mapper with the given resource naming scheme.
+func NewMapper(opts *WebhookOptions) *Mapper {
+	return &Mapper{opts: opts}
+}
+
+// Requests returns one ladon request for the user and one for every group of the review.
+// The user request comes first.
+func (m *Mapper) Requests(spec *SubjectAccessReviewSpec) []*ladon.Request {
+	var resource, verb string
+
+	switch {
+	case spec.ResourceAttributes != nil:
+		attrs := spec.ResourceAttributes
+		verb = attrs.Verb
+		resource = strings.NewReplacer(
+			"{namespace}", attrs.Namespace,
+			"{apigroup}", attrs.Group,
+			"{version}", attrs.Version,
+			"{resource}", attrs.Resource,
+			"{subresource}", attrs.Subresource,
+			"{name}", attrs.Name,
+		).Replace(m.opts.Resource)
+	case spec.NonResourceAttributes != nil:
+		verb = spec.NonResourceAttributes.Verb
+		resource = strings.ReplaceAll(m.opts.NonResource, "{path}", spec.NonResourceAttributes.Path)
+	}
+
+	action := strings.ReplaceAll(m.opts.Action, "{verb}", verb)
+
+	subjects := make([]string, 0, len(spec.Groups)+1)
+	if spec.User != "" {
+		subjects = append(subjects, strings.ReplaceAll(m.opts.Subject, "{user}", spec.User))
+	}
+
+	for _, group := range spec.Groups {
+		subjects = append(subjects, strings.ReplaceAll(m.opts.Group, "{group}", group))
+	}
+
+	requests := make([]*ladon.Request, 0, len(subjects))
+	for _, subject := range subjects {
+		requests = append(requests, &ladon.Request{
+			Subject:  subject,
+			Action:   action,
+			Resource: resource,
+			Context: ladon.Context{
+				"k8sUser":   spec.User,
+				"k8sGroups": spec.Groups,
+			},
+		})
+	}
+
+	return requests
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package k8s
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// WebhookOptions contains configuration items related to the kubernetes authorization webhook.
+// Subject, Group, Resource, NonResource and Action are templates, see the Mapper type for placeholders.
+type WebhookOptions struct {
+	Subject     string `json:"subject"      mapstructure:"subject"`
+	Group       string `json:"group"        mapstructure:"group"`
+	Resource    string `json:"resource"     mapstructure:"resource"`
+	NonResource string `json:"non-resource" mapstructure:"non-resource"`
+	Action      string `json:"action"       mapstructure:"action"`
+}
+
+// NewWebhookOptions creates a WebhookOptions object with default parameters.
+func NewWebhookOptions() *WebhookOptions {
+	return &WebhookOptions{
+		Subject:     "users:{user}",
+		Group:       "groups:{group}",
+		Resource:    "k8s:{namespace}:{apigroup}:{resource}:{name}",
+		NonResource: "k8s:nonresource:{path}",
+		Action:      "{verb}",
+	}
+}
+
+// Validate is used to parse and validate the parameters entered by the user at
+// the command line when the program starts.
+func (o *WebhookOptions) Validate() []error {
+	return nil
+}
+
+// AddFlags adds flags related to the kubernetes authorization webhook for a specific authz server to the
+// specified FlagSet.
+func (o *WebhookOptions) AddFlags(fs *pflag.FlagSet) {
+	if fs == nil {
+		return
+	}
+
+	fs.StringVar(&o.Subject, "k8s-webhook.subject", o.Subject, ""+
+		"Template of the ladon subject for a kubernetes user, {user} is replaced by the user name.")
+
+	fs.StringVar(&o.Group, "k8s-webhook.group", o.Group, ""+
+		"Template of the ladon subject for a kubernetes group, {group} is replaced by the group name.")
+
+	fs.StringVar(&o.Resource, "k8s-webhook.resource", o.Resource, ""+
+		"Template of the ladon resource for a kubernetes resource request. Supported placeholders are "+
+		"{namespace}, {apigroup}, {version}, {resource}, {subresource} and {name}.")
+
+	fs.StringVar(&o.NonResource, "k8s-webhook.non-resource", o.NonResource, ""+
+		"Template of the ladon resource for a kubernetes non-resource request, {path} is replaced by the path.")
+
+	fs.StringVar(&o.Action, "k8s-webhook.action", o.Action, ""+
+		"Template of the ladon action, {verb} is replaced by the kubernetes verb.")
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package k8s
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/internal/authzserver/authorization"
+	"github.com/marmotedu/iam/internal/authzserver/authorization/authorizer"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+)
+
+// SubjectAccessReview evaluates a kubernetes SubjectAccessReview with the policies of the authenticated user.
+// The review is allowed if the user or any of its groups is allowed. A policy which forcefully denies the
+// user or any of its groups makes the review denied, otherwise kubernetes treats a not allowed review as
+// having no opinion.
+// The response is a SubjectAccessReview instead of the iam response format, as kubernetes requires.
+func (w *WebhookController) SubjectAccessReview(c *gin.Context) {
+	var review SubjectAccessReview
+	if err := c.ShouldBindJSON(&review); err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrBind, err.Error()), nil)
+
+		return
+	}
+
+	review.Status = w.review(c.GetString(middleware.UsernameKey), &review.Spec)
+	if review.APIVersion == "" {
+		review.APIVersion = APIVersion
+	}
+	review.Kind = Kind
+
+	c.JSON(http.StatusOK, review)
+}
+
+func (w *WebhookController) review(username string, spec *SubjectAccessReviewSpec) SubjectAccessReviewStatus {
+	requests := w.mapper.Requests(spec)
+	if len(requests) == 0 {
+		return SubjectAccessReviewStatus{EvaluationError: "no user or group specified"}
+	}
+
+	allowed := false
+	reasons := make([]string, 0, len(requests))
+	for _, r := range requests {
+		r.Context["username"] = username
+
+		rsp := authorization.NewAuthorizer(authorizer.NewAuthorization(w.store)).Authorize(r)
+		if rsp.Allowed {
+			allowed = true
+
+			continue
+		}
+
+		// like in a single ladon request, deny overrides all allows.
+		if strings.Contains(rsp.Reason, ladon.ErrRequestForcefullyDenied.Error()) {
+			return SubjectAccessReviewStatus{Denied: true, Reason: r.Subject + ": " + rsp.Reason}
+		}
+
+		reasons = append(reasons, r.Subject+": "+rsp.Reason)
+	}
+
+	if allowed {
+		return SubjectAccessReviewStatus{Allowed: true}
+	}
+
+	return SubjectAccessReviewStatus{Reason: strings.Join(reasons, "; ")}
+}
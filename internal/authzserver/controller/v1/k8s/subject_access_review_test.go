@@ -0,0 +1,136 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+)
+
+type fakePolicyGetter map[string][]*ladon.DefaultPolicy
+
+func (f fakePolicyGetter) GetPolicy(key string) ([]*ladon.DefaultPolicy, error) {
+	return f[key], nil
+}
+
+func TestMapper_Requests(t *testing.T) {
+	spec := &SubjectAccessReviewSpec{
+		User:   "jane",
+		Groups: []string{"developers"},
+		ResourceAttributes: &ResourceAttributes{
+			Namespace: "default",
+			Verb:      "get",
+			Group:     "apps",
+			Resource:  "deployments",
+			Name:      "nginx",
+		},
+	}
+
+	requests := NewMapper(NewWebhookOptions()).Requests(spec)
+	if len(requests) != 2 {
+		t.Fatalf("Mapper.Requests() got %d requests, want 2", len(requests))
+	}
+
+	if got, want := requests[0].Subject, "users:jane"; got != want {
+		t.Errorf("Mapper.Requests()[0].Subject = %v, want %v", got, want)
+	}
+	if got, want := requests[1].Subject, "groups:developers"; got != want {
+		t.Errorf("Mapper.Requests()[1].Subject = %v, want %v", got, want)
+	}
+	if got, want := requests[0].Resource, "k8s:default:apps:deployments:nginx"; got != want {
+		t.Errorf("Mapper.Requests()[0].Resource = %v, want %v", got, want)
+	}
+	if got, want := requests[0].Action, "get"; got != want {
+		t.Errorf("Mapper.Requests()[0].Action = %v, want %v", got, want)
+	}
+}
+
+func TestWebhookController_SubjectAccessReview(t *testing.T) {
+	store := fakePolicyGetter{
+		"kube-apiserver": {
+			{
+				ID:        "developers-read",
+				Subjects:  []string{"groups:developers"},
+				Resources: []string{"k8s:default:<.*>"},
+				Actions:   []string{"<get|list|watch>"},
+				Effect:    ladon.AllowAccess,
+			},
+			{
+				ID:        "no-secrets",
+				Subjects:  []string{"users:jane"},
+				Resources: []string{"k8s:default::secrets:<.*>"},
+				Actions:   []string{"<.*>"},
+				Effect:    ladon.DenyAccess,
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		attrs      *ResourceAttributes
+		wantStatus SubjectAccessReviewStatus
+	}{
+		{
+			name:       "allowed by group",
+			attrs:      &ResourceAttributes{Namespace: "default", Verb: "list", Resource: "pods"},
+			wantStatus: SubjectAccessReviewStatus{Allowed: true},
+		},
+		{
+			name:  "forcefully denied",
+			attrs: &ResourceAttributes{Namespace: "default", Verb: "get", Resource: "secrets", Name: "token"},
+			wantStatus: SubjectAccessReviewStatus{
+				Denied: true,
+				Reason: "users:jane: Request was forcefully denied",
+			},
+		},
+		{
+			name:  "no opinion",
+			attrs: &ResourceAttributes{Namespace: "default", Verb: "delete", Resource: "pods"},
+			wantStatus: SubjectAccessReviewStatus{
+				Reason: "users:jane: Request was denied by default; groups:developers: Request was denied by default",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(SubjectAccessReview{
+				APIVersion: APIVersion,
+				Kind:       Kind,
+				Spec: SubjectAccessReviewSpec{
+					User:               "jane",
+					Groups:             []string{"developers"},
+					ResourceAttributes: tt.attrs,
+				},
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest(http.MethodPost, "/v1/subjectaccessreviews", bytes.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Set(middleware.UsernameKey, "kube-apiserver")
+
+			NewWebhookController(store, NewWebhookOptions()).SubjectAccessReview(c)
+
+			var got SubjectAccessReview
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("unmarshal response failed: %v", err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("SubjectAccessReview() status = %+v, want %+v", got.Status, tt.wantStatus)
+			}
+			if got.Kind != Kind {
+				t.Errorf("SubjectAccessReview() kind = %v, want %v", got.Kind, Kind)
+			}
+		})
+	}
+}
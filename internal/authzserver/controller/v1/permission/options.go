@@ -0,0 +1,52 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package permission
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// PermissionOptions contains configuration items related to the permission inspection.
+type PermissionOptions struct {
+	// AdminUsers are the users whose secrets may inspect the permissions of the other users
+	// and the access to resources.
+	AdminUsers []string `json:"admin-users" mapstructure:"admin-users"`
+}
+
+// NewPermissionOptions creates a PermissionOptions object with default parameters.
+func NewPermissionOptions() *PermissionOptions {
+	return &PermissionOptions{
+		AdminUsers: []string{"admin"},
+	}
+}
+
+// Validate is used to parse and validate the parameters entered by the user at
+// the command line when the program starts.
+func (o *PermissionOptions) Validate() []error {
+	return nil
+}
+
+// AddFlags adds flags related to the permission inspection for a specific authz server to the
+// specified FlagSet.
+func (o *PermissionOptions) AddFlags(fs *pflag.FlagSet) {
+	if fs == nil {
+		return
+	}
+
+	fs.StringSliceVar(&o.AdminUsers, "permission.admin-users", o.AdminUsers, ""+
+		"Users allowed to inspect the effective permissions of other users and who can access a resource. "+
+		"The other users can only inspect their own effective permissions.")
+}
+
+// isAdmin reports whether username is one of the admin users.
+func (o *PermissionOptions) isAdmin(username string) bool {
+	for _, admin := range o.AdminUsers {
+		if admin == username {
+			return true
+		}
+	}
+
+	return false
+}
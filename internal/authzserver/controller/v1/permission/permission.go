@@ -0,0 +1,98 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package permission implements the handlers used to inspect effective permissions.
+package permission
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/marmotedu/component-base/pkg/core"
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/internal/authzserver/authorization"
+	"github.com/marmotedu/iam/internal/authzserver/authorization/authorizer"
+	"github.com/marmotedu/iam/internal/pkg/code"
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+)
+
+// PolicyLister defines functions to get the cached policies.
+type PolicyLister interface {
+	authorizer.PolicyGetter
+	ListPolicies() map[string][]*ladon.DefaultPolicy
+}
+
+// PermissionController create a permission handler used to answer reverse authorization queries.
+type PermissionController struct {
+	store     PolicyLister
+	inspector *authorization.Inspector
+	opts      *PermissionOptions
+}
+
+// NewPermissionController creates a permission handler.
+func NewPermissionController(store PolicyLister, opts *PermissionOptions) *PermissionController {
+	return &PermissionController{
+		store:     store,
+		inspector: authorization.NewInspector(),
+		opts:      opts,
+	}
+}
+
+// EffectivePermissions returns what actions a user's policies allow or deny on a resource.
+// Only the admin users can inspect the permissions of the other users.
+func (p *PermissionController) EffectivePermissions(c *gin.Context) {
+	username := c.Param("name")
+	if caller := c.GetString(middleware.UsernameKey); caller != username && !p.opts.isAdmin(caller) {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied,
+			"user %s is not allowed to inspect the permissions of user %s", caller, username), nil)
+
+		return
+	}
+
+	resource := c.Query("resource")
+	if resource == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "resource must be specified"), nil)
+
+		return
+	}
+
+	// a user without any policy is not an error, it simply can not do anything.
+	policies, _ := p.store.GetPolicy(username)
+
+	rsp, err := p.inspector.EffectivePermissions(username, c.Query("subject"), resource, policies)
+	if err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, err.Error()), nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, rsp)
+}
+
+// ResourceAccess returns who is allowed or denied to access a resource.
+// It exposes the policies of all the users, so only the admin users can call it.
+func (p *PermissionController) ResourceAccess(c *gin.Context) {
+	if caller := c.GetString(middleware.UsernameKey); !p.opts.isAdmin(caller) {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied,
+			"user %s is not allowed to inspect the access to resources", caller), nil)
+
+		return
+	}
+
+	resource := c.Query("resource")
+	if resource == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "resource must be specified"), nil)
+
+		return
+	}
+
+	rsp, err := p.inspector.ResourceAccess(resource, c.Query("action"), p.store.ListPolicies())
+	if err != nil {
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, err.Error()), nil)
+
+		return
+	}
+
+	core.WriteResponse(c, nil, rsp)
+}
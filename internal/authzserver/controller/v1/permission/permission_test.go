@@ -0,0 +1,103 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package permission
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/internal/pkg/middleware"
+)
+
+type fakePolicyLister map[string][]*ladon.DefaultPolicy
+
+func (f fakePolicyLister) GetPolicy(key string) ([]*ladon.DefaultPolicy, error) {
+	return f[key], nil
+}
+
+func (f fakePolicyLister) ListPolicies() map[string][]*ladon.DefaultPolicy {
+	return f
+}
+
+func TestPermissionController_Access(t *testing.T) {
+	store := fakePolicyLister{
+		"colin": {
+			{
+				ID:        "allow-articles",
+				Subjects:  []string{"users:colin"},
+				Resources: []string{"resources:articles:<.*>"},
+				Actions:   []string{"delete"},
+				Effect:    ladon.AllowAccess,
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		caller   string
+		path     string
+		handler  func(*PermissionController) gin.HandlerFunc
+		wantCode int
+	}{
+		{
+			name:     "own permissions",
+			caller:   "colin",
+			path:     "/v1/users/colin/effective-permissions?resource=resources:articles:ladon",
+			handler:  func(p *PermissionController) gin.HandlerFunc { return p.EffectivePermissions },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "permissions of another user",
+			caller:   "peter",
+			path:     "/v1/users/colin/effective-permissions?resource=resources:articles:ladon",
+			handler:  func(p *PermissionController) gin.HandlerFunc { return p.EffectivePermissions },
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "permissions of another user by admin",
+			caller:   "admin",
+			path:     "/v1/users/colin/effective-permissions?resource=resources:articles:ladon",
+			handler:  func(p *PermissionController) gin.HandlerFunc { return p.EffectivePermissions },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "resource access",
+			caller:   "colin",
+			path:     "/v1/resources/access?resource=resources:articles:ladon",
+			handler:  func(p *PermissionController) gin.HandlerFunc { return p.ResourceAccess },
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "resource access by admin",
+			caller:   "admin",
+			path:     "/v1/resources/access?resource=resources:articles:ladon",
+			handler:  func(p *PermissionController) gin.HandlerFunc { return p.ResourceAccess },
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			g := gin.New()
+			g.Use(func(c *gin.Context) { c.Set(middleware.UsernameKey, tt.caller) })
+
+			p := NewPermissionController(store, NewPermissionOptions())
+			g.GET("/v1/users/:name/effective-permissions", tt.handler(p))
+			g.GET("/v1/resources/access", tt.handler(p))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			g.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("%s returned %d, want %d: %s", tt.path, w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+}
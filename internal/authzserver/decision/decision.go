@@ -0,0 +1,94 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package decision implements a bounded cache for authorization decisions.
+package decision
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	authzv1 "github.com/marmotedu/api/authz/v1"
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/ory/ladon"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	cacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "iam_authz_decision_cache_hits_total",
+		Help: "Total number of authorization decisions served from the decision cache.",
+	})
+	cacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "iam_authz_decision_cache_misses_total",
+		Help: "Total number of authorization decisions not found in the decision cache.",
+	})
+)
+
+// Cache is a bounded cache of authorization decisions.
+// Every entry is bound to the reload generation of the policy cache, so a reload
+// makes all the previous decisions unreachable.
+type Cache struct {
+	cache *ristretto.Cache
+	ttl   time.Duration
+}
+
+// NewCache creates a decision cache with the given options.
+func NewCache(opts *CacheOptions) (*Cache, error) {
+	c, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: opts.MaxEntries * 10, // number of keys to track frequency of.
+		MaxCost:     opts.MaxEntries,      // every decision costs 1.
+		BufferItems: 64,                   // number of keys per Get buffer.
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		cache: c,
+		ttl:   opts.TTL,
+	}, nil
+}
+
+// Get returns the cached decision for the given request.
+func (c *Cache) Get(generation uint64, username string, r *ladon.Request) (*authzv1.Response, bool) {
+	value, ok := c.cache.Get(Key(generation, username, r))
+	if !ok {
+		cacheMisses.Inc()
+
+		return nil, false
+	}
+
+	cacheHits.Inc()
+
+	return value.(*authzv1.Response), true
+}
+
+// Set caches the decision for the given request.
+func (c *Cache) Set(generation uint64, username string, r *ladon.Request, rsp *authzv1.Response) {
+	c.cache.SetWithTTL(Key(generation, username, r), rsp, 1, c.ttl)
+}
+
+// Close stops the cache goroutines.
+func (c *Cache) Close() {
+	c.cache.Close()
+}
+
+// Key returns the cache key of a request.
+// The context is marshaled to json, which sorts the map keys, to get a normalized form.
+func Key(generation uint64, username string, r *ladon.Request) string {
+	ctx, _ := json.Marshal(r.Context)
+
+	return strings.Join([]string{
+		strconv.FormatUint(generation, 10),
+		username,
+		r.Subject,
+		r.Action,
+		r.Resource,
+		string(ctx),
+	}, "\x00")
+}
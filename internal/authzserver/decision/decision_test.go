@@ -0,0 +1,60 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package decision
+
+import (
+	"testing"
+
+	"github.com/ory/ladon"
+)
+
+func TestKey(t *testing.T) {
+	request := func(ctx ladon.Context) *ladon.Request {
+		return &ladon.Request{
+			Subject:  "users:peter",
+			Action:   "delete",
+			Resource: "resources:articles:ladon",
+			Context:  ctx,
+		}
+	}
+
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{
+			name:  "context key order",
+			a:     Key(1, "colin", request(ladon.Context{"a": "1", "b": "2"})),
+			b:     Key(1, "colin", request(ladon.Context{"b": "2", "a": "1"})),
+			equal: true,
+		},
+		{
+			name:  "different generation",
+			a:     Key(1, "colin", request(nil)),
+			b:     Key(2, "colin", request(nil)),
+			equal: false,
+		},
+		{
+			name:  "different username",
+			a:     Key(1, "colin", request(nil)),
+			b:     Key(1, "admin", request(nil)),
+			equal: false,
+		},
+		{
+			name:  "different context",
+			a:     Key(1, "colin", request(ladon.Context{"remoteIPAddress": "192.168.0.5"})),
+			b:     Key(1, "colin", request(ladon.Context{"remoteIPAddress": "10.0.0.5"})),
+			equal: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a == tt.b; got != tt.equal {
+				t.Errorf("Key() equal = %v, want %v", got, tt.equal)
+			}
+		})
+	}
+}
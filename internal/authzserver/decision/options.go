@@ -0,0 +1,65 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package decision
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// CacheOptions contains configuration items related to decision cache.
+type CacheOptions struct {
+	Enable     bool          `json:"enable"      mapstructure:"enable"`
+	MaxEntries int64         `json:"max-entries" mapstructure:"max-entries"`
+	TTL        time.Duration `json:"ttl"         mapstructure:"ttl"`
+}
+
+// NewCacheOptions creates a CacheOptions object with default parameters.
+func NewCacheOptions() *CacheOptions {
+	return &CacheOptions{
+		Enable:     true,
+		MaxEntries: 100000,
+		TTL:        10 * time.Second,
+	}
+}
+
+// Validate is used to parse and validate the parameters entered by the user at
+// the command line when the program starts.
+func (o *CacheOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errors := []error{}
+
+	if o.Enable && o.MaxEntries <= 0 {
+		errors = append(errors, fmt.Errorf("--decision-cache.max-entries %v must be greater than 0", o.MaxEntries))
+	}
+
+	if o.Enable && o.TTL <= 0 {
+		errors = append(errors, fmt.Errorf("--decision-cache.ttl %v must be greater than 0", o.TTL))
+	}
+
+	return errors
+}
+
+// AddFlags adds flags related to decision cache for a specific authz server to the
+// specified FlagSet.
+func (o *CacheOptions) AddFlags(fs *pflag.FlagSet) {
+	if fs == nil {
+		return
+	}
+
+	fs.BoolVar(&o.Enable, "decision-cache.enable", o.Enable, ""+
+		"This sets the iam-authz-server to cache authorization decisions.")
+
+	fs.Int64Var(&o.MaxEntries, "decision-cache.max-entries", o.MaxEntries,
+		"Maximum number of authorization decisions to cache.")
+
+	fs.DurationVar(&o.TTL, "decision-cache.ttl", o.TTL, ""+
+		"How long a cached decision is valid. Cached decisions are also dropped once "+
+		"secrets and policies are reloaded. Keep it short when using time-bound grants.")
+}
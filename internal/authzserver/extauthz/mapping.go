@@ -0,0 +1,92 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package extauthz
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ory/ladon"
+)
+
+var placeholderRegexp = regexp.MustCompile(`{[a-z]+(:[^}]+)?}`)
+
+// httpAttributes contains the http request attributes used by mapping rules.
+type httpAttributes struct {
+	Method   string
+	Host     string
+	Path     string
+	Headers  map[string]string
+	RemoteIP string
+	Username string
+}
+
+func (r *MappingRule) matches(attrs *httpAttributes) bool {
+	if !strings.HasPrefix(attrs.Path, r.PathPrefix) {
+		return false
+	}
+
+	if len(r.Methods) == 0 {
+		return true
+	}
+
+	for _, method := range r.Methods {
+		if strings.EqualFold(method, attrs.Method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toRequest maps the http attributes to a ladon request.
+func (r *MappingRule) toRequest(attrs *httpAttributes) *ladon.Request {
+	ctx := ladon.Context{}
+	if attrs.RemoteIP != "" {
+		ctx["remoteIPAddress"] = attrs.RemoteIP
+	}
+
+	for key, header := range r.Context {
+		if value, ok := attrs.Headers[strings.ToLower(header)]; ok {
+			ctx[key] = value
+		}
+	}
+
+	return &ladon.Request{
+		Subject:  expand(r.Subject, attrs),
+		Action:   expand(r.Action, attrs),
+		Resource: expand(r.Resource, attrs),
+		Context:  ctx,
+	}
+}
+
+func expand(template string, attrs *httpAttributes) string {
+	return placeholderRegexp.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := strings.Trim(placeholder, "{}")
+		switch {
+		case name == "method":
+			return strings.ToLower(attrs.Method)
+		case name == "host":
+			return attrs.Host
+		case name == "path":
+			return attrs.Path
+		case name == "username":
+			return attrs.Username
+		case strings.HasPrefix(name, "header:"):
+			return attrs.Headers[strings.ToLower(strings.TrimPrefix(name, "header:"))]
+		default:
+			return placeholder
+		}
+	})
+}
+
+// stripQuery removes the query string and fragment from an envoy request path.
+func stripQuery(path string) string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		return path[:i]
+	}
+
+	return path
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package extauthz
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// MappingRule defines how an http request checked by envoy is mapped to a ladon request.
+// Subject, Action and Resource are templates which support the following placeholders:
+// {method} (lower case http method), {path}, {host}, {username} and {header:<name>}.
+type MappingRule struct {
+	PathPrefix string            `json:"path-prefix" mapstructure:"path-prefix"`
+	Methods    []string          `json:"methods"     mapstructure:"methods"`
+	Subject    string            `json:"subject"     mapstructure:"subject"`
+	Action     string            `json:"action"      mapstructure:"action"`
+	Resource   string            `json:"resource"    mapstructure:"resource"`
+	Context    map[string]string `json:"context"     mapstructure:"context"`
+}
+
+// ExtAuthzOptions contains configuration items related to envoy external authorization.
+type ExtAuthzOptions struct {
+	Enable      bool          `json:"enable"       mapstructure:"enable"`
+	BindAddress string        `json:"bind-address" mapstructure:"bind-address"`
+	BindPort    int           `json:"bind-port"    mapstructure:"bind-port"`
+	MaxMsgSize  int           `json:"max-msg-size" mapstructure:"max-msg-size"`
+	Rules       []MappingRule `json:"rules"        mapstructure:"rules"`
+}
+
+// NewExtAuthzOptions creates a ExtAuthzOptions object with default parameters.
+func NewExtAuthzOptions() *ExtAuthzOptions {
+	return &ExtAuthzOptions{
+		Enable:      false,
+		BindAddress: "127.0.0.1",
+		BindPort:    9091,
+		MaxMsgSize:  4 * 1024 * 1024,
+		Rules: []MappingRule{
+			{
+				PathPrefix: "/",
+				Subject:    "users:{username}",
+				Action:     "{method}",
+				Resource:   "resources:{host}{path}",
+			},
+		},
+	}
+}
+
+// Validate is used to parse and validate the parameters entered by the user at
+// the command line when the program starts.
+func (o *ExtAuthzOptions) Validate() []error {
+	if o == nil || !o.Enable {
+		return nil
+	}
+	errors := []error{}
+
+	if o.BindPort < 1 || o.BindPort > 65535 {
+		errors = append(errors, fmt.Errorf("--ext-authz.bind-port %v must be between 1 and 65535, inclusive", o.BindPort))
+	}
+
+	for i, rule := range o.Rules {
+		if rule.Subject == "" || rule.Action == "" || rule.Resource == "" {
+			errors = append(errors, fmt.Errorf("ext-authz.rules[%d] must specify subject, action and resource", i))
+		}
+	}
+
+	return errors
+}
+
+// AddFlags adds flags related to envoy external authorization for a specific authz server to the
+// specified FlagSet. Mapping rules can only be set in the configuration file.
+func (o *ExtAuthzOptions) AddFlags(fs *pflag.FlagSet) {
+	if fs == nil {
+		return
+	}
+
+	fs.BoolVar(&o.Enable, "ext-authz.enable", o.Enable, ""+
+		"Serve the envoy external authorization (envoy.service.auth.v3.Authorization) grpc api.")
+
+	fs.StringVar(&o.BindAddress, "ext-authz.bind-address", o.BindAddress, ""+
+		"The IP address on which to serve the envoy external authorization grpc api.")
+
+	fs.IntVar(&o.BindPort, "ext-authz.bind-port", o.BindPort, ""+
+		"The port on which to serve the envoy external authorization grpc api.")
+
+	fs.IntVar(&o.MaxMsgSize, "ext-authz.max-msg-size", o.MaxMsgSize, "gRPC max message size.")
+}
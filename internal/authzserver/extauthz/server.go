@@ -0,0 +1,161 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package extauthz implements the envoy external authorization grpc api.
+package extauthz
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/marmotedu/iam/internal/authzserver/authorization"
+	"github.com/marmotedu/iam/internal/authzserver/authorization/authorizer"
+	"github.com/marmotedu/iam/internal/pkg/middleware/auth"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Headers added to the upstream request when the access is allowed.
+const (
+	HeaderUsername = "x-iam-username"
+	HeaderSubject  = "x-iam-subject"
+)
+
+// Server implements the envoy.service.auth.v3.Authorization grpc service.
+type Server struct {
+	strategy auth.CacheStrategy
+	store    authorizer.PolicyGetter
+	rules    []MappingRule
+}
+
+var _ authv3.AuthorizationServer = &Server{}
+
+// NewServer creates an envoy external authorization server.
+func NewServer(strategy auth.CacheStrategy, store authorizer.PolicyGetter, rules []MappingRule) *Server {
+	return &Server{
+		strategy: strategy,
+		store:    store,
+		rules:    rules,
+	}
+}
+
+// Check authenticates the bearer token of the http request checked by envoy, maps the
+// request to a ladon request and authorizes it.
+func (s *Server) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	httpReq := req.GetAttributes().GetRequest().GetHttp()
+	attrs := &httpAttributes{
+		Method:   httpReq.GetMethod(),
+		Host:     httpReq.GetHost(),
+		Path:     stripQuery(httpReq.GetPath()),
+		Headers:  httpReq.GetHeaders(),
+		RemoteIP: req.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress(),
+	}
+
+	var rawJWT string
+	// Parse the header to get the token part.
+	fmt.Sscanf(attrs.Headers["authorization"], "Bearer %s", &rawJWT)
+	if rawJWT == "" {
+		return deniedResponse(codes.Unauthenticated, typev3.StatusCode_Unauthorized,
+			"Authorization header cannot be empty."), nil
+	}
+
+	secret, err := s.strategy.ParseToken(rawJWT)
+	if err != nil {
+		return deniedResponse(codes.Unauthenticated, typev3.StatusCode_Unauthorized, err.Error()), nil
+	}
+	attrs.Username = secret.Username
+
+	var rule *MappingRule
+	for i := range s.rules {
+		if s.rules[i].matches(attrs) {
+			rule = &s.rules[i]
+
+			break
+		}
+	}
+
+	if rule == nil {
+		log.L(ctx).Debugw("no mapping rule matched", "method", attrs.Method, "path", attrs.Path)
+
+		return deniedResponse(codes.PermissionDenied, typev3.StatusCode_Forbidden, "no mapping rule matched"), nil
+	}
+
+	r := rule.toRequest(attrs)
+	r.Context["username"] = secret.Username
+
+	rsp := authorization.NewAuthorizer(authorizer.NewAuthorization(s.store)).Authorize(r)
+	if !rsp.Allowed {
+		return deniedResponse(codes.PermissionDenied, typev3.StatusCode_Forbidden, rsp.Reason), nil
+	}
+
+	return &authv3.CheckResponse{
+		Status: &status.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{
+			OkResponse: &authv3.OkHttpResponse{
+				Headers: []*corev3.HeaderValueOption{
+					{Header: &corev3.HeaderValue{Key: HeaderUsername, Value: secret.Username}},
+					{Header: &corev3.HeaderValue{Key: HeaderSubject, Value: r.Subject}},
+				},
+			},
+		},
+	}, nil
+}
+
+func deniedResponse(code codes.Code, httpCode typev3.StatusCode, reason string) *authv3.CheckResponse {
+	return &authv3.CheckResponse{
+		Status: &status.Status{Code: int32(code), Message: reason},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{
+			DeniedResponse: &authv3.DeniedHttpResponse{
+				Status: &typev3.HttpStatus{Code: httpCode},
+				Body:   reason,
+			},
+		},
+	}
+}
+
+// GRPCServer serves the envoy external authorization grpc api.
+type GRPCServer struct {
+	*grpc.Server
+	address string
+}
+
+// NewGRPCServer creates a grpc server which serves the given external authorization server.
+func NewGRPCServer(opts *ExtAuthzOptions, srv authv3.AuthorizationServer) *GRPCServer {
+	grpcServer := grpc.NewServer(grpc.MaxRecvMsgSize(opts.MaxMsgSize))
+	authv3.RegisterAuthorizationServer(grpcServer, srv)
+
+	return &GRPCServer{
+		Server:  grpcServer,
+		address: net.JoinHostPort(opts.BindAddress, fmt.Sprintf("%d", opts.BindPort)),
+	}
+}
+
+// Run starts to serve in background.
+func (s *GRPCServer) Run() {
+	listen, err := net.Listen("tcp", s.address)
+	if err != nil {
+		log.Fatalf("failed to listen: %s", err.Error())
+	}
+
+	go func() {
+		if err := s.Serve(listen); err != nil {
+			log.Fatalf("failed to start ext_authz grpc server: %s", err.Error())
+		}
+	}()
+
+	log.Infof("start ext_authz grpc server at %s", s.address)
+}
+
+// Close stops the grpc server gracefully.
+func (s *GRPCServer) Close() {
+	s.GracefulStop()
+	log.Infof("ext_authz grpc server on %s stopped", s.address)
+}
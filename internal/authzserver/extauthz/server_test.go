@@ -0,0 +1,202 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package extauthz
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/ory/ladon"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/marmotedu/iam/internal/pkg/middleware/auth"
+)
+
+type fakePolicyGetter map[string][]*ladon.DefaultPolicy
+
+func (f fakePolicyGetter) GetPolicy(key string) ([]*ladon.DefaultPolicy, error) {
+	return f[key], nil
+}
+
+func newTestClient(t *testing.T) authv3.AuthorizationClient {
+	t.Helper()
+
+	strategy := auth.NewCacheStrategy(func(kid string) (auth.Secret, error) {
+		if kid != "secret-id" {
+			return auth.Secret{}, errors.New("secret not found")
+		}
+
+		return auth.Secret{Username: "colin", ID: kid, Key: "secret-key"}, nil
+	})
+
+	store := fakePolicyGetter{
+		"colin": {
+			{
+				ID:        "allow-read-articles",
+				Subjects:  []string{"users:colin"},
+				Resources: []string{"resources:blog.example.com/articles/<.*>"},
+				Actions:   []string{"get"},
+				Effect:    ladon.AllowAccess,
+			},
+		},
+	}
+
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+
+	srv := grpc.NewServer()
+	authv3.RegisterAuthorizationServer(srv, NewServer(strategy, store, NewExtAuthzOptions().Rules))
+	go func() {
+		_ = srv.Serve(listen)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(listen.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("grpc.Dial() error = %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return authv3.NewAuthorizationClient(conn)
+}
+
+func newToken(t *testing.T, kid, key string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString([]byte(key))
+	if err != nil {
+		t.Fatalf("token.SignedString() error = %v", err)
+	}
+
+	return signed
+}
+
+func newCheckRequest(method, path, token string) *authv3.CheckRequest {
+	headers := map[string]string{}
+	if token != "" {
+		headers["authorization"] = "Bearer " + token
+	}
+
+	return &authv3.CheckRequest{
+		Attributes: &authv3.AttributeContext{
+			Request: &authv3.AttributeContext_Request{
+				Http: &authv3.AttributeContext_HttpRequest{
+					Method:  method,
+					Host:    "blog.example.com",
+					Path:    path,
+					Headers: headers,
+				},
+			},
+			Source: &authv3.AttributeContext_Peer{
+				Address: &corev3.Address{
+					Address: &corev3.Address_SocketAddress{
+						SocketAddress: &corev3.SocketAddress{Address: "192.168.0.5"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestServer_Check(t *testing.T) {
+	client := newTestClient(t)
+
+	tests := []struct {
+		name     string
+		request  *authv3.CheckRequest
+		wantCode codes.Code
+	}{
+		{
+			name:     "allow",
+			request:  newCheckRequest("GET", "/articles/ladon?lang=en", newToken(t, "secret-id", "secret-key")),
+			wantCode: codes.OK,
+		},
+		{
+			name:     "deny by policy",
+			request:  newCheckRequest("DELETE", "/articles/ladon", newToken(t, "secret-id", "secret-key")),
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "missing token",
+			request:  newCheckRequest("GET", "/articles/ladon", ""),
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "bad signature",
+			request:  newCheckRequest("GET", "/articles/ladon", newToken(t, "secret-id", "other-key")),
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "unknown secret",
+			request:  newCheckRequest("GET", "/articles/ladon", newToken(t, "unknown", "secret-key")),
+			wantCode: codes.Unauthenticated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp, err := client.Check(context.Background(), tt.request)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+
+			if got := codes.Code(rsp.GetStatus().GetCode()); got != tt.wantCode {
+				t.Fatalf("Check() code = %v, want %v, message: %s", got, tt.wantCode, rsp.GetStatus().GetMessage())
+			}
+
+			if tt.wantCode != codes.OK {
+				return
+			}
+
+			headers := map[string]string{}
+			for _, h := range rsp.GetOkResponse().GetHeaders() {
+				headers[h.GetHeader().GetKey()] = h.GetHeader().GetValue()
+			}
+			if headers[HeaderUsername] != "colin" {
+				t.Errorf("Check() %s header = %q, want %q", HeaderUsername, headers[HeaderUsername], "colin")
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	attrs := &httpAttributes{
+		Method:   "POST",
+		Host:     "blog.example.com",
+		Path:     "/articles",
+		Headers:  map[string]string{"x-tenant": "marmotedu"},
+		Username: "colin",
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"users:{username}", "users:colin"},
+		{"{method}", "post"},
+		{"resources:{header:X-Tenant}:{host}{path}", "resources:marmotedu:blog.example.com/articles"},
+		{"resources:{unknown}", "resources:{unknown}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			if got := expand(tt.template, attrs); got != tt.want {
+				t.Errorf("expand() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
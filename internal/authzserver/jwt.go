@@ -13,7 +13,13 @@ import (
 )
 
 func newCacheAuth() middleware.AuthStrategy {
-	return auth.NewCacheStrategy(getSecretFunc())
+	return newCacheStrategy()
+}
+
+func newCacheStrategy() auth.CacheStrategy {
+	return auth.NewCacheStrategy(getSecretFunc()).WithFailureObserver(func(reason string) {
+		tokenValidationFailures.WithLabelValues(reason).Inc()
+	})
 }
 
 func getSecretFunc() func(string) (auth.Secret, error) {
@@ -6,13 +6,17 @@ package cache
 
 import (
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dgraph-io/ristretto"
 	pb "github.com/marmotedu/api/proto/apiserver/v1"
 	"github.com/marmotedu/errors"
 	"github.com/ory/ladon"
 
+	"github.com/marmotedu/iam/internal/authzserver/authorization"
 	"github.com/marmotedu/iam/internal/authzserver/store"
+	"github.com/marmotedu/iam/pkg/log"
 )
 
 // Cache is used to store secrets and policies.
@@ -21,6 +25,39 @@ type Cache struct {
 	cli      store.Factory
 	secrets  *ristretto.Cache
 	policies *ristretto.Cache
+	// snapshot keeps all the policies loaded by the last reload, grouped by
+	// username, so that they can be enumerated.
+	snapshot map[string][]*ladon.DefaultPolicy
+	// generation is increased every time secrets and policies are reloaded.
+	generation uint64
+	// snapshotOptions configures persisting secrets and policies to disk, nil disables it.
+	snapshotOptions *SnapshotOptions
+	// status reports where the cached secrets and policies come from.
+	status Status
+}
+
+// Status reports where the cached secrets and policies come from.
+type Status struct {
+	// Source is one of SourceNone, SourceAPIServer and SourceSnapshot.
+	Source string `json:"source"`
+	// Stale is true until secrets and policies are loaded from iam-apiserver.
+	Stale bool `json:"stale"`
+	// LoadedAt is the time the cached secrets and policies were fetched from iam-apiserver.
+	LoadedAt   time.Time `json:"loadedAt"`
+	Generation uint64    `json:"generation"`
+}
+
+// Sources of the cached secrets and policies.
+const (
+	SourceNone      = "none"
+	SourceAPIServer = "apiserver"
+	SourceSnapshot  = "snapshot"
+)
+
+// policyEntry holds the policies of a user together with their compiled form.
+type policyEntry struct {
+	policies []*ladon.DefaultPolicy
+	compiled []*authorization.CompiledPolicy
 }
 
 var (
@@ -66,6 +103,8 @@ func GetCacheInsOr(cli store.Factory) (*Cache, error) {
 				lock:     new(sync.RWMutex),
 				secrets:  secretCache,
 				policies: policyCache,
+				snapshot: make(map[string][]*ladon.DefaultPolicy),
+				status:   Status{Source: SourceNone, Stale: true},
 			}
 		})
 	}
@@ -96,11 +135,70 @@ func (c *Cache) GetPolicy(key string) ([]*ladon.DefaultPolicy, error) {
 		return nil, ErrPolicyNotFound
 	}
 
-	return value.([]*ladon.DefaultPolicy), nil
+	return value.(*policyEntry).policies, nil
+}
+
+// GetCompiledPolicy return user's compiled ladon policies for the given user.
+func (c *Cache) GetCompiledPolicy(key string) ([]*authorization.CompiledPolicy, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	value, ok := c.policies.Get(key)
+	if !ok {
+		return nil, ErrPolicyNotFound
+	}
+
+	return value.(*policyEntry).compiled, nil
+}
+
+// ListPolicies return all the cached ladon policies grouped by username.
+func (c *Cache) ListPolicies() map[string][]*ladon.DefaultPolicy {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	policies := make(map[string][]*ladon.DefaultPolicy, len(c.snapshot))
+	for key, val := range c.snapshot {
+		policies[key] = val
+	}
+
+	return policies
+}
+
+// Generation returns the reload generation of the cache.
+func (c *Cache) Generation() uint64 {
+	return atomic.LoadUint64(&c.generation)
+}
+
+// SetSnapshotOptions enables persisting secrets and policies to disk after every successful reload.
+func (c *Cache) SetSnapshotOptions(opts *SnapshotOptions) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.snapshotOptions = opts
+}
+
+// Status returns where the cached secrets and policies come from.
+func (c *Cache) Status() Status {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	status := c.status
+	status.Generation = c.Generation()
+
+	return status
 }
 
 // Reload reload secrets and policies.
-func (c *Cache) Reload() error {
+func (c *Cache) Reload() (err error) {
+	start := time.Now()
+	defer func() {
+		result := "success"
+		if err != nil {
+			result = "failure"
+		}
+		reloadDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	}()
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
@@ -110,21 +208,94 @@ func (c *Cache) Reload() error {
 		return errors.Wrap(err, "list secrets failed")
 	}
 
-	c.secrets.Clear()
-	for key, val := range secrets {
-		c.secrets.Set(key, val, 1)
-	}
-
 	// reload policies
 	policies, err := c.cli.Policies().List()
 	if err != nil {
 		return errors.Wrap(err, "list policies failed")
 	}
 
+	c.apply(secrets, policies)
+	c.status = Status{Source: SourceAPIServer, LoadedAt: time.Now()}
+
+	if c.snapshotOptions.Enabled() {
+		s := &snapshot{CreatedAt: c.status.LoadedAt, Secrets: secrets, Policies: policies}
+		if err := saveSnapshot(c.snapshotOptions, s); err != nil {
+			log.Warnf("failed to save snapshot to %s, error: %s", c.snapshotOptions.Path, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// LoadSnapshot loads secrets and policies from the snapshot file. It does nothing if
+// they have already been loaded from iam-apiserver.
+func (c *Cache) LoadSnapshot() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.snapshotOptions.Enabled() || !c.status.Stale {
+		return nil
+	}
+
+	s, err := loadSnapshot(c.snapshotOptions)
+	if err != nil {
+		return err
+	}
+
+	c.apply(s.Secrets, s.Policies)
+	c.status = Status{Source: SourceSnapshot, Stale: true, LoadedAt: s.CreatedAt}
+
+	log.Warnf("Loaded secrets and policies from snapshot %s created at %s",
+		c.snapshotOptions.Path, s.CreatedAt.Format(time.RFC3339))
+
+	return nil
+}
+
+// cacheSetRetries is how many times a value dropped by ristretto is set again.
+const cacheSetRetries = 3
+
+// setCache sets key in cache. ristretto drops a Set when its buffers are full, it is retried
+// once the pending sets are applied.
+func setCache(cache *ristretto.Cache, kind, key string, val interface{}) {
+	for i := 0; i < cacheSetRetries; i++ {
+		if cache.Set(key, val, 1) {
+			return
+		}
+		cache.Wait()
+	}
+
+	log.Errorf("failed to cache %s for %s, it is dropped by the cache", kind, key)
+}
+
+// apply replaces the cached secrets and policies. The caller must hold the lock.
+func (c *Cache) apply(secrets map[string]*pb.SecretInfo, policies map[string][]*ladon.DefaultPolicy) {
+	c.secrets.Clear()
+	for key, val := range secrets {
+		setCache(c.secrets, "secret", key, val)
+	}
+
 	c.policies.Clear()
 	for key, val := range policies {
-		c.policies.Set(key, val, 1)
+		// compile the policies once here, instead of on every authorization.
+		compiled, err := authorization.CompilePolicies(val)
+		if err != nil {
+			log.Warnf("failed to compile policies for %s, error: %s", key, err.Error())
+		}
+
+		setCache(c.policies, "policies", key, &policyEntry{policies: val, compiled: compiled})
 	}
+	c.snapshot = policies
 
-	return nil
+	// ristretto applies Set asynchronously, the new generation must only be visible with the
+	// new secrets and policies, or the decisions made with the old ones are cached under it.
+	c.secrets.Wait()
+	c.policies.Wait()
+	atomic.AddUint64(&c.generation, 1)
+
+	total := 0
+	for _, val := range policies {
+		total += len(val)
+	}
+	cachedSecrets.Set(float64(len(secrets)))
+	cachedPolicies.Set(float64(total))
 }
@@ -0,0 +1,66 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/dgraph-io/ristretto"
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/ory/ladon"
+)
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+
+	newRistretto := func() *ristretto.Cache {
+		cache, err := ristretto.NewCache(&ristretto.Config{NumCounters: 1e4, MaxCost: 1 << 20, BufferItems: 64})
+		if err != nil {
+			t.Fatalf("ristretto.NewCache() error = %v", err)
+		}
+
+		return cache
+	}
+
+	return &Cache{
+		lock:     new(sync.RWMutex),
+		secrets:  newRistretto(),
+		policies: newRistretto(),
+		snapshot: make(map[string][]*ladon.DefaultPolicy),
+	}
+}
+
+func TestCache_applyVisibleWithGeneration(t *testing.T) {
+	c := newTestCache(t)
+
+	secrets := map[string]*pb.SecretInfo{}
+	policies := map[string][]*ladon.DefaultPolicy{}
+	for i := 0; i < 1000; i++ {
+		secrets[fmt.Sprintf("secret-%d", i)] = &pb.SecretInfo{Username: "colin"}
+		policies[fmt.Sprintf("user-%d", i)] = []*ladon.DefaultPolicy{{ID: "policy", Effect: ladon.AllowAccess}}
+	}
+
+	c.lock.Lock()
+	c.apply(secrets, policies)
+	c.lock.Unlock()
+
+	if got := c.Generation(); got != 1 {
+		t.Fatalf("Generation() = %d, want 1", got)
+	}
+
+	// everything is readable as soon as the generation is increased.
+	for key := range secrets {
+		if _, err := c.GetSecret(key); err != nil {
+			t.Fatalf("GetSecret(%s) error = %v", key, err)
+		}
+	}
+	for key := range policies {
+		if _, err := c.GetPolicy(key); err != nil {
+			t.Fatalf("GetPolicy(%s) error = %v", key, err)
+		}
+	}
+}
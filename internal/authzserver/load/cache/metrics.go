@@ -0,0 +1,26 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	reloadDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "iam_authz_cache_reload_duration_seconds",
+		Help:    "Time spent reloading secrets and policies from iam-apiserver by result.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"result"})
+	cachedSecrets = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "iam_authz_cache_secrets",
+		Help: "Number of secrets in the authorization cache.",
+	})
+	cachedPolicies = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "iam_authz_cache_policies",
+		Help: "Number of policies in the authorization cache.",
+	})
+)
@@ -0,0 +1,111 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+)
+
+// snapshot is the content of the snapshot file.
+type snapshot struct {
+	CreatedAt time.Time                         `json:"createdAt"`
+	Secrets   map[string]*pb.SecretInfo         `json:"secrets"`
+	Policies  map[string][]*ladon.DefaultPolicy `json:"policies"`
+}
+
+// saveSnapshot encrypts the snapshot with AES-GCM and atomically replaces the snapshot file.
+func saveSnapshot(opts *SnapshotOptions, s *snapshot) error {
+	plaintext, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "marshal snapshot failed")
+	}
+
+	gcm, err := newGCM(opts.EncryptionKey)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return errors.Wrap(err, "generate nonce failed")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(opts.Path), filepath.Base(opts.Path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "create snapshot file failed")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(gcm.Seal(nonce, nonce, plaintext, nil)); err != nil {
+		_ = tmp.Close()
+
+		return errors.Wrap(err, "write snapshot file failed")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "write snapshot file failed")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), opts.Path), "replace snapshot file failed")
+}
+
+// loadSnapshot reads and decrypts the snapshot file.
+func loadSnapshot(opts *SnapshotOptions) (*snapshot, error) {
+	data, err := os.ReadFile(opts.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read snapshot file failed")
+	}
+
+	gcm, err := newGCM(opts.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("snapshot file is corrupted")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt snapshot file failed")
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(plaintext, &s); err != nil {
+		return nil, errors.Wrap(err, "unmarshal snapshot failed")
+	}
+
+	return &s, nil
+}
+
+// newGCM derives an AES-256 key from the configured encryption key.
+func newGCM(key string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(key))
+
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "create cipher failed")
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "create gcm failed")
+	}
+
+	return gcm, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// SnapshotOptions contains configuration items related to the on-disk snapshot of secrets and policies.
+type SnapshotOptions struct {
+	Path          string `json:"path"           mapstructure:"path"`
+	EncryptionKey string `json:"encryption-key" mapstructure:"encryption-key"`
+}
+
+// NewSnapshotOptions creates a SnapshotOptions object with default parameters.
+func NewSnapshotOptions() *SnapshotOptions {
+	return &SnapshotOptions{}
+}
+
+// Enabled returns true if secrets and policies should be persisted to disk.
+func (o *SnapshotOptions) Enabled() bool {
+	return o != nil && o.Path != ""
+}
+
+// Validate is used to parse and validate the parameters entered by the user at
+// the command line when the program starts.
+func (o *SnapshotOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errors := []error{}
+
+	if o.Path != "" && o.EncryptionKey == "" {
+		errors = append(errors, fmt.Errorf("--snapshot.encryption-key is required when --snapshot.path is set"))
+	}
+
+	return errors
+}
+
+// AddFlags adds flags related to snapshot for a specific authz server to the
+// specified FlagSet.
+func (o *SnapshotOptions) AddFlags(fs *pflag.FlagSet) {
+	if fs == nil {
+		return
+	}
+
+	fs.StringVar(&o.Path, "snapshot.path", o.Path, ""+
+		"File to persist the last successfully loaded secrets and policies to. They are loaded from "+
+		"this file at startup if iam-apiserver is unreachable. Disabled if empty.")
+
+	fs.StringVar(&o.EncryptionKey, "snapshot.encryption-key", o.EncryptionKey,
+		"Key used to encrypt the snapshot file, since it contains secret keys.")
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/ory/ladon"
+)
+
+func TestSnapshot(t *testing.T) {
+	opts := &SnapshotOptions{
+		Path:          filepath.Join(t.TempDir(), "snapshot"),
+		EncryptionKey: "encryption-key",
+	}
+
+	want := &snapshot{
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Secrets: map[string]*pb.SecretInfo{
+			"secret-id": {Username: "colin", SecretId: "secret-id", SecretKey: "secret-key"},
+		},
+		Policies: map[string][]*ladon.DefaultPolicy{
+			"colin": {
+				{
+					ID:         "policy",
+					Subjects:   []string{"users:colin"},
+					Resources:  []string{"resources:<.*>"},
+					Actions:    []string{"get"},
+					Effect:     ladon.AllowAccess,
+					Conditions: ladon.Conditions{"remoteIPAddress": &ladon.CIDRCondition{CIDR: "192.168.0.1/16"}},
+				},
+			},
+		},
+	}
+
+	if err := saveSnapshot(opts, want); err != nil {
+		t.Fatalf("saveSnapshot() error = %v", err)
+	}
+
+	got, err := loadSnapshot(opts)
+	if err != nil {
+		t.Fatalf("loadSnapshot() error = %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("loadSnapshot() createdAt = %v, want %v", got.CreatedAt, want.CreatedAt)
+	}
+	if got.Secrets["secret-id"].GetSecretKey() != "secret-key" {
+		t.Errorf("loadSnapshot() secret key = %v, want %v", got.Secrets["secret-id"].GetSecretKey(), "secret-key")
+	}
+	if cond, ok := got.Policies["colin"][0].Conditions["remoteIPAddress"].(*ladon.CIDRCondition); !ok ||
+		cond.CIDR != "192.168.0.1/16" {
+		t.Errorf("loadSnapshot() conditions = %v", got.Policies["colin"][0].Conditions)
+	}
+
+	opts.EncryptionKey = "wrong-key"
+	if _, err := loadSnapshot(opts); err == nil {
+		t.Errorf("loadSnapshot() with wrong key error = nil, want error")
+	}
+}
@@ -0,0 +1,15 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authzserver
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var tokenValidationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "iam_authz_token_validation_failures_total",
+	Help: "Total number of bearer tokens which failed validation by reason.",
+}, []string{"reason"})
@@ -10,6 +10,11 @@ import (
 	"github.com/marmotedu/component-base/pkg/json"
 
 	"github.com/marmotedu/iam/internal/authzserver/analytics"
+	"github.com/marmotedu/iam/internal/authzserver/controller/v1/k8s"
+	"github.com/marmotedu/iam/internal/authzserver/controller/v1/permission"
+	"github.com/marmotedu/iam/internal/authzserver/decision"
+	"github.com/marmotedu/iam/internal/authzserver/extauthz"
+	"github.com/marmotedu/iam/internal/authzserver/load/cache"
 	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
 	"github.com/marmotedu/iam/internal/pkg/server"
 	"github.com/marmotedu/iam/pkg/log"
@@ -26,6 +31,12 @@ type Options struct {
 	FeatureOptions          *genericoptions.FeatureOptions         `json:"feature"        mapstructure:"feature"`
 	Log                     *log.Options                           `json:"log"            mapstructure:"log"`
 	AnalyticsOptions        *analytics.AnalyticsOptions            `json:"analytics"      mapstructure:"analytics"`
+	DecisionCacheOptions    *decision.CacheOptions                 `json:"decision-cache" mapstructure:"decision-cache"`
+	ExtAuthzOptions         *extauthz.ExtAuthzOptions              `json:"ext-authz"      mapstructure:"ext-authz"`
+	WebhookOptions          *k8s.WebhookOptions                    `json:"k8s-webhook"    mapstructure:"k8s-webhook"`
+	PermissionOptions       *permission.PermissionOptions          `json:"permission"     mapstructure:"permission"`
+	SnapshotOptions         *cache.SnapshotOptions                 `json:"snapshot"       mapstructure:"snapshot"`
+	TracingOptions          *genericoptions.TracingOptions         `json:"tracing"        mapstructure:"tracing"`
 }
 
 // NewOptions creates a new Options object with default parameters.
@@ -40,6 +51,12 @@ func NewOptions() *Options {
 		FeatureOptions:          genericoptions.NewFeatureOptions(),
 		Log:                     log.NewOptions(),
 		AnalyticsOptions:        analytics.NewAnalyticsOptions(),
+		DecisionCacheOptions:    decision.NewCacheOptions(),
+		ExtAuthzOptions:         extauthz.NewExtAuthzOptions(),
+		WebhookOptions:          k8s.NewWebhookOptions(),
+		PermissionOptions:       permission.NewPermissionOptions(),
+		SnapshotOptions:         cache.NewSnapshotOptions(),
+		TracingOptions:          genericoptions.NewTracingOptions(),
 	}
 
 	return &o
@@ -54,6 +71,12 @@ func (o *Options) ApplyTo(c *server.Config) error {
 func (o *Options) Flags() (fss cliflag.NamedFlagSets) {
 	o.GenericServerRunOptions.AddFlags(fss.FlagSet("generic"))
 	o.AnalyticsOptions.AddFlags(fss.FlagSet("analytics"))
+	o.DecisionCacheOptions.AddFlags(fss.FlagSet("decision cache"))
+	o.ExtAuthzOptions.AddFlags(fss.FlagSet("ext authz"))
+	o.WebhookOptions.AddFlags(fss.FlagSet("k8s webhook"))
+	o.PermissionOptions.AddFlags(fss.FlagSet("permission"))
+	o.SnapshotOptions.AddFlags(fss.FlagSet("snapshot"))
+	o.TracingOptions.AddFlags(fss.FlagSet("tracing"))
 	o.RedisOptions.AddFlags(fss.FlagSet("redis"))
 	o.FeatureOptions.AddFlags(fss.FlagSet("features"))
 	o.InsecureServing.AddFlags(fss.FlagSet("insecure serving"))
@@ -15,6 +15,12 @@ func (o *Options) Validate() []error {
 	errs = append(errs, o.FeatureOptions.Validate()...)
 	errs = append(errs, o.Log.Validate()...)
 	errs = append(errs, o.AnalyticsOptions.Validate()...)
+	errs = append(errs, o.DecisionCacheOptions.Validate()...)
+	errs = append(errs, o.ExtAuthzOptions.Validate()...)
+	errs = append(errs, o.WebhookOptions.Validate()...)
+	errs = append(errs, o.PermissionOptions.Validate()...)
+	errs = append(errs, o.SnapshotOptions.Validate()...)
+	errs = append(errs, o.TracingOptions.Validate()...)
 
 	return errs
 }
@@ -10,20 +10,27 @@ import (
 	"github.com/marmotedu/errors"
 
 	"github.com/marmotedu/iam/internal/authzserver/controller/v1/authorize"
+	"github.com/marmotedu/iam/internal/authzserver/controller/v1/k8s"
+	"github.com/marmotedu/iam/internal/authzserver/controller/v1/permission"
+	"github.com/marmotedu/iam/internal/authzserver/decision"
 	"github.com/marmotedu/iam/internal/authzserver/load/cache"
 	"github.com/marmotedu/iam/internal/pkg/code"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
-func initRouter(g *gin.Engine) {
+func initRouter(g *gin.Engine, decisions *decision.Cache, webhookOptions *k8s.WebhookOptions,
+	permissionOptions *permission.PermissionOptions,
+) {
 	installMiddleware(g)
-	installController(g)
+	installController(g, decisions, webhookOptions, permissionOptions)
 }
 
 func installMiddleware(g *gin.Engine) {
 }
 
-func installController(g *gin.Engine) *gin.Engine {
+func installController(g *gin.Engine, decisions *decision.Cache, webhookOptions *k8s.WebhookOptions,
+	permissionOptions *permission.PermissionOptions,
+) *gin.Engine {
 	auth := newCacheAuth()
 	g.NoRoute(auth.AuthFunc(), func(c *gin.Context) {
 		core.WriteResponse(c, errors.WithCode(code.ErrPageNotFound, "page not found."), nil)
@@ -36,10 +43,19 @@ func installController(g *gin.Engine) *gin.Engine {
 
 	apiv1 := g.Group("/v1", auth.AuthFunc())
 	{
-		authzController := authorize.NewAuthzController(cacheIns)
+		authzController := authorize.NewAuthzController(cacheIns, decisions)
 
 		// Router for authorization
 		apiv1.POST("/authz", authzController.Authorize)
+
+		// Router for permission inspection
+		permissionController := permission.NewPermissionController(cacheIns, permissionOptions)
+		apiv1.GET("/users/:name/effective-permissions", permissionController.EffectivePermissions)
+		apiv1.GET("/resources/access", permissionController.ResourceAccess)
+
+		// Router for kubernetes authorization webhook
+		webhookController := k8s.NewWebhookController(cacheIns, webhookOptions)
+		apiv1.POST("/subjectaccessreviews", webhookController.SubjectAccessReview)
 	}
 
 	return g
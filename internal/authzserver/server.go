@@ -6,16 +6,23 @@ package authzserver
 
 import (
 	"context"
+	"net/http"
+	"time"
 
 	"github.com/marmotedu/errors"
 
 	"github.com/marmotedu/iam/internal/authzserver/analytics"
 	"github.com/marmotedu/iam/internal/authzserver/config"
+	"github.com/marmotedu/iam/internal/authzserver/controller/v1/k8s"
+	"github.com/marmotedu/iam/internal/authzserver/controller/v1/permission"
+	"github.com/marmotedu/iam/internal/authzserver/decision"
+	"github.com/marmotedu/iam/internal/authzserver/extauthz"
 	"github.com/marmotedu/iam/internal/authzserver/load"
 	"github.com/marmotedu/iam/internal/authzserver/load/cache"
 	"github.com/marmotedu/iam/internal/authzserver/store/apiserver"
 	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
 	genericapiserver "github.com/marmotedu/iam/internal/pkg/server"
+	"github.com/marmotedu/iam/internal/pkg/server/healthz"
 	"github.com/marmotedu/iam/pkg/log"
 	"github.com/marmotedu/iam/pkg/shutdown"
 	"github.com/marmotedu/iam/pkg/shutdown/shutdownmanagers/posixsignal"
@@ -26,13 +33,22 @@ import (
 const RedisKeyPrefix = "analytics-"
 
 type authzServer struct {
-	gs               *shutdown.GracefulShutdown
-	rpcServer        string
-	clientCA         string
-	redisOptions     *genericoptions.RedisOptions
-	genericAPIServer *genericapiserver.GenericAPIServer
-	analyticsOptions *analytics.AnalyticsOptions
-	redisCancelFunc  context.CancelFunc
+	gs                *shutdown.GracefulShutdown
+	rpcServer         string
+	clientCA          string
+	redisOptions      *genericoptions.RedisOptions
+	genericAPIServer  *genericapiserver.GenericAPIServer
+	analyticsOptions  *analytics.AnalyticsOptions
+	decisionOptions   *decision.CacheOptions
+	decisions         *decision.Cache
+	extAuthzOptions   *extauthz.ExtAuthzOptions
+	extAuthzServer    *extauthz.GRPCServer
+	webhookOptions    *k8s.WebhookOptions
+	permissionOptions *permission.PermissionOptions
+	snapshotOptions   *cache.SnapshotOptions
+	tracingOptions    *genericoptions.TracingOptions
+	shutdownTracing   func(context.Context) error
+	redisCancelFunc   context.CancelFunc
 }
 
 type preparedAuthzServer struct {
@@ -55,21 +71,34 @@ func createAuthzServer(cfg *config.Config) (*authzServer, error) {
 	}
 
 	server := &authzServer{
-		gs:               gs,
-		redisOptions:     cfg.RedisOptions,
-		analyticsOptions: cfg.AnalyticsOptions,
-		rpcServer:        cfg.RPCServer,
-		clientCA:         cfg.ClientCA,
-		genericAPIServer: genericServer,
+		gs:                gs,
+		redisOptions:      cfg.RedisOptions,
+		analyticsOptions:  cfg.AnalyticsOptions,
+		decisionOptions:   cfg.DecisionCacheOptions,
+		extAuthzOptions:   cfg.ExtAuthzOptions,
+		webhookOptions:    cfg.WebhookOptions,
+		permissionOptions: cfg.PermissionOptions,
+		snapshotOptions:   cfg.SnapshotOptions,
+		tracingOptions:    cfg.TracingOptions,
+		rpcServer:         cfg.RPCServer,
+		clientCA:          cfg.ClientCA,
+		genericAPIServer:  genericServer,
 	}
 
 	return server, nil
 }
 
 func (s *authzServer) PrepareRun() preparedAuthzServer {
-	_ = s.initialize()
+	var err error
+	if s.shutdownTracing, err = s.tracingOptions.Install("iam-authz-server"); err != nil {
+		log.Fatalf("failed to install tracer provider: %s", err.Error())
+	}
+
+	if err := s.initialize(); err != nil {
+		log.Fatalf("initialize authz server failed: %s", err.Error())
+	}
 
-	initRouter(s.genericAPIServer.Engine)
+	initRouter(s.genericAPIServer.Engine, s.decisions, s.webhookOptions, s.permissionOptions)
 
 	return preparedAuthzServer{s}
 }
@@ -79,11 +108,20 @@ func (s preparedAuthzServer) Run() error {
 	// in order to ensure that the reported data is not lost,
 	// please ensure the following graceful shutdown sequence
 	s.gs.AddShutdownCallback(shutdown.ShutdownFunc(func(string) error {
+		if s.extAuthzServer != nil {
+			s.extAuthzServer.Close()
+		}
 		s.genericAPIServer.Close()
 		if s.analyticsOptions.Enable {
 			analytics.GetAnalytics().Stop()
 		}
 		s.redisCancelFunc()
+		if s.decisions != nil {
+			s.decisions.Close()
+		}
+		if err := s.shutdownTracing(context.Background()); err != nil {
+			log.Warnf("failed to shutdown tracer provider: %s", err.Error())
+		}
 
 		return nil
 	}))
@@ -93,6 +131,10 @@ func (s preparedAuthzServer) Run() error {
 		log.Fatalf("start shutdown manager failed: %s", err.Error())
 	}
 
+	if s.extAuthzServer != nil {
+		s.extAuthzServer.Run()
+	}
+
 	return s.genericAPIServer.Run()
 }
 
@@ -143,19 +185,92 @@ func (s *authzServer) initialize() error {
 	go storage.ConnectToRedis(ctx, s.buildStorageConfig())
 
 	// cron to reload all secrets and policies from iam-apiserver
-	cacheIns, err := cache.GetCacheInsOr(apiserver.GetAPIServerFactoryOrDie(s.rpcServer, s.clientCA))
+	// do not wait for iam-apiserver, so that authorization keeps working from the
+	// snapshot while it is unreachable.
+	factory, err := apiserver.DialAPIServerFactory(s.rpcServer, s.clientCA)
+	if err != nil {
+		return errors.Wrap(err, "connect to iam-apiserver failed")
+	}
+
+	cacheIns, err := cache.GetCacheInsOr(factory)
 	if err != nil {
 		return errors.Wrap(err, "get cache instance failed")
 	}
 
-	load.NewLoader(ctx, cacheIns).Start()
+	cacheIns.SetSnapshotOptions(s.snapshotOptions)
+	installHealthChecks(s.genericAPIServer, cacheIns.Status, factory)
+
+	loader := load.NewLoader(ctx, cacheIns)
+	loader.Start()
+
+	if cacheIns.Status().Stale {
+		if err := cacheIns.LoadSnapshot(); err != nil {
+			log.Warnf("failed to load snapshot: %s", err.Error())
+		}
+
+		go reloadUntilFresh(ctx, cacheIns, loader)
+	}
+
+	// decisions are bound to the reload generation of cacheIns
+	if s.decisionOptions.Enable {
+		s.decisions, err = decision.NewCache(s.decisionOptions)
+		if err != nil {
+			return errors.Wrap(err, "create decision cache failed")
+		}
+	}
+
+	// serve envoy external authorization
+	if s.extAuthzOptions.Enable {
+		extAuthz := extauthz.NewServer(newCacheStrategy(), cacheIns, s.extAuthzOptions.Rules)
+		s.extAuthzServer = extauthz.NewGRPCServer(s.extAuthzOptions, extAuthz)
+	}
 
 	// start analytics service
 	if s.analyticsOptions.Enable {
-		analyticsStore := storage.RedisCluster{KeyPrefix: RedisKeyPrefix}
-		analyticsIns := analytics.NewAnalytics(s.analyticsOptions, &analyticsStore)
+		analyticsIns := analytics.NewAnalytics(s.analyticsOptions, analytics.NewSink(s.analyticsOptions, RedisKeyPrefix))
 		analyticsIns.Start()
 	}
 
 	return nil
 }
+
+// installHealthChecks adds the status of the cache and of iam-apiserver to /healthz, and the
+// readiness checks to /readyz. iam-apiserver is excluded from the readiness probe to keep
+// serving from the snapshot while it is unreachable.
+func installHealthChecks(s *genericapiserver.GenericAPIServer, status func() cache.Status, factory interface{}) {
+	s.AddHealthzStatus("cache", func() interface{} {
+		return status()
+	})
+	if pinger, ok := factory.(healthz.Pinger); ok {
+		s.AddHealthzStatus("apiserver-grpc", healthz.PingStatus(pinger, 5*time.Second))
+	}
+
+	s.AddReadyzChecks(
+		healthz.RedisCheck(),
+		healthz.NamedCheck("cache", func(*http.Request) error {
+			if status().Source == cache.SourceNone {
+				return errors.New("secrets and policies are not loaded")
+			}
+
+			return nil
+		}),
+	)
+}
+
+// reloadUntilFresh retries loading secrets and policies from iam-apiserver until it succeeds.
+// Afterwards they are only reloaded on change notifications.
+func reloadUntilFresh(ctx context.Context, cacheIns *cache.Cache, loader *load.Load) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for cacheIns.Status().Stale {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			loader.DoReload()
+		}
+	}
+
+	log.Info("Secrets and policies are loaded from iam-apiserver")
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authzserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/authzserver/load/cache"
+	genericapiserver "github.com/marmotedu/iam/internal/pkg/server"
+)
+
+type failingPinger struct{}
+
+func (failingPinger) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestInstallHealthChecks(t *testing.T) {
+	cfg := genericapiserver.NewConfig()
+	cfg.EnableMetrics = false
+	cfg.EnableProfiling = false
+
+	s, err := cfg.Complete().New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	status := cache.Status{Source: cache.SourceSnapshot, Stale: true}
+	installHealthChecks(s, func() cache.Status { return status }, failingPinger{})
+
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{
+			// redis is not connected in the test.
+			name:     "ready with iam-apiserver down",
+			url:      "/readyz?exclude=redis",
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+		},
+		{
+			name:     "iam-apiserver reported by healthz",
+			url:      "/healthz",
+			wantCode: http.StatusOK,
+			wantBody: `"apiserver-grpc":{"error":"connection refused","status":"failed"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if w.Code != tt.wantCode {
+				t.Errorf("%s returned %d, want %d: %s", tt.url, w.Code, tt.wantCode, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("%s body = %s, want %s", tt.url, w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -5,18 +5,21 @@
 package apiserver
 
 import (
-	"sync"
+	"context"
 
 	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/marmotedu/errors"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
 
 	"github.com/marmotedu/iam/internal/authzserver/store"
-	"github.com/marmotedu/iam/pkg/log"
 )
 
 type datastore struct {
-	cli pb.CacheClient
+	conn *grpc.ClientConn
+	cli  pb.CacheClient
 }
 
 func (ds *datastore) Secrets() store.SecretStore {
@@ -27,37 +30,34 @@ func (ds *datastore) Policies() store.PolicyStore {
 	return newPolicies(ds)
 }
 
-var (
-	apiServerFactory store.Factory
-	once             sync.Once
-)
+// Ping reports whether the grpc connection to iam-apiserver is ready.
+func (ds *datastore) Ping(ctx context.Context) error {
+	state := ds.conn.GetState()
+	if state == connectivity.Ready {
+		return nil
+	}
+
+	// make an idle connection reconnect
+	ds.conn.Connect()
+
+	return errors.Errorf("grpc connection is %s", state)
+}
+
+// DialAPIServerFactory returns a store factory without waiting for the connection to iam-apiserver.
+// grpc keeps reconnecting in the background, requests fail until the connection is established.
+func DialAPIServerFactory(address string, clientCA string) (store.Factory, error) {
+	creds, err := credentials.NewClientTLSFromFile(clientCA, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "load client ca failed")
+	}
 
-// GetAPIServerFactoryOrDie return cache instance and panics on any error.
-func GetAPIServerFactoryOrDie(address string, clientCA string) store.Factory {
-	once.Do(func() {
-		var (
-			err   error
-			conn  *grpc.ClientConn
-			creds credentials.TransportCredentials
-		)
-
-		creds, err = credentials.NewClientTLSFromFile(clientCA, "")
-		if err != nil {
-			log.Panicf("credentials.NewClientTLSFromFile err: %v", err)
-		}
-
-		conn, err = grpc.Dial(address, grpc.WithBlock(), grpc.WithTransportCredentials(creds))
-		if err != nil {
-			log.Panicf("Connect to grpc server failed, error: %s", err.Error())
-		}
-
-		apiServerFactory = &datastore{pb.NewCacheClient(conn)}
-		log.Infof("Connected to grpc server, address: %s", address)
-	})
-
-	if apiServerFactory == nil {
-		log.Panicf("failed to get apiserver store fatory")
+	conn, err := grpc.Dial(address,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "dial grpc server %s failed", address)
 	}
 
-	return apiServerFactory
+	return &datastore{conn, pb.NewCacheClient(conn)}, nil
 }
@@ -32,17 +32,42 @@ type Secret struct {
 	Expires  int64
 }
 
+// Reasons of token validation failures reported by CacheStrategy.
+const (
+	FailureMissingToken  = "missing_token"
+	FailureMissingKID    = "missing_kid"
+	FailureUnknownSecret = "unknown_secret"
+	FailureExpired       = "expired"
+	FailureBadSignature  = "bad_signature"
+)
+
 // CacheStrategy defines jwt bearer authentication strategy which called `cache strategy`.
 // Secrets are obtained through grpc api interface and cached in memory.
 type CacheStrategy struct {
 	get func(kid string) (Secret, error)
+	// failed is called with the reason of every token validation failure, it can be nil.
+	failed func(reason string)
 }
 
 var _ middleware.AuthStrategy = &CacheStrategy{}
 
 // NewCacheStrategy create cache strategy with function which can list and cache secrets.
 func NewCacheStrategy(get func(kid string) (Secret, error)) CacheStrategy {
-	return CacheStrategy{get}
+	return CacheStrategy{get: get}
+}
+
+// WithFailureObserver returns a copy of the strategy which reports the reason of
+// every token validation failure to observe.
+func (cache CacheStrategy) WithFailureObserver(observe func(reason string)) CacheStrategy {
+	cache.failed = observe
+
+	return cache
+}
+
+func (cache CacheStrategy) fail(reason string) {
+	if cache.failed != nil {
+		cache.failed(reason)
+	}
 }
 
 // AuthFunc defines cache strategy as the gin authentication middleware.
@@ -50,6 +75,7 @@ func (cache CacheStrategy) AuthFunc() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		header := c.Request.Header.Get("Authorization")
 		if len(header) == 0 {
+			cache.fail(FailureMissingToken)
 			core.WriteResponse(c, errors.WithCode(code.ErrMissingHeader, "Authorization header cannot be empty."), nil)
 			c.Abort()
 
@@ -60,48 +86,70 @@ func (cache CacheStrategy) AuthFunc() gin.HandlerFunc {
 		// Parse the header to get the token part.
 		fmt.Sscanf(header, "Bearer %s", &rawJWT)
 
-		// Use own validation logic, see below
-		var secret Secret
-
-		claims := &jwt.MapClaims{}
-		// Verify the token
-		parsedT, err := jwt.ParseWithClaims(rawJWT, claims, func(token *jwt.Token) (interface{}, error) {
-			// Validate the alg is HMAC signature
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
-			kid, ok := token.Header["kid"].(string)
-			if !ok {
-				return nil, ErrMissingKID
-			}
-
-			var err error
-			secret, err = cache.get(kid)
-			if err != nil {
-				return nil, ErrMissingSecret
-			}
-
-			return []byte(secret.Key), nil
-		})
-		if err != nil || !parsedT.Valid {
-			core.WriteResponse(c, errors.WithCode(code.ErrSignatureInvalid, err.Error()), nil)
+		secret, err := cache.ParseToken(rawJWT)
+		if err != nil {
+			core.WriteResponse(c, err, nil)
 			c.Abort()
 
 			return
 		}
 
-		if KeyExpired(secret.Expires) {
-			tm := time.Unix(secret.Expires, 0).Format("2006-01-02 15:04:05")
-			core.WriteResponse(c, errors.WithCode(code.ErrExpired, "expired at: %s", tm), nil)
-			c.Abort()
+		c.Set(middleware.UsernameKey, secret.Username)
+		c.Next()
+	}
+}
 
-			return
+// ParseToken verifies the raw jwt token with the secret it refers to, and returns the secret.
+// The returned error carries the error code which should be reported to the client.
+func (cache CacheStrategy) ParseToken(rawJWT string) (Secret, error) {
+	// Use own validation logic, see below
+	var secret Secret
+
+	reason := FailureBadSignature
+
+	claims := &jwt.MapClaims{}
+	// Verify the token
+	parsedT, err := jwt.ParseWithClaims(rawJWT, claims, func(token *jwt.Token) (interface{}, error) {
+		// Validate the alg is HMAC signature
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 
-		c.Set(middleware.UsernameKey, secret.Username)
-		c.Next()
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			reason = FailureMissingKID
+
+			return nil, ErrMissingKID
+		}
+
+		var err error
+		secret, err = cache.get(kid)
+		if err != nil {
+			reason = FailureUnknownSecret
+
+			return nil, ErrMissingSecret
+		}
+
+		return []byte(secret.Key), nil
+	})
+	if err != nil || !parsedT.Valid {
+		var vErr *jwt.ValidationError
+		if reason == FailureBadSignature && errors.As(err, &vErr) && vErr.Errors&jwt.ValidationErrorExpired != 0 {
+			reason = FailureExpired
+		}
+		cache.fail(reason)
+
+		return Secret{}, errors.WithCode(code.ErrSignatureInvalid, err.Error())
 	}
+
+	if KeyExpired(secret.Expires) {
+		cache.fail(FailureExpired)
+		tm := time.Unix(secret.Expires, 0).Format("2006-01-02 15:04:05")
+
+		return Secret{}, errors.WithCode(code.ErrExpired, "expired at: %s", tm)
+	}
+
+	return secret, nil
 }
 
 // KeyExpired checks if a key has expired, if the value of user.SessionState.Expires is 0, it will be ignored.
@@ -0,0 +1,65 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+func TestCacheStrategy_ParseToken_failureReason(t *testing.T) {
+	sign := func(kid, key string, exp time.Time) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"exp": exp.Unix()})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, _ := token.SignedString([]byte(key))
+
+		return signed
+	}
+
+	var reason string
+	strategy := NewCacheStrategy(func(kid string) (Secret, error) {
+		switch kid {
+		case "secret-id":
+			return Secret{Username: "colin", ID: kid, Key: "secret-key"}, nil
+		case "expired-id":
+			return Secret{Username: "colin", ID: kid, Key: "secret-key", Expires: time.Now().Add(-time.Hour).Unix()}, nil
+		default:
+			return Secret{}, errors.New("secret not found")
+		}
+	}).WithFailureObserver(func(r string) {
+		reason = r
+	})
+
+	now := time.Now()
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"valid", sign("secret-id", "secret-key", now.Add(time.Hour)), ""},
+		{"missing kid", sign("", "secret-key", now.Add(time.Hour)), FailureMissingKID},
+		{"unknown secret", sign("unknown", "secret-key", now.Add(time.Hour)), FailureUnknownSecret},
+		{"bad signature", sign("secret-id", "other-key", now.Add(time.Hour)), FailureBadSignature},
+		{"token expired", sign("secret-id", "secret-key", now.Add(-time.Hour)), FailureExpired},
+		{"secret expired", sign("expired-id", "secret-key", now.Add(time.Hour)), FailureExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason = ""
+			_, err := strategy.ParseToken(tt.token)
+			if (err != nil) != (tt.want != "") {
+				t.Fatalf("ParseToken() error = %v, want failure %q", err, tt.want)
+			}
+			if reason != tt.want {
+				t.Errorf("ParseToken() failure reason = %q, want %q", reason, tt.want)
+			}
+		})
+	}
+}
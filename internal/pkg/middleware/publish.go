@@ -38,7 +38,7 @@ func Publish() gin.HandlerFunc {
 		method := c.Request.Method
 
 		switch resource {
-		case "policies":
+		case "policies", "grants":
 			notify(c, method, load.NoticePolicyChanged)
 		case "secrets":
 			notify(c, method, load.NoticeSecretChanged)
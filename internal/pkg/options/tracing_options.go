@@ -0,0 +1,124 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// Supported trace exporters.
+const (
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+)
+
+// TracingOptions defines options for OpenTelemetry tracing.
+type TracingOptions struct {
+	Enable      bool    `json:"enable"       mapstructure:"enable"`
+	Exporter    string  `json:"exporter"     mapstructure:"exporter"`
+	Endpoint    string  `json:"endpoint"     mapstructure:"endpoint"`
+	Insecure    bool    `json:"insecure"     mapstructure:"insecure"`
+	SampleRatio float64 `json:"sample-ratio" mapstructure:"sample-ratio"`
+}
+
+// NewTracingOptions create a `zero` value instance.
+func NewTracingOptions() *TracingOptions {
+	return &TracingOptions{
+		Enable:      false,
+		Exporter:    TracingExporterOTLP,
+		Endpoint:    "127.0.0.1:4317",
+		Insecure:    true,
+		SampleRatio: 1,
+	}
+}
+
+// Validate verifies flags passed to TracingOptions.
+func (o *TracingOptions) Validate() []error {
+	errs := []error{}
+
+	if o.Exporter != TracingExporterOTLP && o.Exporter != TracingExporterStdout {
+		errs = append(errs, fmt.Errorf("--tracing.exporter must be one of %s and %s",
+			TracingExporterOTLP, TracingExporterStdout))
+	}
+
+	if o.SampleRatio < 0 || o.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("--tracing.sample-ratio %v must be between 0 and 1", o.SampleRatio))
+	}
+
+	return errs
+}
+
+// AddFlags adds flags related to tracing for a specific server to the specified FlagSet.
+func (o *TracingOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enable, "tracing.enable", o.Enable, ""+
+		"Enable OpenTelemetry tracing.")
+
+	fs.StringVar(&o.Exporter, "tracing.exporter", o.Exporter, ""+
+		"The exporter used to export spans, otlp or stdout. stdout is used for local testing.")
+
+	fs.StringVar(&o.Endpoint, "tracing.endpoint", o.Endpoint, ""+
+		"The address of the OTLP gRPC collector.")
+
+	fs.BoolVar(&o.Insecure, "tracing.insecure", o.Insecure, ""+
+		"Connect to the OTLP gRPC collector without TLS.")
+
+	fs.Float64Var(&o.SampleRatio, "tracing.sample-ratio", o.SampleRatio, ""+
+		"The ratio of traces to sample, traces started by upstream services follow their sampling decision.")
+}
+
+// Install installs a global tracer provider for serviceName, which exports spans with the
+// configured exporter. It returns a function which flushes and stops the provider.
+// The global provider is a no-op one if tracing is disabled.
+func (o *TracingOptions) Install(serviceName string) (func(context.Context) error, error) {
+	if !o.Enable {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	switch o.Exporter {
+	case TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(o.Endpoint)}
+		if o.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+
+		exporter, err = otlptracegrpc.New(context.Background(), opts...)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("create %s trace exporter failed: %w", o.Exporter, err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return tp.Shutdown, nil
+}
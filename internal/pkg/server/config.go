@@ -15,6 +15,7 @@ import (
 	"github.com/marmotedu/component-base/pkg/util/homedir"
 	"github.com/spf13/viper"
 
+	"github.com/marmotedu/iam/internal/pkg/server/healthz"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
@@ -115,6 +116,8 @@ func (c CompletedConfig) New() (*GenericAPIServer, error) {
 		enableMetrics:       c.EnableMetrics,
 		enableProfiling:     c.EnableProfiling,
 		middlewares:         c.Middlewares,
+		livezChecks:         healthz.NewChecks(healthz.PingHealthz),
+		readyzChecks:        healthz.NewChecks(healthz.PingHealthz),
 		Engine:              gin.New(),
 	}
 
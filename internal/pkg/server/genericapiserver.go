@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-contrib/pprof"
@@ -20,6 +21,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/marmotedu/iam/internal/pkg/middleware"
+	"github.com/marmotedu/iam/internal/pkg/server/healthz"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
@@ -39,6 +41,9 @@ type GenericAPIServer struct {
 
 	*gin.Engine
 	healthz         bool
+	healthzStatus   sync.Map
+	livezChecks     *healthz.Checks
+	readyzChecks    *healthz.Checks
 	enableMetrics   bool
 	enableProfiling bool
 	// wrapper for gin.Engine
@@ -55,13 +60,37 @@ func initGenericAPIServer(s *GenericAPIServer) {
 	s.InstallAPIs()
 }
 
+// AddHealthzStatus adds the status of a component to the /healthz output under the given name.
+func (s *GenericAPIServer) AddHealthzStatus(name string, status func() interface{}) {
+	s.healthzStatus.Store(name, status)
+}
+
+// AddLivezChecks adds checks to /livez. A failed liveness check means the process should be restarted.
+func (s *GenericAPIServer) AddLivezChecks(checks ...healthz.Checker) {
+	s.livezChecks.Add(checks...)
+}
+
+// AddReadyzChecks adds checks to /readyz. A failed readiness check means the process should not receive traffic.
+func (s *GenericAPIServer) AddReadyzChecks(checks ...healthz.Checker) {
+	s.readyzChecks.Add(checks...)
+}
+
 // InstallAPIs install generic apis.
 func (s *GenericAPIServer) InstallAPIs() {
 	// install healthz handler
 	if s.healthz {
 		s.GET("/healthz", func(c *gin.Context) {
-			core.WriteResponse(c, nil, map[string]string{"status": "ok"})
+			status := map[string]interface{}{"status": "ok"}
+			s.healthzStatus.Range(func(name, fn interface{}) bool {
+				status[name.(string)] = fn.(func() interface{})()
+
+				return true
+			})
+
+			core.WriteResponse(c, nil, status)
 		})
+		s.GET("/livez", gin.WrapF(s.livezChecks.Handler("livez")))
+		s.GET("/readyz", gin.WrapF(s.readyzChecks.Handler("readyz")))
 	}
 
 	// install metric handler
@@ -91,6 +120,7 @@ func (s *GenericAPIServer) Setup() {
 func (s *GenericAPIServer) InstallMiddlewares() {
 	// necessary middlewares
 	s.Use(middleware.RequestID())
+	s.Use(middleware.Tracing())
 	s.Use(middleware.Context())
 
 	// install custom middlewares
@@ -8,16 +8,20 @@ package server
 import (
 	"net/http"
 
+	"github.com/marmotedu/iam/internal/pkg/server/healthz"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
 // ServeHealthCheck runs a http server used to provide a api to check pump health status.
-func ServeHealthCheck(healthPath string, healthAddress string) {
+// The given checks are served by /readyz, /livez only reports the process is running.
+func ServeHealthCheck(healthPath string, healthAddress string, readyzChecks ...healthz.Checker) {
 	http.HandleFunc("/"+healthPath, func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte(`{"status": "ok"}`))
 	})
+	http.HandleFunc("/livez", healthz.NewChecks(healthz.PingHealthz).Handler("livez"))
+	http.HandleFunc("/readyz", healthz.NewChecks(append([]healthz.Checker{healthz.PingHealthz}, readyzChecks...)...).Handler("readyz"))
 
 	if err := http.ListenAndServe(healthAddress, nil); err != nil {
 		log.Fatalf("Error serving health check endpoint: %s", err.Error())
@@ -0,0 +1,10 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package healthz implements the /livez and /readyz endpoints with pluggable named checks.
+//
+// Both endpoints return `ok` when all the checks pass and 500 with the result of every
+// check otherwise. `?verbose` lists the result of every check and `?exclude=<name>`
+// skips a check, e.g. `/readyz?verbose&exclude=redis`.
+package healthz // import "github.com/marmotedu/iam/internal/pkg/server/healthz"
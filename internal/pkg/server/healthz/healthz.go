@@ -0,0 +1,166 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package healthz
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// Checker is a named health check.
+type Checker interface {
+	Name() string
+	Check(r *http.Request) error
+}
+
+// Pinger is implemented by dependencies which can be pinged.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type namedCheck struct {
+	name  string
+	check func(r *http.Request) error
+}
+
+func (c *namedCheck) Name() string {
+	return c.name
+}
+
+func (c *namedCheck) Check(r *http.Request) error {
+	return c.check(r)
+}
+
+// NamedCheck returns a checker for the given name and check function.
+func NamedCheck(name string, check func(r *http.Request) error) Checker {
+	return &namedCheck{name: name, check: check}
+}
+
+// PingHealthz returns true automatically when checked.
+var PingHealthz Checker = NamedCheck("ping", func(*http.Request) error {
+	return nil
+})
+
+// PingCheck returns a checker which pings the given dependency.
+func PingCheck(name string, pinger Pinger) Checker {
+	return NamedCheck(name, func(r *http.Request) error {
+		return pinger.Ping(r.Context())
+	})
+}
+
+// PingStatus returns a /healthz status function which pings the given dependency. It is used for
+// dependencies whose failure is reported but must not make the server unready.
+func PingStatus(pinger Pinger, timeout time.Duration) func() interface{} {
+	return func() interface{} {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		if err := pinger.Ping(ctx); err != nil {
+			return map[string]string{"status": "failed", "error": err.Error()}
+		}
+
+		return map[string]string{"status": "ok"}
+	}
+}
+
+// RedisCheck returns a checker which reports whether the redis connection maintained by
+// `storage.ConnectToRedis` is up.
+func RedisCheck() Checker {
+	return NamedCheck("redis", func(*http.Request) error {
+		if !storage.Connected() {
+			return errors.New("redis is not connected")
+		}
+
+		return nil
+	})
+}
+
+// Checks is a list of checks which can be extended after the handler is installed.
+type Checks struct {
+	lock   sync.RWMutex
+	checks []Checker
+}
+
+// NewChecks creates a list of checks.
+func NewChecks(checks ...Checker) *Checks {
+	return &Checks{checks: checks}
+}
+
+// Add adds checks to the list.
+func (c *Checks) Add(checks ...Checker) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.checks = append(c.checks, checks...)
+}
+
+func (c *Checks) list() []Checker {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return append([]Checker(nil), c.checks...)
+}
+
+// Handler returns a http handler which runs all the checks, name is used in the output.
+func (c *Checks) Handler(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		excluded := make(map[string]struct{})
+		for _, e := range r.URL.Query()["exclude"] {
+			for _, n := range strings.Split(e, ",") {
+				excluded[strings.TrimSpace(n)] = struct{}{}
+			}
+		}
+
+		var (
+			out    bytes.Buffer
+			failed []string
+		)
+
+		for _, check := range c.list() {
+			if _, ok := excluded[check.Name()]; ok {
+				fmt.Fprintf(&out, "[+]%s excluded: ok\n", check.Name())
+
+				continue
+			}
+
+			if err := check.Check(r); err != nil {
+				fmt.Fprintf(&out, "[-]%s failed: %s\n", check.Name(), err.Error())
+				failed = append(failed, check.Name())
+
+				continue
+			}
+
+			fmt.Fprintf(&out, "[+]%s ok\n", check.Name())
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		if len(failed) > 0 {
+			log.Warnf("%s check failed: %s", name, strings.Join(failed, ","))
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "%s%s check failed\n", out.String(), name)
+
+			return
+		}
+
+		if _, verbose := r.URL.Query()["verbose"]; !verbose {
+			fmt.Fprint(w, "ok")
+
+			return
+		}
+
+		fmt.Fprintf(w, "%s%s check passed\n", out.String(), name)
+	}
+}
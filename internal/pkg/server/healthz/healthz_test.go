@@ -0,0 +1,58 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package healthz
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChecks_Handler(t *testing.T) {
+	checks := NewChecks(PingHealthz)
+	checks.Add(NamedCheck("cache", func(*http.Request) error {
+		return errors.New("not loaded")
+	}))
+
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "failed",
+			url:      "/readyz",
+			wantCode: http.StatusInternalServerError,
+			wantBody: "[+]ping ok\n[-]cache failed: not loaded\nreadyz check failed\n",
+		},
+		{
+			name:     "excluded",
+			url:      "/readyz?exclude=cache",
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+		},
+		{
+			name:     "verbose",
+			url:      "/readyz?verbose&exclude=cache",
+			wantCode: http.StatusOK,
+			wantBody: "[+]ping ok\n[+]cache excluded: ok\nreadyz check passed\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			checks.Handler("readyz")(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Handler() code = %v, want %v", w.Code, tt.wantCode)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("Handler() body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package tracing defines helpers to create OpenTelemetry spans in iam services.
+// The tracer provider is installed by `options.TracingOptions.Install`.
+package tracing // import "github.com/marmotedu/iam/internal/pkg/tracing"
@@ -0,0 +1,43 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracer used by iam services.
+const InstrumentationName = "github.com/marmotedu/iam"
+
+// Start creates a span and a context containing the newly-created span.
+//
+// gin.Context does not expose the spans of its request context, so if ctx is a *gin.Context
+// carrying no span, the span of its request is used as the parent. The returned context
+// still wraps ctx, so that the values of ctx are kept.
+func Start(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	parent := ctx
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil && !trace.SpanContextFromContext(ctx).IsValid() {
+		parent = c.Request.Context()
+	}
+
+	_, span := otel.Tracer(InstrumentationName).Start(parent, spanName, opts...)
+
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// End records err on span if it is not nil and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package tracing
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStart(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		_ = tp.Shutdown(context.Background())
+	})
+
+	reqCtx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	defer parent.End()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil).WithContext(reqCtx)
+	c.Set("requestID", "id")
+
+	ctx, span := Start(c, "store")
+	defer span.End()
+
+	if got, want := span.SpanContext().TraceID(), parent.SpanContext().TraceID(); got != want {
+		t.Errorf("Start() trace id = %v, want %v", got, want)
+	}
+	if got := ctx.Value("requestID"); got != "id" {
+		t.Errorf("Start() context lost value of gin context, got %v", got)
+	}
+	if got := trace.SpanFromContext(ctx); got != span {
+		t.Errorf("Start() context does not carry the new span")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pump
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/marmotedu/component-base/pkg/json"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// serveAdmin serves the admin API on address:
+//
+//	GET  /status               status of the input and of the pumps
+//	POST /pumps/<key>/pause    stop writing to the pump, its records are dead-lettered,
+//	                           requires dead-letter.dir
+//	POST /pumps/<key>/resume   write to the pump again
+//	POST /purge                purge the input now, without waiting for purge-delay
+func (s *pumpServer) serveAdmin(address string) {
+	log.Infof("Serving the admin API on %s", address)
+
+	if err := http.ListenAndServe(address, s.adminHandler()); err != nil {
+		log.Fatalf("Error serving the admin API: %s", err.Error())
+	}
+}
+
+func (s *pumpServer) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeAdminError(w, http.StatusMethodNotAllowed, "method %s is not allowed", r.Method)
+
+			return
+		}
+
+		writeAdminResponse(w, http.StatusOK, s.status())
+	})
+
+	mux.HandleFunc("/pumps/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeAdminError(w, http.StatusMethodNotAllowed, "method %s is not allowed", r.Method)
+
+			return
+		}
+
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/pumps/"), "/")
+		if len(parts) != 2 || (parts[1] != "pause" && parts[1] != "resume") {
+			writeAdminError(w, http.StatusNotFound, "unknown path %s", r.URL.Path)
+
+			return
+		}
+
+		pmp := findPump(parts[0])
+		if pmp == nil {
+			writeAdminError(w, http.StatusNotFound, "pump %s is not initialized", parts[0])
+
+			return
+		}
+
+		paused := parts[1] == "pause"
+		// the records of a paused pump are kept in the dead-letter queue, they would be lost without it.
+		if paused && s.deadLetters == nil {
+			writeAdminError(w, http.StatusConflict, "pump %s can not be paused without dead-letter.dir", pmp.key)
+
+			return
+		}
+
+		pmp.stats.setPaused(paused)
+		log.Infof("Pump %s is %sd by the admin API", pmp.key, parts[1])
+
+		writeAdminResponse(w, http.StatusOK, pmp.stats.status(pmp.key, pmp.GetName()))
+	})
+
+	mux.HandleFunc("/purge", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeAdminError(w, http.StatusMethodNotAllowed, "method %s is not allowed", r.Method)
+
+			return
+		}
+
+		// a purge already requested covers this one.
+		select {
+		case s.purgeCh <- struct{}{}:
+		default:
+		}
+
+		writeAdminResponse(w, http.StatusAccepted, map[string]string{"message": "purge is triggered"})
+	})
+
+	return mux
+}
+
+// findPump returns the initialized pump of key.
+func findPump(key string) *configuredPump {
+	for _, pmp := range pmps {
+		if pmp != nil && pmp.key == key {
+			return pmp
+		}
+	}
+
+	return nil
+}
+
+func writeAdminResponse(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Warnf("Failed to write the admin API response: %s", err.Error())
+	}
+}
+
+func writeAdminError(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	writeAdminResponse(w, code, map[string]string{"message": fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pump
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marmotedu/component-base/pkg/json"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+	"github.com/marmotedu/iam/internal/pump/deadletter"
+)
+
+// fakeStorage is an input holding backlog records.
+type fakeStorage struct {
+	backlog int64
+}
+
+func (f *fakeStorage) Init(config interface{}) error { return nil }
+func (f *fakeStorage) GetName() string               { return "fake" }
+func (f *fakeStorage) Connect() bool                 { return true }
+
+func (f *fakeStorage) GetSet(string) ([]interface{}, func() error) {
+	return nil, func() error { return nil }
+}
+
+func (f *fakeStorage) Len(keyName string) (int64, error) { return f.backlog, nil }
+
+func TestAdminHandler(t *testing.T) {
+	flaky := &configuredPump{Pump: &flakyPump{failures: 1}, key: "flaky"}
+	defer func(saved []*configuredPump) { pmps = saved }(pmps)
+	pmps = []*configuredPump{flaky, nil}
+
+	s := &pumpServer{
+		analyticsStore: &fakeStorage{backlog: 3},
+		deadLetters:    deadletter.NewQueue(t.TempDir()),
+		initErrors:     map[string]string{"broken": "init failed"},
+		purgeCh:        make(chan struct{}, 1),
+	}
+	handler := s.adminHandler()
+
+	keys := []interface{}{analytics.AnalyticsRecord{Username: "colin"}}
+	_ = deliver(context.Background(), flaky, keys, 10, nil)
+	_ = deliver(context.Background(), flaky, keys, 10, nil)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+	}{
+		{name: "status", method: http.MethodGet, path: "/status", wantCode: http.StatusOK},
+		{name: "status method", method: http.MethodPost, path: "/status", wantCode: http.StatusMethodNotAllowed},
+		{name: "pause", method: http.MethodPost, path: "/pumps/flaky/pause", wantCode: http.StatusOK},
+		{name: "resume", method: http.MethodPost, path: "/pumps/flaky/resume", wantCode: http.StatusOK},
+		{name: "failed pump", method: http.MethodPost, path: "/pumps/broken/pause", wantCode: http.StatusNotFound},
+		{name: "unknown action", method: http.MethodPost, path: "/pumps/flaky/stop", wantCode: http.StatusNotFound},
+		{name: "purge", method: http.MethodPost, path: "/purge", wantCode: http.StatusAccepted},
+		{name: "purge again", method: http.MethodPost, path: "/purge", wantCode: http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, nil))
+			if recorder.Code != tt.wantCode {
+				t.Errorf("%s %s status = %d, want %d: %s", tt.method, tt.path, recorder.Code, tt.wantCode, recorder.Body)
+			}
+		})
+	}
+
+	if len(s.purgeCh) != 1 {
+		t.Errorf("purges triggered = %d, want 1", len(s.purgeCh))
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/pumps/flaky/pause", nil))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var status serverStatus
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if status.Input != "fake" || status.Backlog == nil || *status.Backlog != 3 || len(status.Pumps) != 2 {
+		t.Fatalf("status = %+v", status)
+	}
+
+	broken, flakyStatus := status.Pumps[0], status.Pumps[1]
+	if broken.Key != "broken" || broken.State != pumpStateFailed || broken.LastError != "init failed" {
+		t.Errorf("status of broken = %+v", broken)
+	}
+	if flakyStatus.State != pumpStatePaused || flakyStatus.RecordsWritten != 1 || flakyStatus.RecordsFailed != 1 ||
+		flakyStatus.LastError != "unavailable" || flakyStatus.LastSuccessfulWrite == nil || flakyStatus.AverageLatency == "" {
+		t.Errorf("status of flaky = %+v", flakyStatus)
+	}
+}
+
+func TestAdminHandler_PauseWithoutDeadLetters(t *testing.T) {
+	flaky := &configuredPump{Pump: &flakyPump{}, key: "flaky"}
+	defer func(saved []*configuredPump) { pmps = saved }(pmps)
+	pmps = []*configuredPump{flaky}
+
+	s := &pumpServer{analyticsStore: &fakeStorage{}, purgeCh: make(chan struct{}, 1)}
+
+	recorder := httptest.NewRecorder()
+	s.adminHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/pumps/flaky/pause", nil))
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("POST /pumps/flaky/pause status = %d, want %d: %s", recorder.Code, http.StatusConflict, recorder.Body)
+	}
+	if flaky.stats.isPaused() {
+		t.Error("pump is paused without a dead-letter queue")
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/ory/ladon"
+)
+
+// Granularities of the aggregate time buckets, the buckets are aligned to UTC.
+const (
+	GranularityMinute = "minute"
+	GranularityHour   = "hour"
+	GranularityDay    = "day"
+)
+
+// AnalyticsRecordAggregate counts the authorization decisions of a time bucket with the same
+// username, effect, action and resource.
+type AnalyticsRecordAggregate struct {
+	// Bucket is the start of the time bucket, in unix seconds.
+	Bucket   int64  `json:"bucket"   bson:"bucket"`
+	Username string `json:"username" bson:"username"`
+	Effect   string `json:"effect"   bson:"effect"`
+	Action   string `json:"action"   bson:"action"`
+	Resource string `json:"resource" bson:"resource"`
+	Count    int64  `json:"count"    bson:"count"`
+}
+
+// BucketSize returns the size of the time buckets of the granularity.
+func BucketSize(granularity string) (time.Duration, error) {
+	switch granularity {
+	case GranularityMinute:
+		return time.Minute, nil
+	case GranularityHour:
+		return time.Hour, nil
+	case GranularityDay:
+		return 24 * time.Hour, nil
+	}
+
+	return 0, fmt.Errorf("unknown granularity %q, must be one of %s, %s and %s",
+		granularity, GranularityMinute, GranularityHour, GranularityDay)
+}
+
+// AggregateRecords rolls up the records into buckets of bucketSize. The aggregates are sorted
+// by bucket, username, effect, action and resource.
+func AggregateRecords(records []AnalyticsRecord, bucketSize time.Duration) []*AnalyticsRecordAggregate {
+	seconds := int64(bucketSize / time.Second)
+	aggregates := make(map[AnalyticsRecordAggregate]*AnalyticsRecordAggregate)
+
+	for _, record := range records {
+		var request ladon.Request
+		_ = json.Unmarshal([]byte(record.Request), &request)
+
+		key := AnalyticsRecordAggregate{
+			Bucket:   record.TimeStamp - record.TimeStamp%seconds,
+			Username: record.Username,
+			Effect:   record.Effect,
+			Action:   request.Action,
+			Resource: request.Resource,
+		}
+
+		aggregate, ok := aggregates[key]
+		if !ok {
+			aggregate = &AnalyticsRecordAggregate{}
+			*aggregate = key
+			aggregates[key] = aggregate
+		}
+		aggregate.Count++
+	}
+
+	result := make([]*AnalyticsRecordAggregate, 0, len(aggregates))
+	for _, aggregate := range aggregates {
+		result = append(result, aggregate)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Bucket != b.Bucket {
+			return a.Bucket < b.Bucket
+		}
+		if a.Username != b.Username {
+			return a.Username < b.Username
+		}
+		if a.Effect != b.Effect {
+			return a.Effect < b.Effect
+		}
+		if a.Action != b.Action {
+			return a.Action < b.Action
+		}
+
+		return a.Resource < b.Resource
+	})
+
+	return result
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAggregateRecords(t *testing.T) {
+	request := `{"action":"delete","resource":"resources:articles:ladon"}`
+	records := []AnalyticsRecord{
+		{TimeStamp: 3600 + 10, Username: "colin", Effect: "allow", Request: request},
+		{TimeStamp: 3600 + 70, Username: "colin", Effect: "allow", Request: request},
+		{TimeStamp: 3600 + 80, Username: "colin", Effect: "deny", Request: request},
+		{TimeStamp: 7200 + 10, Username: "colin", Effect: "allow", Request: request},
+		{TimeStamp: 3600 + 20, Username: "james", Effect: "deny", Request: "invalid"},
+	}
+
+	tests := []struct {
+		name       string
+		bucketSize time.Duration
+		want       []*AnalyticsRecordAggregate
+	}{
+		{
+			name:       "hour",
+			bucketSize: time.Hour,
+			want: []*AnalyticsRecordAggregate{
+				{Bucket: 3600, Username: "colin", Effect: "allow", Action: "delete", Resource: "resources:articles:ladon", Count: 2},
+				{Bucket: 3600, Username: "colin", Effect: "deny", Action: "delete", Resource: "resources:articles:ladon", Count: 1},
+				{Bucket: 3600, Username: "james", Effect: "deny", Count: 1},
+				{Bucket: 7200, Username: "colin", Effect: "allow", Action: "delete", Resource: "resources:articles:ladon", Count: 1},
+			},
+		},
+		{
+			name:       "day",
+			bucketSize: 24 * time.Hour,
+			want: []*AnalyticsRecordAggregate{
+				{Bucket: 0, Username: "colin", Effect: "allow", Action: "delete", Resource: "resources:articles:ladon", Count: 3},
+				{Bucket: 0, Username: "colin", Effect: "deny", Action: "delete", Resource: "resources:articles:ladon", Count: 1},
+				{Bucket: 0, Username: "james", Effect: "deny", Count: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AggregateRecords(records, tt.bucketSize); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AggregateRecords() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,10 +4,46 @@
 
 package analytics
 
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/component-base/pkg/json"
+)
+
 // AnalyticsFilters defines the analytics options.
+// A record is written to a pump only if it passes all the configured filters.
 type AnalyticsFilters struct {
-	Usernames        []string `json:"usernames"`
-	SkippedUsernames []string `json:"skip_usernames"`
+	Usernames        []string `json:"usernames"      mapstructure:"usernames"`
+	SkippedUsernames []string `json:"skip_usernames" mapstructure:"skip_usernames"`
+	// Effects keeps the records with one of the effects, allow or deny.
+	Effects []string `json:"effects"        mapstructure:"effects"`
+	// From and To keep the records authorized in [From, To), in RFC3339 format.
+	From string `json:"from"           mapstructure:"from"`
+	To   string `json:"to"             mapstructure:"to"`
+	// Match keeps the records matched by the rule.
+	Match *FilterRule `json:"match"          mapstructure:"match"`
+}
+
+// FilterRule matches a record. It is either a field rule which matches the value of Field against
+// Glob or Regex, or a combination of the rules in All or Any. Not negates the result.
+//
+// Field is one of username, effect, conclusion, or a field of the authorization request:
+// action, resource, subject and context.<key>.
+//
+// Glob is matched with path.Match, so `*` and `?` do not match `/`: resources:articles:* matches
+// resources:articles:ladon but not resources:articles:ladon/comments. Use Regex to match across `/`.
+type FilterRule struct {
+	Field string       `json:"field,omitempty" mapstructure:"field"`
+	Glob  string       `json:"glob,omitempty"  mapstructure:"glob"`
+	Regex string       `json:"regex,omitempty" mapstructure:"regex"`
+	All   []FilterRule `json:"all,omitempty"   mapstructure:"all"`
+	Any   []FilterRule `json:"any,omitempty"   mapstructure:"any"`
+	Not   bool         `json:"not,omitempty"   mapstructure:"not"`
 }
 
 // ShouldFilter determine whether a record should to be filtered out.
@@ -17,6 +53,12 @@ func (filters AnalyticsFilters) ShouldFilter(record AnalyticsRecord) bool {
 		return true
 	case len(filters.Usernames) > 0 && !stringInSlice(record.Username, filters.Usernames):
 		return true
+	case len(filters.Effects) > 0 && !stringInSlice(record.Effect, filters.Effects):
+		return true
+	case !filters.inTimeRange(record.TimeStamp):
+		return true
+	case filters.Match != nil && !filters.Match.matches(&recordFields{record: record}):
+		return true
 	}
 
 	return false
@@ -24,13 +66,208 @@ func (filters AnalyticsFilters) ShouldFilter(record AnalyticsRecord) bool {
 
 // HasFilter determine whether a record has a filter.
 func (filters AnalyticsFilters) HasFilter() bool {
-	if len(filters.SkippedUsernames) == 0 && len(filters.Usernames) == 0 {
+	if len(filters.SkippedUsernames) == 0 && len(filters.Usernames) == 0 && len(filters.Effects) == 0 &&
+		filters.From == "" && filters.To == "" && filters.Match == nil {
+		return false
+	}
+
+	return true
+}
+
+// Validate checks the filters, prefix is used to locate the filters in the errors.
+func (filters AnalyticsFilters) Validate(prefix string) []error {
+	errs := []error{}
+
+	for _, effect := range filters.Effects {
+		if effect != "allow" && effect != "deny" {
+			errs = append(errs, fmt.Errorf("%s.effects: unknown effect %q, must be allow or deny", prefix, effect))
+		}
+	}
+
+	for name, value := range map[string]string{"from": filters.From, "to": filters.To} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s: %w", prefix, name, err))
+		}
+	}
+
+	if filters.Match != nil {
+		errs = append(errs, filters.Match.validate(prefix+".match")...)
+	}
+
+	return errs
+}
+
+func (filters AnalyticsFilters) inTimeRange(timestamp int64) bool {
+	authorizedAt := time.Unix(timestamp, 0)
+
+	// the times are checked by Validate, invalid ones are ignored.
+	if from, err := time.Parse(time.RFC3339, filters.From); err == nil && authorizedAt.Before(from) {
+		return false
+	}
+
+	if to, err := time.Parse(time.RFC3339, filters.To); err == nil && !authorizedAt.Before(to) {
 		return false
 	}
 
 	return true
 }
 
+func (r *FilterRule) validate(prefix string) []error {
+	errs := []error{}
+
+	kinds := 0
+	if r.Field != "" {
+		kinds++
+	}
+	if len(r.All) > 0 {
+		kinds++
+	}
+	if len(r.Any) > 0 {
+		kinds++
+	}
+	if kinds != 1 {
+		return append(errs, fmt.Errorf("%s: exactly one of field, all and any must be set", prefix))
+	}
+
+	if r.Field != "" {
+		if !isFilterField(r.Field) {
+			errs = append(errs, fmt.Errorf("%s.field: unknown field %q, must be one of username, effect, "+
+				"conclusion, action, resource, subject and context.<key>", prefix, r.Field))
+		}
+		if (r.Glob == "") == (r.Regex == "") {
+			errs = append(errs, fmt.Errorf("%s: exactly one of glob and regex must be set", prefix))
+		}
+		if _, err := path.Match(r.Glob, ""); err != nil {
+			errs = append(errs, fmt.Errorf("%s.glob: %w", prefix, err))
+		}
+		if r.Regex != "" {
+			if _, err := compileRegex(r.Regex); err != nil {
+				errs = append(errs, fmt.Errorf("%s.regex: %w", prefix, err))
+			}
+		}
+	}
+
+	for i := range r.All {
+		errs = append(errs, r.All[i].validate(fmt.Sprintf("%s.all[%d]", prefix, i))...)
+	}
+	for i := range r.Any {
+		errs = append(errs, r.Any[i].validate(fmt.Sprintf("%s.any[%d]", prefix, i))...)
+	}
+
+	return errs
+}
+
+// isFilterField returns true if field is resolved by recordFields.
+func isFilterField(field string) bool {
+	switch field {
+	case "username", "effect", "conclusion", "action", "resource", "subject":
+		return true
+	}
+
+	key := strings.TrimPrefix(field, "context.")
+
+	return key != field && key != ""
+}
+
+func (r *FilterRule) matches(fields *recordFields) bool {
+	var matched bool
+
+	switch {
+	case len(r.All) > 0:
+		matched = true
+		for i := range r.All {
+			if !r.All[i].matches(fields) {
+				matched = false
+
+				break
+			}
+		}
+	case len(r.Any) > 0:
+		for i := range r.Any {
+			if r.Any[i].matches(fields) {
+				matched = true
+
+				break
+			}
+		}
+	default:
+		matched = r.matchesValue(fields.get(r.Field))
+	}
+
+	return matched != r.Not
+}
+
+func (r *FilterRule) matchesValue(value string) bool {
+	if r.Regex != "" {
+		re, err := compileRegex(r.Regex)
+
+		return err == nil && re.MatchString(value)
+	}
+
+	matched, _ := path.Match(r.Glob, value)
+
+	return matched
+}
+
+// regexes caches the compiled regular expressions of the filter rules.
+var regexes sync.Map
+
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexes.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexes.Store(expr, re)
+
+	return re, nil
+}
+
+// recordFields resolves the fields of a record for filter rules. The JSON request is only
+// decoded if a rule refers to it.
+type recordFields struct {
+	record  AnalyticsRecord
+	request map[string]interface{}
+	decoded bool
+}
+
+func (f *recordFields) get(field string) string {
+	switch field {
+	case "username":
+		return f.record.Username
+	case "effect":
+		return f.record.Effect
+	case "conclusion":
+		return f.record.Conclusion
+	}
+
+	if !f.decoded {
+		f.decoded = true
+		_ = json.Unmarshal([]byte(f.record.Request), &f.request)
+	}
+
+	var value interface{}
+	if key := strings.TrimPrefix(field, "context."); key != field {
+		if ctx, ok := f.request["context"].(map[string]interface{}); ok {
+			value = ctx[key]
+		}
+	} else {
+		value = f.request[field]
+	}
+
+	if value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
 func stringInSlice(a string, list []string) bool {
 	for _, b := range list {
 		if b == a {
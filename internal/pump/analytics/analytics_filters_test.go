@@ -53,3 +53,114 @@ func TestHasFilter(t *testing.T) {
 		t.Fatal("HasFilter should be true.")
 	}
 }
+
+func TestShouldFilter_Rules(t *testing.T) {
+	record := AnalyticsRecord{
+		TimeStamp: 1609459200, // 2021-01-01T00:00:00Z
+		Username:  "colin",
+		Effect:    "deny",
+		Request: `{"subject":"users:peter","action":"delete","resource":"resources:articles:ladon",` +
+			`"context":{"remoteIP":"10.0.0.1","username":"colin","path":"/v1/articles/ladon"}}`,
+	}
+
+	tests := []struct {
+		name    string
+		filters AnalyticsFilters
+		want    bool
+	}{
+		{
+			name:    "effect kept",
+			filters: AnalyticsFilters{Effects: []string{"deny"}},
+			want:    false,
+		},
+		{
+			name:    "effect filtered",
+			filters: AnalyticsFilters{Effects: []string{"allow"}},
+			want:    true,
+		},
+		{
+			name:    "in time range",
+			filters: AnalyticsFilters{From: "2020-12-31T00:00:00Z", To: "2021-01-02T00:00:00Z"},
+			want:    false,
+		},
+		{
+			name:    "after time range",
+			filters: AnalyticsFilters{To: "2021-01-01T00:00:00Z"},
+			want:    true,
+		},
+		{
+			name:    "resource glob",
+			filters: AnalyticsFilters{Match: &FilterRule{Field: "resource", Glob: "resources:articles:*"}},
+			want:    false,
+		},
+		{
+			name:    "resource glob mismatch",
+			filters: AnalyticsFilters{Match: &FilterRule{Field: "resource", Glob: "resources:users:*"}},
+			want:    true,
+		},
+		{
+			name:    "context regex",
+			filters: AnalyticsFilters{Match: &FilterRule{Field: "context.remoteIP", Regex: `^10\.`}},
+			want:    false,
+		},
+		{
+			name:    "glob matches across colon",
+			filters: AnalyticsFilters{Match: &FilterRule{Field: "resource", Glob: "resources:*"}},
+			want:    false,
+		},
+		{
+			name:    "glob does not match across slash",
+			filters: AnalyticsFilters{Match: &FilterRule{Field: "context.path", Glob: "/v1/*"}},
+			want:    true,
+		},
+		{
+			name: "all with not",
+			filters: AnalyticsFilters{Match: &FilterRule{All: []FilterRule{
+				{Field: "action", Glob: "delete"},
+				{Field: "subject", Glob: "users:peter", Not: true},
+			}}},
+			want: true,
+		},
+		{
+			name: "any",
+			filters: AnalyticsFilters{Match: &FilterRule{Any: []FilterRule{
+				{Field: "action", Glob: "get"},
+				{Field: "context.username", Regex: "^col"},
+			}}},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.filters.HasFilter() {
+				t.Fatal("HasFilter() = false")
+			}
+
+			if got := tt.filters.ShouldFilter(record); got != tt.want {
+				t.Errorf("ShouldFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	filters := AnalyticsFilters{
+		Effects: []string{"maybe"},
+		From:    "yesterday",
+		Match: &FilterRule{Any: []FilterRule{
+			{Field: "action"},
+			{Field: "resource", Regex: "("},
+			{Field: "subject", Glob: "users:*", All: []FilterRule{{Field: "action", Glob: "get"}}},
+			{Field: "actions", Glob: "get"},
+			{Field: "context.", Glob: "*"},
+		}},
+	}
+
+	if errs := filters.Validate("filters"); len(errs) != 7 {
+		t.Errorf("Validate() = %v, want 7 errors", errs)
+	}
+
+	if errs := (AnalyticsFilters{}).Validate("filters"); len(errs) != 0 {
+		t.Errorf("Validate() = %v, want no errors", errs)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/marmotedu/component-base/pkg/json"
+)
+
+// AnalyticsTransforms defines the transformations of the authorization request of a record,
+// applied before the record is written to a pump. The fields of the request are referred to by
+// dot separated paths, like context.remoteIP.
+//
+// The transformations are applied in order: drop, hash, rename, add, timestamps and truncate.
+// If drop or hash is set and the request is not a JSON object, the request is blanked.
+type AnalyticsTransforms struct {
+	// Drop removes the fields.
+	Drop []string `json:"drop"             mapstructure:"drop"`
+	// Hash replaces the values of the fields with their hex encoded SHA-256, salted by HashSalt.
+	Hash     []string `json:"hash"             mapstructure:"hash"`
+	HashSalt string   `json:"hash_salt"        mapstructure:"hash_salt"`
+	// Rename moves the fields to new paths.
+	Rename []RenameTransform `json:"rename"           mapstructure:"rename"`
+	// Add sets fields to static values.
+	Add []AddTransform `json:"add"              mapstructure:"add"`
+	// Timestamps converts the unix seconds of the fields to TimestampFormat.
+	Timestamps []string `json:"timestamps"       mapstructure:"timestamps"`
+	// TimestampFormat is a time layout, RFC3339 by default.
+	TimestampFormat string `json:"timestamp_format" mapstructure:"timestamp_format"`
+	// TruncateLength truncates the strings longer than it, in bytes, 0 disables it.
+	TruncateLength int `json:"truncate_length"  mapstructure:"truncate_length"`
+}
+
+// RenameTransform moves the field at From to To.
+// The paths are not map keys, as the config keys are case insensitive and split on dots.
+type RenameTransform struct {
+	From string `json:"from" mapstructure:"from"`
+	To   string `json:"to"   mapstructure:"to"`
+}
+
+// AddTransform sets the field at Path to Value.
+type AddTransform struct {
+	Path  string      `json:"path"  mapstructure:"path"`
+	Value interface{} `json:"value" mapstructure:"value"`
+}
+
+// HasTransforms determine whether any transformation is configured.
+func (t AnalyticsTransforms) HasTransforms() bool {
+	return len(t.Drop) > 0 || len(t.Hash) > 0 || len(t.Rename) > 0 || len(t.Add) > 0 ||
+		len(t.Timestamps) > 0 || t.TruncateLength > 0
+}
+
+// Validate checks the transforms, prefix is used to locate the transforms in the errors.
+func (t AnalyticsTransforms) Validate(prefix string) []error {
+	errs := []error{}
+
+	check := func(name string, paths ...string) {
+		for _, path := range paths {
+			if path == "" || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") || strings.Contains(path, "..") {
+				errs = append(errs, fmt.Errorf("%s.%s: invalid path %q", prefix, name, path))
+			}
+		}
+	}
+
+	check("drop", t.Drop...)
+	check("hash", t.Hash...)
+	check("timestamps", t.Timestamps...)
+	for _, rename := range t.Rename {
+		check("rename", rename.From, rename.To)
+	}
+	for _, add := range t.Add {
+		check("add", add.Path)
+	}
+
+	if t.TruncateLength < 0 {
+		errs = append(errs, fmt.Errorf("%s.truncate_length: must not be negative", prefix))
+	}
+
+	return errs
+}
+
+// Transform returns the record with the transformed request.
+func (t AnalyticsTransforms) Transform(record AnalyticsRecord) AnalyticsRecord {
+	if !t.HasTransforms() {
+		return record
+	}
+
+	request, err := DecodeRequest(record.Request)
+	if err != nil {
+		if len(t.Drop) > 0 || len(t.Hash) > 0 {
+			// the fields to remove can't be found, don't leak them.
+			record.Request = ""
+		}
+
+		return record
+	}
+
+	for _, path := range t.Drop {
+		deletePath(request, path)
+	}
+
+	for _, path := range t.Hash {
+		if value, ok := getPath(request, path); ok {
+			setPath(request, path, t.hash(value))
+		}
+	}
+
+	for _, rename := range t.Rename {
+		if value, ok := getPath(request, rename.From); ok {
+			deletePath(request, rename.From)
+			setPath(request, rename.To, value)
+		}
+	}
+
+	for _, add := range t.Add {
+		setPath(request, add.Path, add.Value)
+	}
+
+	for _, path := range t.Timestamps {
+		if value, ok := getPath(request, path); ok {
+			if converted, ok := t.formatTimestamp(value); ok {
+				setPath(request, path, converted)
+			}
+		}
+	}
+
+	if t.TruncateLength > 0 {
+		truncateStrings(request, t.TruncateLength)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	// the request is not embedded in html
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(request); err == nil {
+		record.Request = strings.TrimSuffix(buf.String(), "\n")
+	}
+
+	return record
+}
+
+func (t AnalyticsTransforms) hash(value interface{}) string {
+	s, ok := value.(string)
+	if !ok {
+		encoded, _ := json.Marshal(value)
+		s = string(encoded)
+	}
+
+	sum := sha256.Sum256([]byte(t.HashSalt + s))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// number is a json.Number decoded by a decoder using numbers.
+type number interface {
+	Int64() (int64, error)
+	Float64() (float64, error)
+}
+
+func (t AnalyticsTransforms) formatTimestamp(value interface{}) (string, bool) {
+	var seconds int64
+	switch v := value.(type) {
+	case number:
+		i, err := v.Int64()
+		if err != nil {
+			f, err := v.Float64()
+			if err != nil {
+				return "", false
+			}
+			i = int64(f)
+		}
+		seconds = i
+	case float64:
+		seconds = int64(v)
+	case int:
+		seconds = int64(v)
+	case int64:
+		seconds = v
+	default:
+		return "", false
+	}
+
+	layout := t.TimestampFormat
+	if layout == "" {
+		layout = time.RFC3339
+	}
+
+	return time.Unix(seconds, 0).UTC().Format(layout), true
+}
+
+// DecodeRequest decodes the authorization request of a record, which must be a JSON object.
+// The numbers are decoded as json numbers to keep the precision of the large integers.
+func DecodeRequest(request string) (map[string]interface{}, error) {
+	var decoded map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(request))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	if decoded == nil {
+		return nil, fmt.Errorf("request is not a JSON object")
+	}
+
+	return decoded, nil
+}
+
+// LookupPath returns the field at the dot separated path of a decoded request.
+func LookupPath(request map[string]interface{}, path string) (interface{}, bool) {
+	return getPath(request, path)
+}
+
+func getPath(m map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := m[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		m = child
+	}
+
+	value, ok := m[keys[len(keys)-1]]
+
+	return value, ok
+}
+
+// setPath sets the value at path, the missing objects on the path are created.
+func setPath(m map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := m[key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			m[key] = child
+		}
+		m = child
+	}
+
+	m[keys[len(keys)-1]] = value
+}
+
+func deletePath(m map[string]interface{}, path string) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := m[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = child
+	}
+
+	delete(m, keys[len(keys)-1])
+}
+
+func truncateStrings(value interface{}, length int) interface{} {
+	switch v := value.(type) {
+	case string:
+		if len(v) <= length {
+			return v
+		}
+		end := length
+		for end > 0 && !utf8.RuneStart(v[end]) {
+			end--
+		}
+
+		return v[:end]
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = truncateStrings(child, length)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = truncateStrings(child, length)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"testing"
+)
+
+func TestAnalyticsTransforms_Transform(t *testing.T) {
+	request := `{"action":"delete","context":{"email":"colin@foxmail.com","loginAt":1609459200,` +
+		`"remoteIP":"10.0.0.1","token":"secret"},"resource":"resources:articles:ladon","subject":"users:peter"}`
+
+	tests := []struct {
+		name       string
+		transforms AnalyticsTransforms
+		request    string
+		want       string
+	}{
+		{
+			name:       "no transforms",
+			transforms: AnalyticsTransforms{},
+			request:    "invalid",
+			want:       "invalid",
+		},
+		{
+			name:       "drop",
+			transforms: AnalyticsTransforms{Drop: []string{"context.token", "context.missing", "missing.token"}},
+			request:    request,
+			want: `{"action":"delete","context":{"email":"colin@foxmail.com","loginAt":1609459200,` +
+				`"remoteIP":"10.0.0.1"},"resource":"resources:articles:ladon","subject":"users:peter"}`,
+		},
+		{
+			name:       "hash",
+			transforms: AnalyticsTransforms{Drop: []string{"context"}, Hash: []string{"subject"}, HashSalt: "salt"},
+			request:    request,
+			// sha256 of saltusers:peter
+			want: `{"action":"delete","resource":"resources:articles:ladon",` +
+				`"subject":"94226d117dbc3a604ee077e5441c104893a50ad38e87bd34f4b60c1efb32a16b"}`,
+		},
+		{
+			name: "rename and add",
+			transforms: AnalyticsTransforms{
+				Drop:   []string{"context.email", "context.token", "context.loginAt"},
+				Rename: []RenameTransform{{From: "context.remoteIP", To: "client.ip"}},
+				Add:    []AddTransform{{Path: "source", Value: "iam"}},
+			},
+			request: request,
+			want: `{"action":"delete","client":{"ip":"10.0.0.1"},"context":{},"resource":"resources:articles:ladon",` +
+				`"source":"iam","subject":"users:peter"}`,
+		},
+		{
+			name: "timestamps and truncate",
+			transforms: AnalyticsTransforms{
+				Drop:            []string{"context.email", "context.token", "context.remoteIP"},
+				Timestamps:      []string{"context.loginAt", "action"},
+				TimestampFormat: "2006-01-02",
+				TruncateLength:  10,
+			},
+			request: request,
+			want: `{"action":"delete","context":{"loginAt":"2021-01-01"},"resource":"resources:",` +
+				`"subject":"users:pete"}`,
+		},
+		{
+			name:       "invalid request is blanked",
+			transforms: AnalyticsTransforms{Drop: []string{"context.token"}},
+			request:    `{"context":`,
+			want:       "",
+		},
+		{
+			name:       "invalid request is kept without removals",
+			transforms: AnalyticsTransforms{TruncateLength: 1},
+			request:    `{"context":`,
+			want:       `{"context":`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.transforms.Transform(AnalyticsRecord{Username: "colin", Request: tt.request})
+			if got.Request != tt.want {
+				t.Errorf("Transform().Request = %s, want %s", got.Request, tt.want)
+			}
+			if got.Username != "colin" {
+				t.Errorf("Transform().Username = %s, want colin", got.Username)
+			}
+		})
+	}
+}
+
+func TestAnalyticsTransforms_Validate(t *testing.T) {
+	transforms := AnalyticsTransforms{
+		Drop:           []string{"context.token", ""},
+		Rename:         []RenameTransform{{From: "context.", To: "ip"}},
+		TruncateLength: -1,
+	}
+
+	if errs := transforms.Validate("pumps.es.transforms"); len(errs) != 3 {
+		t.Errorf("Validate() = %v, want 3 errors", errs)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package deadletter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/marmotedu/errors"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+)
+
+const batchSuffix = ".msgpack"
+
+// Queue is an on-disk dead-letter queue. Each batch is a msgpack encoded list of analytics
+// records stored in <dir>/<pump>/<unixnano>.msgpack, where pump is the key of the pump in the
+// pumps configuration.
+type Queue struct {
+	dir string
+}
+
+// NewQueue returns a dead-letter queue stored under dir.
+func NewQueue(dir string) *Queue {
+	return &Queue{dir: dir}
+}
+
+// Put stores records as a new batch of pump. Values which are not analytics records are ignored.
+func (q *Queue) Put(pump string, records []interface{}) error {
+	batch := make([]analytics.AnalyticsRecord, 0, len(records))
+	for _, record := range records {
+		if decoded, ok := record.(analytics.AnalyticsRecord); ok {
+			batch = append(batch, decoded)
+		}
+	}
+
+	data, err := msgpack.Marshal(batch)
+	if err != nil {
+		return errors.Wrap(err, "encode dead-letter batch failed")
+	}
+
+	dir := filepath.Join(q.dir, pump)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return errors.Wrap(err, "create dead-letter directory failed")
+	}
+
+	// write to a temporary file first, so that a partially written batch is never replayed.
+	name := filepath.Join(dir, fmt.Sprintf("%020d%s", time.Now().UnixNano(), batchSuffix))
+	if err := os.WriteFile(name+".tmp", data, 0o640); err != nil {
+		return errors.Wrap(err, "write dead-letter batch failed")
+	}
+
+	return os.Rename(name+".tmp", name)
+}
+
+// Batches returns the paths of the batches of pump, oldest first.
+func (q *Queue) Batches(pump string) ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(q.dir, pump, "*"+batchSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Read returns the records of the batch stored in path.
+func (q *Queue) Read(path string) ([]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var batch []analytics.AnalyticsRecord
+	if err := msgpack.Unmarshal(data, &batch); err != nil {
+		return nil, errors.Wrapf(err, "decode dead-letter batch %s failed", path)
+	}
+
+	records := make([]interface{}, len(batch))
+	for i, record := range batch {
+		records[i] = record
+	}
+
+	return records, nil
+}
+
+// Remove deletes the batch stored in path.
+func (q *Queue) Remove(path string) error {
+	return os.Remove(path)
+}
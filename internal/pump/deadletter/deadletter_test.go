@@ -0,0 +1,49 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package deadletter
+
+import (
+	"testing"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+)
+
+func TestQueue(t *testing.T) {
+	q := NewQueue(t.TempDir())
+
+	records := []interface{}{
+		analytics.AnalyticsRecord{Username: "colin", Effect: "allow"},
+		nil,
+		analytics.AnalyticsRecord{Username: "admin", Effect: "deny"},
+	}
+	if err := q.Put("mongo", records); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	batches, err := q.Batches("mongo")
+	if err != nil || len(batches) != 1 {
+		t.Fatalf("Batches() = %v, %v, want 1 batch", batches, err)
+	}
+
+	if others, _ := q.Batches("csv"); len(others) != 0 {
+		t.Errorf("Batches(csv) = %v, want empty", others)
+	}
+
+	got, err := q.Read(batches[0])
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(got) != 2 || got[0].(analytics.AnalyticsRecord).Username != "colin" ||
+		got[1].(analytics.AnalyticsRecord).Username != "admin" {
+		t.Errorf("Read() = %v", got)
+	}
+
+	if err := q.Remove(batches[0]); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if batches, _ := q.Batches("mongo"); len(batches) != 0 {
+		t.Errorf("Batches() after Remove() = %v, want empty", batches)
+	}
+}
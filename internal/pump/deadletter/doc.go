@@ -0,0 +1,7 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package deadletter stores the analytics records which a pump failed to write after all retries,
+// so that they can be replayed later instead of being lost.
+package deadletter
@@ -0,0 +1,44 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/spf13/pflag"
+)
+
+// AdminOptions defines the admin API showing the pump status and controlling the pumps.
+type AdminOptions struct {
+	BindAddress string `json:"bind-address" mapstructure:"bind-address"`
+}
+
+// NewAdminOptions creates an AdminOptions object with default parameters.
+func NewAdminOptions() *AdminOptions {
+	return &AdminOptions{
+		BindAddress: "127.0.0.1:7071",
+	}
+}
+
+// Validate verifies flags passed to AdminOptions.
+func (o *AdminOptions) Validate() []error {
+	errs := []error{}
+
+	if o.BindAddress != "" {
+		if _, _, err := net.SplitHostPort(o.BindAddress); err != nil {
+			errs = append(errs, fmt.Errorf("--admin.bind-address %s is invalid: %w", o.BindAddress, err))
+		}
+	}
+
+	return errs
+}
+
+// AddFlags adds flags related to the admin API to the specified FlagSet.
+func (o *AdminOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.BindAddress, "admin.bind-address", o.BindAddress, ""+
+		"The address the admin API showing the pump status and pausing, resuming the pumps is served on. "+
+		"The API is not authenticated, bind it to a local address. If empty, the admin API is disabled.")
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// DeadLetterOptions defines where iam-pump stores the records a pump failed to write.
+type DeadLetterOptions struct {
+	Dir    string `json:"dir"    mapstructure:"dir"`
+	Replay bool   `json:"replay" mapstructure:"replay"`
+}
+
+// NewDeadLetterOptions creates a DeadLetterOptions object with default parameters.
+func NewDeadLetterOptions() *DeadLetterOptions {
+	return &DeadLetterOptions{
+		Dir: "/var/lib/iam/pump-dead-letter",
+	}
+}
+
+// Validate verifies flags passed to DeadLetterOptions.
+func (o *DeadLetterOptions) Validate() []error {
+	errs := []error{}
+
+	if o.Replay && o.Dir == "" {
+		errs = append(errs, fmt.Errorf("--dead-letter.dir is required by --dead-letter.replay"))
+	}
+
+	return errs
+}
+
+// AddFlags adds flags related to dead-lettering to the specified FlagSet.
+func (o *DeadLetterOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Dir, "dead-letter.dir", o.Dir, ""+
+		"The directory storing the records a pump failed to write after all retries. "+
+		"If empty, the records are kept in the input and pumped again in the next purge, "+
+		"also to the pumps which have already written them.")
+
+	fs.BoolVar(&o.Replay, "dead-letter.replay", o.Replay, ""+
+		"Re-send the dead-lettered records to their pumps, then exit instead of running the purge loop.")
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// Supported analytics inputs, they match the sinks of iam-authz-server.
+const (
+	InputRedis = "redis"
+	InputFile  = "file"
+	InputKafka = "kafka"
+)
+
+// InputOptions defines where iam-pump reads analytics records from.
+type InputOptions struct {
+	Type         string   `json:"type"           mapstructure:"type"`
+	SpoolDir     string   `json:"spool-dir"      mapstructure:"spool-dir"`
+	KafkaBrokers []string `json:"kafka-brokers"  mapstructure:"kafka-brokers"`
+	KafkaTopic   string   `json:"kafka-topic"    mapstructure:"kafka-topic"`
+	KafkaGroupID string   `json:"kafka-group-id" mapstructure:"kafka-group-id"`
+}
+
+// NewInputOptions creates an InputOptions object with default parameters.
+func NewInputOptions() *InputOptions {
+	return &InputOptions{
+		Type:         InputRedis,
+		SpoolDir:     "/var/lib/iam/analytics-spool",
+		KafkaBrokers: []string{},
+		KafkaTopic:   "iam-analytics",
+		KafkaGroupID: "iam-pump",
+	}
+}
+
+// Validate verifies flags passed to InputOptions.
+func (o *InputOptions) Validate() []error {
+	errs := []error{}
+
+	switch o.Type {
+	case InputRedis:
+	case InputFile:
+		if o.SpoolDir == "" {
+			errs = append(errs, fmt.Errorf("--input.spool-dir is required by the file input"))
+		}
+	case InputKafka:
+		if len(o.KafkaBrokers) == 0 || o.KafkaTopic == "" || o.KafkaGroupID == "" {
+			errs = append(errs, fmt.Errorf("--input.kafka-brokers, --input.kafka-topic and --input.kafka-group-id "+
+				"are required by the kafka input"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("--input.type must be one of %s, %s and %s", InputRedis, InputFile, InputKafka))
+	}
+
+	return errs
+}
+
+// AddFlags adds flags related to the analytics input to the specified FlagSet.
+func (o *InputOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Type, "input.type", o.Type, ""+
+		"Where to read analytics records from, one of redis, file and kafka. "+
+		"It must match --analytics.sink of iam-authz-server.")
+
+	fs.StringVar(&o.SpoolDir, "input.spool-dir", o.SpoolDir, ""+
+		"The spool directory written by iam-authz-server when the input type is file.")
+
+	fs.StringSliceVar(&o.KafkaBrokers, "input.kafka-brokers", o.KafkaBrokers, ""+
+		"The kafka brokers to consume analytics records from when the input type is kafka.")
+
+	fs.StringVar(&o.KafkaTopic, "input.kafka-topic", o.KafkaTopic, ""+
+		"The kafka topic to consume analytics records from when the input type is kafka.")
+
+	fs.StringVar(&o.KafkaGroupID, "input.kafka-group-id", o.KafkaGroupID, ""+
+		"The kafka consumer group shared by all iam-pump instances.")
+}
@@ -16,22 +16,28 @@ import (
 
 // PumpConfig defines options for pump back-end.
 type PumpConfig struct {
-	Type                  string                     `json:"type"                    mapstructure:"type"`
-	Filters               analytics.AnalyticsFilters `json:"filters"                 mapstructure:"filters"`
-	Timeout               int                        `json:"timeout"                 mapstructure:"timeout"`
-	OmitDetailedRecording bool                       `json:"omit-detailed-recording" mapstructure:"omit-detailed-recording"`
-	Meta                  map[string]interface{}     `json:"meta"                    mapstructure:"meta"`
+	Type                  string                        `json:"type"                    mapstructure:"type"`
+	Filters               analytics.AnalyticsFilters    `json:"filters"                 mapstructure:"filters"`
+	Transforms            analytics.AnalyticsTransforms `json:"transforms"              mapstructure:"transforms"`
+	Timeout               int                           `json:"timeout"                 mapstructure:"timeout"`
+	OmitDetailedRecording bool                          `json:"omit-detailed-recording" mapstructure:"omit-detailed-recording"`
+	Retry                 RetryOptions                  `json:"retry"                   mapstructure:"retry"`
+	Meta                  map[string]interface{}        `json:"meta"                    mapstructure:"meta"`
 }
 
 // Options runs a pumpserver.
 type Options struct {
-	PurgeDelay            int                          `json:"purge-delay"             mapstructure:"purge-delay"`
-	Pumps                 map[string]PumpConfig        `json:"pumps"                   mapstructure:"pumps"`
-	HealthCheckPath       string                       `json:"health-check-path"       mapstructure:"health-check-path"`
-	HealthCheckAddress    string                       `json:"health-check-address"    mapstructure:"health-check-address"`
-	OmitDetailedRecording bool                         `json:"omit-detailed-recording" mapstructure:"omit-detailed-recording"`
-	RedisOptions          *genericoptions.RedisOptions `json:"redis"                   mapstructure:"redis"`
-	Log                   *log.Options                 `json:"log"                     mapstructure:"log"`
+	PurgeDelay            int                            `json:"purge-delay"             mapstructure:"purge-delay"`
+	Pumps                 map[string]PumpConfig          `json:"pumps"                   mapstructure:"pumps"`
+	HealthCheckPath       string                         `json:"health-check-path"       mapstructure:"health-check-path"`
+	HealthCheckAddress    string                         `json:"health-check-address"    mapstructure:"health-check-address"`
+	OmitDetailedRecording bool                           `json:"omit-detailed-recording" mapstructure:"omit-detailed-recording"`
+	Input                 *InputOptions                  `json:"input"                   mapstructure:"input"`
+	DeadLetter            *DeadLetterOptions             `json:"dead-letter"             mapstructure:"dead-letter"`
+	Admin                 *AdminOptions                  `json:"admin"                   mapstructure:"admin"`
+	RedisOptions          *genericoptions.RedisOptions   `json:"redis"                   mapstructure:"redis"`
+	Log                   *log.Options                   `json:"log"                     mapstructure:"log"`
+	TracingOptions        *genericoptions.TracingOptions `json:"tracing"                 mapstructure:"tracing"`
 }
 
 // NewOptions creates a new Options object with default parameters.
@@ -48,8 +54,12 @@ func NewOptions() *Options {
 		},
 		HealthCheckPath:    "healthz",
 		HealthCheckAddress: "0.0.0.0:7070",
+		Input:              NewInputOptions(),
+		DeadLetter:         NewDeadLetterOptions(),
+		Admin:              NewAdminOptions(),
 		RedisOptions:       genericoptions.NewRedisOptions(),
 		Log:                log.NewOptions(),
+		TracingOptions:     genericoptions.NewTracingOptions(),
 	}
 
 	return &s
@@ -57,8 +67,12 @@ func NewOptions() *Options {
 
 // Flags returns flags for a specific APIServer by section name.
 func (o *Options) Flags() (fss cliflag.NamedFlagSets) {
+	o.Input.AddFlags(fss.FlagSet("input"))
+	o.DeadLetter.AddFlags(fss.FlagSet("dead letter"))
+	o.Admin.AddFlags(fss.FlagSet("admin"))
 	o.RedisOptions.AddFlags(fss.FlagSet("redis"))
 	o.Log.AddFlags(fss.FlagSet("logs"))
+	o.TracingOptions.AddFlags(fss.FlagSet("tracing"))
 
 	// Note: the weird ""+ in below lines seems to be the only way to get gofmt to
 	// arrange these text blocks sensibly. Grrr.
@@ -0,0 +1,54 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryOptions defines how a pump retries a failed write before the records are dead-lettered.
+type RetryOptions struct {
+	MaxRetries     int           `json:"max-retries"     mapstructure:"max-retries"`
+	InitialBackoff time.Duration `json:"initial-backoff" mapstructure:"initial-backoff"`
+	MaxBackoff     time.Duration `json:"max-backoff"     mapstructure:"max-backoff"`
+}
+
+// Validate verifies the retry options of a pump.
+func (o *RetryOptions) Validate(pump string) []error {
+	errs := []error{}
+
+	if o.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("pumps.%s.retry.max-retries %d must not be negative", pump, o.MaxRetries))
+	}
+
+	if o.InitialBackoff < 0 || o.MaxBackoff < 0 {
+		errs = append(errs, fmt.Errorf("pumps.%s.retry backoffs must not be negative", pump))
+	}
+
+	return errs
+}
+
+// Backoff returns how long to wait before the retry following the given number of attempts,
+// doubling from InitialBackoff (default 1s) up to MaxBackoff (default 30s).
+func (o *RetryOptions) Backoff(attempts int) time.Duration {
+	backoff, maxBackoff := o.InitialBackoff, o.MaxBackoff
+	if backoff == 0 {
+		backoff = time.Second
+	}
+	if maxBackoff == 0 {
+		maxBackoff = 30 * time.Second
+	}
+
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+
+	return backoff
+}
@@ -8,8 +8,17 @@ package options
 func (o *Options) Validate() []error {
 	var errs []error
 
+	errs = append(errs, o.Input.Validate()...)
+	errs = append(errs, o.DeadLetter.Validate()...)
+	errs = append(errs, o.Admin.Validate()...)
+	for name, pump := range o.Pumps {
+		errs = append(errs, pump.Retry.Validate(name)...)
+		errs = append(errs, pump.Filters.Validate("pumps."+name+".filters")...)
+		errs = append(errs, pump.Transforms.Validate("pumps."+name+".transforms")...)
+	}
 	errs = append(errs, o.RedisOptions.Validate()...)
 	errs = append(errs, o.Log.Validate()...)
+	errs = append(errs, o.TracingOptions.Validate()...)
 
 	return errs
 }
@@ -0,0 +1,296 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/marmotedu/errors"
+	"github.com/mitchellh/mapstructure"
+	"github.com/vinllen/mgo"
+	"github.com/vinllen/mgo/bson"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Stores of the aggregates.
+const (
+	AggregateStoreSQL   = "sql"
+	AggregateStoreMongo = "mongo"
+)
+
+const (
+	defaultAggregateName      = "iam_analytics_aggregates"
+	defaultAggregateBatchSize = 1000
+
+	// sizes in bytes of the aggregate key columns, their sum fits in the mysql index key limit.
+	aggregateUsernameSize = 255
+	aggregateActionSize   = 128
+	aggregateResourceSize = 255
+)
+
+// aggregateKeyColumns are the columns identifying an aggregate.
+var aggregateKeyColumns = []string{"bucket", "username", "effect", "action", "resource"}
+
+// AggregatePump defines an aggregate pump with aggregate specific options and common options.
+// It rolls up the records into time buckets and adds the counts to the existing aggregates of
+// the downstream store, so the aggregates of several pump cycles are merged.
+type AggregatePump struct {
+	aggregateConf *AggregateConf
+	bucketSize    time.Duration
+	store         aggregateStore
+	CommonPumpConfig
+}
+
+// AggregateConf defines aggregate specific options.
+type AggregateConf struct {
+	// Granularity is the size of the time buckets, one of minute, hour and day, default hour.
+	Granularity string `mapstructure:"granularity"`
+	// Store is the downstream store of the aggregates, sql or mongo.
+	Store string `mapstructure:"store"`
+
+	// SQLType and ConnectionString configure the sql store, see SQLConf.
+	SQLType          string `mapstructure:"sql_type"`
+	ConnectionString string `mapstructure:"connection_string"`
+	TableName        string `mapstructure:"table_name"`
+
+	// BaseMongoConf configures the mongo store.
+	BaseMongoConf  `mapstructure:",squash"`
+	CollectionName string `mapstructure:"collection_name"`
+}
+
+// aggregateStore adds the counts of the aggregates to the stored ones.
+type aggregateStore interface {
+	upsert(ctx context.Context, aggregates []*analytics.AnalyticsRecordAggregate) error
+}
+
+// New create an aggregate pump instance.
+func (a *AggregatePump) New() Pump {
+	newPump := AggregatePump{}
+
+	return &newPump
+}
+
+// GetName returns the aggregate pump name.
+func (a *AggregatePump) GetName() string {
+	return "Aggregate Pump"
+}
+
+// Init initialize the aggregate pump instance.
+func (a *AggregatePump) Init(config interface{}) error {
+	a.aggregateConf = &AggregateConf{}
+	if err := mapstructure.Decode(config, a.aggregateConf); err != nil {
+		return errors.Wrap(err, "failed to decode aggregate configuration")
+	}
+
+	conf := a.aggregateConf
+	if conf.Granularity == "" {
+		conf.Granularity = analytics.GranularityHour
+	}
+
+	var err error
+	if a.bucketSize, err = analytics.BucketSize(conf.Granularity); err != nil {
+		return err
+	}
+
+	switch conf.Store {
+	case AggregateStoreSQL:
+		if conf.TableName == "" {
+			conf.TableName = defaultAggregateName
+		}
+		a.store, err = newSQLAggregateStore(conf.SQLType, conf.ConnectionString, conf.TableName)
+	case AggregateStoreMongo:
+		if conf.CollectionName == "" {
+			conf.CollectionName = defaultAggregateName
+		}
+		a.store, err = newMongoAggregateStore(conf.BaseMongoConf, conf.CollectionName)
+	default:
+		err = fmt.Errorf("unknown aggregate store %q, must be %s or %s",
+			conf.Store, AggregateStoreSQL, AggregateStoreMongo)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Aggregate Pump active, rolling up records by %s to %s", conf.Granularity, conf.Store)
+
+	return nil
+}
+
+// WriteData rolls up the records and merges the aggregates into the store.
+func (a *AggregatePump) WriteData(ctx context.Context, data []interface{}) error {
+	startTime := time.Now()
+
+	records := make([]analytics.AnalyticsRecord, 0, len(data))
+	for _, v := range data {
+		decoded, _ := v.(analytics.AnalyticsRecord)
+		records = append(records, decoded)
+	}
+
+	aggregates := analytics.AggregateRecords(records, a.bucketSize)
+	if len(aggregates) == 0 {
+		return nil
+	}
+
+	log.Debugf("Attempting to write %d aggregates of %d records...", len(aggregates), len(data))
+
+	if err := a.store.upsert(ctx, aggregates); err != nil {
+		return err
+	}
+
+	log.Debugf("ElapsedTime in seconds for %d records %v", len(data), time.Since(startTime))
+
+	return nil
+}
+
+// SQLAnalyticsAggregate is the table row of an aggregate.
+type SQLAnalyticsAggregate struct {
+	ID       uint64 `gorm:"primaryKey;autoIncrement"`
+	Bucket   int64  `gorm:"not null"`
+	Username string `gorm:"size:255;not null"`
+	Effect   string `gorm:"size:16;not null"`
+	Action   string `gorm:"size:128;not null"`
+	Resource string `gorm:"size:255;not null"`
+	Count    int64  `gorm:"not null"`
+}
+
+// TableName returns the default table name of the aggregates.
+func (SQLAnalyticsAggregate) TableName() string {
+	return defaultAggregateName
+}
+
+type sqlAggregateStore struct {
+	db      *gorm.DB
+	sqlType string
+	table   string
+}
+
+func newSQLAggregateStore(sqlType, dsn, table string) (*sqlAggregateStore, error) {
+	gdb, err := openSQL(sqlType, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gdb.Table(table).AutoMigrate(&SQLAnalyticsAggregate{}); err != nil {
+		return nil, errors.Wrapf(err, "failed to migrate table %s", table)
+	}
+
+	// the unique index is named after the table, index names are unique per schema in postgres.
+	index := "idx_" + table + "_key"
+	if !gdb.Migrator().HasIndex(table, index) {
+		columns := make([]clause.Column, len(aggregateKeyColumns))
+		for i, name := range aggregateKeyColumns {
+			columns[i] = clause.Column{Name: name}
+		}
+		err := gdb.Exec("CREATE UNIQUE INDEX ? ON ? (?)", clause.Column{Name: index}, clause.Table{Name: table}, columns).Error
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create index %s", index)
+		}
+	}
+
+	return &sqlAggregateStore{db: gdb, sqlType: sqlType, table: table}, nil
+}
+
+func (s *sqlAggregateStore) upsert(ctx context.Context, aggregates []*analytics.AnalyticsRecordAggregate) error {
+	// the truncated keys may collide, merge them as a row can't be upserted twice in a statement.
+	rows := make([]*SQLAnalyticsAggregate, 0, len(aggregates))
+	merged := make(map[SQLAnalyticsAggregate]*SQLAnalyticsAggregate, len(aggregates))
+	for _, aggregate := range aggregates {
+		key := SQLAnalyticsAggregate{
+			Bucket:   aggregate.Bucket,
+			Username: truncateColumn(aggregate.Username, aggregateUsernameSize),
+			Effect:   aggregate.Effect,
+			Action:   truncateColumn(aggregate.Action, aggregateActionSize),
+			Resource: truncateColumn(aggregate.Resource, aggregateResourceSize),
+		}
+		if row, ok := merged[key]; ok {
+			row.Count += aggregate.Count
+
+			continue
+		}
+
+		row := key
+		row.Count = aggregate.Count
+		merged[key] = &row
+		rows = append(rows, &row)
+	}
+
+	conflict := clause.OnConflict{DoUpdates: clause.Assignments(map[string]interface{}{"count": s.addCount()})}
+	for _, name := range aggregateKeyColumns {
+		conflict.Columns = append(conflict.Columns, clause.Column{Name: name})
+	}
+
+	err := s.db.WithContext(ctx).Table(s.table).Clauses(conflict).CreateInBatches(rows, defaultAggregateBatchSize).Error
+	if err != nil {
+		return errors.Wrapf(err, "failed to upsert aggregates to table %s", s.table)
+	}
+
+	return nil
+}
+
+// addCount returns the expression adding the inserted count to the existing one.
+func (s *sqlAggregateStore) addCount() clause.Expr {
+	if s.sqlType == SQLTypeMySQL {
+		return gorm.Expr("? + VALUES(?)", clause.Column{Name: "count"}, clause.Column{Name: "count"})
+	}
+
+	return gorm.Expr("? + excluded.?", clause.Column{Table: s.table, Name: "count"}, clause.Column{Name: "count"})
+}
+
+type mongoAggregateStore struct {
+	session    *mgo.Session
+	collection string
+}
+
+func newMongoAggregateStore(conf BaseMongoConf, collection string) (*mongoAggregateStore, error) {
+	dialInfo, err := mongoDialInfo(conf)
+	if err != nil {
+		return nil, errors.Wrap(err, "mongo url is invalid")
+	}
+	dialInfo.Timeout = 5 * time.Second
+
+	session, err := mgo.DialWithInfo(dialInfo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to mongo")
+	}
+
+	index := mgo.Index{Key: aggregateKeyColumns, Unique: true, Background: true}
+	if err := session.DB("").C(collection).EnsureIndex(index); err != nil {
+		session.Close()
+
+		return nil, errors.Wrapf(err, "failed to ensure the index of collection %s", collection)
+	}
+
+	return &mongoAggregateStore{session: session, collection: collection}, nil
+}
+
+func (m *mongoAggregateStore) upsert(_ context.Context, aggregates []*analytics.AnalyticsRecordAggregate) error {
+	sess := m.session.Copy()
+	defer sess.Close()
+
+	bulk := sess.DB("").C(m.collection).Bulk()
+	bulk.Unordered()
+	for _, aggregate := range aggregates {
+		selector := bson.M{
+			"bucket":   aggregate.Bucket,
+			"username": aggregate.Username,
+			"effect":   aggregate.Effect,
+			"action":   aggregate.Action,
+			"resource": aggregate.Resource,
+		}
+		bulk.Upsert(selector, bson.M{"$inc": bson.M{"count": aggregate.Count}})
+	}
+
+	if _, err := bulk.Run(); err != nil {
+		return errors.Wrapf(err, "failed to upsert aggregates to collection %s", m.collection)
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+)
+
+func TestAggregatePump_WriteData(t *testing.T) {
+	pmp := &AggregatePump{}
+	err := pmp.Init(map[string]interface{}{
+		"granularity":       analytics.GranularityMinute,
+		"store":             AggregateStoreSQL,
+		"sql_type":          SQLTypeSQLite,
+		"connection_string": filepath.Join(t.TempDir(), "analytics.db"),
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	request := `{"action":"delete","resource":"resources:articles:ladon"}`
+	data := []interface{}{
+		analytics.AnalyticsRecord{TimeStamp: 60, Username: "colin", Effect: "allow", Request: request},
+		analytics.AnalyticsRecord{TimeStamp: 90, Username: "colin", Effect: "allow", Request: request},
+		analytics.AnalyticsRecord{TimeStamp: 120, Username: "colin", Effect: "allow", Request: request},
+	}
+
+	// the counts of the pump cycles are merged.
+	for i := 0; i < 2; i++ {
+		if err := pmp.WriteData(context.Background(), data); err != nil {
+			t.Fatalf("WriteData() error = %v", err)
+		}
+	}
+
+	var rows []SQLAnalyticsAggregate
+	store, _ := pmp.store.(*sqlAggregateStore)
+	if err := store.db.Table(defaultAggregateName).Order("bucket").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int64]int64{60: 4, 120: 2}
+	if len(rows) != len(want) {
+		t.Fatalf("aggregates = %+v, want counts %v", rows, want)
+	}
+	for _, row := range rows {
+		if row.Count != want[row.Bucket] || row.Action != "delete" || row.Resource != "resources:articles:ladon" {
+			t.Errorf("aggregate = %+v, want count %d", row, want[row.Bucket])
+		}
+	}
+}
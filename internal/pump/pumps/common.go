@@ -9,6 +9,7 @@ import "github.com/marmotedu/iam/internal/pump/analytics"
 // CommonPumpConfig defines common options used by all persistent store, like elasticsearch, kafka, mongo and etc.
 type CommonPumpConfig struct {
 	filters               analytics.AnalyticsFilters
+	transforms            analytics.AnalyticsTransforms
 	timeout               int
 	OmitDetailedRecording bool
 }
@@ -23,6 +24,16 @@ func (p *CommonPumpConfig) GetFilters() analytics.AnalyticsFilters {
 	return p.filters
 }
 
+// SetTransforms set attributes `transforms` for CommonPumpConfig.
+func (p *CommonPumpConfig) SetTransforms(transforms analytics.AnalyticsTransforms) {
+	p.transforms = transforms
+}
+
+// GetTransforms get attributes `transforms` for CommonPumpConfig.
+func (p *CommonPumpConfig) GetTransforms() analytics.AnalyticsTransforms {
+	return p.transforms
+}
+
 // SetTimeout set attributes `timeout` for CommonPumpConfig.
 func (p *CommonPumpConfig) SetTimeout(timeout int) {
 	p.timeout = timeout
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/marmotedu/errors"
@@ -28,6 +29,7 @@ type ElasticsearchPump struct {
 }
 
 // ElasticsearchConf defines elasticsearch specific options.
+// The pump installs a versioned index template mapping the records, unless DisableTemplate is set.
 type ElasticsearchConf struct {
 	BulkConfig       ElasticsearchBulkConfig `mapstructure:"bulk_config"`
 	IndexName        string                  `mapstructure:"index_name"`
@@ -40,6 +42,19 @@ type ElasticsearchConf struct {
 	EnableSniffing   bool                    `mapstructure:"use_sniffing"`
 	RollingIndex     bool                    `mapstructure:"rolling_index"`
 	DisableBulk      bool                    `mapstructure:"disable_bulk"`
+	DisableTemplate  bool                    `mapstructure:"disable_template"`
+	// TemplateName defaults to IndexName. An installed template of the same or a newer version is
+	// kept unless TemplateOverwrite is set.
+	TemplateName      string `mapstructure:"template_name"`
+	TemplateOverwrite bool   `mapstructure:"template_overwrite"`
+	// ILMPolicy is attached to the indices. With ILMRetention, the policy is created to delete the
+	// indices after the retention, named <index_name>-policy by default.
+	ILMPolicy         string `mapstructure:"ilm_policy"`
+	ILMRetention      string `mapstructure:"ilm_retention"`
+	ILMRolloverMaxAge string `mapstructure:"ilm_rollover_max_age"`
+	// DataStream writes the records to the data stream IndexName, created by elasticsearch on
+	// the first write from the index template.
+	DataStream bool `mapstructure:"data_stream"`
 }
 
 // ElasticsearchBulkConfig defines elasticsearch bulk config.
@@ -53,12 +68,54 @@ type ElasticsearchBulkConfig struct {
 // ElasticsearchOperator defines interface for all elasticsearch operator.
 type ElasticsearchOperator interface {
 	processData(ctx context.Context, data []interface{}, esConf *ElasticsearchConf) error
+	setupIndex(ctx context.Context, esConf *ElasticsearchConf) error
 }
 
 // Elasticsearch7Operator defines elasticsearch6 operator.
 type Elasticsearch7Operator struct {
 	esClient      *elastic.Client
 	bulkProcessor *elastic.BulkProcessor
+	bulkFailures  *bulkFailures
+}
+
+// bulkFailures collects the failures of the bulk requests committed by the bulk processor.
+type bulkFailures struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (f *bulkFailures) after(_ int64, _ []elastic.BulkableRequest, response *elastic.BulkResponse, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err != nil {
+		f.errs = append(f.errs, errors.Wrap(err, "bulk request failed"))
+
+		return
+	}
+
+	if response == nil {
+		return
+	}
+
+	if failed := response.Failed(); len(failed) > 0 {
+		reason := "unknown"
+		if failed[0].Error != nil {
+			reason = failed[0].Error.Reason
+		}
+		f.errs = append(f.errs, errors.Errorf("%d documents of bulk request failed: %s", len(failed), reason))
+	}
+}
+
+// take returns the failures collected since the last call.
+func (f *bulkFailures) take() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := errors.NewAggregate(f.errs)
+	f.errs = nil
+
+	return err
 }
 
 // APIKeyTransport defiens elasticsearch api key.
@@ -100,7 +157,8 @@ func getOperator(ctx context.Context, conf ElasticsearchConf) (ElasticsearchOper
 		return e, errors.Wrap(err, "failed to new es client")
 	}
 	// Setup a bulk processor
-	p := e.esClient.BulkProcessor().Name("IAMPumpESv6BackgroundProcessor")
+	e.bulkFailures = &bulkFailures{}
+	p := e.esClient.BulkProcessor().Name("IAMPumpESv6BackgroundProcessor").After(e.bulkFailures.after)
 	if conf.BulkConfig.Workers != 0 {
 		p = p.Workers(conf.BulkConfig.Workers)
 	}
@@ -137,10 +195,8 @@ func (e *ElasticsearchPump) GetName() string {
 // Init initialize the elasticsearch pump instance.
 func (e *ElasticsearchPump) Init(config interface{}) error {
 	e.esConf = &ElasticsearchConf{}
-	loadConfigErr := mapstructure.Decode(config, &e.esConf)
-
-	if loadConfigErr != nil {
-		log.Fatalf("Failed to decode configuration: %s", loadConfigErr.Error())
+	if err := mapstructure.Decode(config, &e.esConf); err != nil {
+		return errors.Wrap(err, "failed to decode elasticsearch configuration")
 	}
 
 	if e.esConf.IndexName == "" {
@@ -155,6 +211,14 @@ func (e *ElasticsearchPump) Init(config interface{}) error {
 		e.esConf.DocumentType = "iam_analytics"
 	}
 
+	if e.esConf.TemplateName == "" {
+		e.esConf.TemplateName = e.esConf.IndexName
+	}
+
+	if err := validateIndexConf(e.esConf); err != nil {
+		return err
+	}
+
 	re := regexp.MustCompile(`(.*)\/\/(.*):(.*)\@(.*)`)
 	printableURL := re.ReplaceAllString(e.esConf.ElasticsearchURL, `$1//***:***@$4`)
 
@@ -166,7 +230,7 @@ func (e *ElasticsearchPump) Init(config interface{}) error {
 
 	e.connect(context.Background())
 
-	return nil
+	return e.operator.setupIndex(context.Background(), e.esConf)
 }
 
 func (e *ElasticsearchPump) connect(ctx context.Context) {
@@ -187,12 +251,13 @@ func (e *ElasticsearchPump) WriteData(ctx context.Context, data []interface{}) e
 	if e.operator == nil {
 		log.Debug("Connecting to analytics store")
 		e.connect(ctx)
-		_ = e.WriteData(ctx, data)
-	} else if len(data) > 0 {
-		_ = e.operator.processData(ctx, data, e.esConf)
 	}
 
-	return nil
+	if len(data) == 0 {
+		return nil
+	}
+
+	return e.operator.processData(ctx, data, e.esConf)
 }
 
 func getIndexName(esConf *ElasticsearchConf) string {
@@ -207,7 +272,8 @@ func getIndexName(esConf *ElasticsearchConf) string {
 	return indexName
 }
 
-func getMapping(datum analytics.AnalyticsRecord) (map[string]interface{}, string) {
+// getMapping returns the document of the record. The fields of the request are parsed for the index template.
+func getMapping(datum analytics.AnalyticsRecord, esConf *ElasticsearchConf) (map[string]interface{}, string) {
 	record := datum
 	mapping := map[string]interface{}{
 		"@timestamp": record.TimeStamp,
@@ -220,15 +286,32 @@ func getMapping(datum analytics.AnalyticsRecord) (map[string]interface{}, string
 		"expireAt":   record.ExpireAt,
 	}
 
+	if !esConf.DisableTemplate {
+		if request, err := analytics.DecodeRequest(record.Request); err == nil {
+			parsed := map[string]interface{}{}
+			for _, field := range []string{"subject", "action", "resource", "context"} {
+				if value, ok := request[field]; ok {
+					parsed[field] = value
+				}
+			}
+			mapping[elasticsearchParsedRequestKey] = parsed
+		}
+	}
+
 	return mapping, ""
 }
 
 func (e Elasticsearch7Operator) processData(ctx context.Context, data []interface{}, esConf *ElasticsearchConf) error {
 	index := e.esClient.Index().Index(getIndexName(esConf))
+	if esConf.DataStream {
+		// data streams only accept new documents.
+		index = index.OpType("create")
+	}
 
+	var errs []error
 	for dataIndex := range data {
 		if ctxErr := ctx.Err(); ctxErr != nil {
-			continue
+			return errors.NewAggregate(append(errs, ctxErr))
 		}
 
 		d, ok := data[dataIndex].(analytics.AnalyticsRecord)
@@ -238,19 +321,40 @@ func (e Elasticsearch7Operator) processData(ctx context.Context, data []interfac
 			continue
 		}
 
-		mapping, id := getMapping(d)
+		mapping, id := getMapping(d, esConf)
 
-		if !esConf.DisableBulk {
+		switch {
+		case !esConf.DisableBulk && esConf.DataStream:
+			e.bulkProcessor.Add(elastic.NewBulkCreateRequest().Index(getIndexName(esConf)).Doc(mapping))
+		case !esConf.DisableBulk:
 			r := elastic.NewBulkIndexRequest().Index(getIndexName(esConf)).Type(esConf.DocumentType).Id(id).Doc(mapping)
 			e.bulkProcessor.Add(r)
-		} else {
+		case esConf.DataStream:
+			if _, err := index.BodyJson(mapping).Do(ctx); err != nil {
+				log.Errorf("Error while writing %s %s", data[dataIndex], err.Error())
+				errs = append(errs, err)
+			}
+		default:
 			//nolint: staticcheck
 			_, err := index.BodyJson(mapping).Type(esConf.DocumentType).Id(id).Do(ctx)
 			if err != nil {
 				log.Errorf("Error while writing %s %s", data[dataIndex], err.Error())
+				errs = append(errs, err)
 			}
 		}
 	}
 
-	return nil
+	// the records are only written once the bulk processor is flushed, its failures are returned
+	// so that the records are retried or dead-lettered.
+	if !esConf.DisableBulk {
+		if err := e.bulkProcessor.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+		if err := e.bulkFailures.take(); err != nil {
+			log.Errorf("Error while writing bulk: %s", err.Error())
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.NewAggregate(errs)
 }
@@ -0,0 +1,238 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/marmotedu/errors"
+	elastic "github.com/olivere/elastic/v7"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// elasticsearchTemplateVersion is the version of the index template installed by the pump, it is
+// increased when the template changes so the installed templates are upgraded.
+const elasticsearchTemplateVersion = 1
+
+const (
+	// elasticsearchTemplatePriority overrides the templates of the broader patterns, like logs-*-*.
+	elasticsearchTemplatePriority = 200
+	// elasticsearchManagedBy marks the templates and policies installed by the pump.
+	elasticsearchManagedBy        = "iam-pump"
+	defaultElasticsearchRollover  = "1d"
+	elasticsearchRolloverMaxSize  = "50gb"
+	elasticsearchParsedRequestKey = "parsedRequest"
+)
+
+// elasticsearchTimeUnit matches the elasticsearch time units, like 30d.
+var elasticsearchTimeUnit = regexp.MustCompile(`^[0-9]+(d|h|m|s|ms)$`)
+
+// validateIndexConf checks the index template, ILM and data stream options.
+func validateIndexConf(conf *ElasticsearchConf) error {
+	if conf.DataStream && conf.RollingIndex {
+		return errors.New("rolling_index can't be used with data_stream, data streams are rolled over by ILM")
+	}
+	if conf.DataStream && conf.DisableTemplate {
+		return errors.New("data_stream requires the index template, disable_template must be false")
+	}
+	if conf.ILMRetention != "" && !elasticsearchTimeUnit.MatchString(conf.ILMRetention) {
+		return fmt.Errorf("invalid ilm_retention %q, must be an elasticsearch time unit like 30d", conf.ILMRetention)
+	}
+	if conf.ILMRolloverMaxAge != "" && !elasticsearchTimeUnit.MatchString(conf.ILMRolloverMaxAge) {
+		return fmt.Errorf("invalid ilm_rollover_max_age %q, must be an elasticsearch time unit like 1d", conf.ILMRolloverMaxAge)
+	}
+	if conf.ILMRetention != "" && conf.ILMPolicy == "" {
+		conf.ILMPolicy = conf.IndexName + "-policy"
+	}
+
+	return nil
+}
+
+// setupIndex installs the ILM policy and the index template of the pump.
+func (e *Elasticsearch7Operator) setupIndex(ctx context.Context, conf *ElasticsearchConf) error {
+	if conf.ILMRetention != "" {
+		if err := e.putILMPolicy(ctx, conf); err != nil {
+			return err
+		}
+	}
+
+	if conf.DisableTemplate {
+		return nil
+	}
+
+	body := elasticsearchIndexTemplate(conf)
+	installed, err := e.installedTemplate(ctx, conf.TemplateName)
+	if err != nil {
+		return err
+	}
+	// a template installed by the pump with other settings, like ilm_retention or data_stream,
+	// is replaced so that the settings are applied.
+	changed := installed.Version == elasticsearchTemplateVersion && installed.Meta.ManagedBy == elasticsearchManagedBy &&
+		installed.Meta.Hash != body["_meta"].(map[string]interface{})["hash"]
+	if installed.Version >= elasticsearchTemplateVersion && !changed && !conf.TemplateOverwrite {
+		if installed.Version > elasticsearchTemplateVersion {
+			log.Warnf("Elasticsearch index template %s has version %d, newer than %d of iam-pump, keep it",
+				conf.TemplateName, installed.Version, elasticsearchTemplateVersion)
+		}
+
+		return nil
+	}
+
+	_, err = e.esClient.PerformRequest(ctx, elastic.PerformRequestOptions{
+		Method: http.MethodPut,
+		Path:   "/_index_template/" + conf.TemplateName,
+		Body:   body,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to install elasticsearch index template %s", conf.TemplateName)
+	}
+
+	log.Infof("Installed elasticsearch index template %s version %d", conf.TemplateName, elasticsearchTemplateVersion)
+
+	return nil
+}
+
+// installedIndexTemplate is the part of an installed index template checked by the pump.
+type installedIndexTemplate struct {
+	Version int `json:"version"`
+	Meta    struct {
+		ManagedBy string `json:"managed_by"`
+		Hash      string `json:"hash"`
+	} `json:"_meta"`
+}
+
+// installedTemplate returns the installed index template, the zero value if it is missing.
+func (e *Elasticsearch7Operator) installedTemplate(ctx context.Context, name string) (installedIndexTemplate, error) {
+	resp, err := e.esClient.PerformRequest(ctx, elastic.PerformRequestOptions{
+		Method:       http.MethodGet,
+		Path:         "/_index_template/" + name,
+		IgnoreErrors: []int{http.StatusNotFound},
+	})
+	if err != nil {
+		return installedIndexTemplate{}, errors.Wrapf(err, "failed to get elasticsearch index template %s", name)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return installedIndexTemplate{}, nil
+	}
+
+	var templates struct {
+		IndexTemplates []struct {
+			IndexTemplate installedIndexTemplate `json:"index_template"`
+		} `json:"index_templates"`
+	}
+	if err := json.Unmarshal(resp.Body, &templates); err != nil {
+		return installedIndexTemplate{}, errors.Wrapf(err, "invalid elasticsearch index template %s", name)
+	}
+	if len(templates.IndexTemplates) == 0 {
+		return installedIndexTemplate{}, nil
+	}
+
+	return templates.IndexTemplates[0].IndexTemplate, nil
+}
+
+// putILMPolicy creates or updates the ILM policy deleting the indices after the retention.
+// The backing indices of a data stream are rolled over daily by default.
+func (e *Elasticsearch7Operator) putILMPolicy(ctx context.Context, conf *ElasticsearchConf) error {
+	phases := map[string]interface{}{
+		"delete": map[string]interface{}{
+			"min_age": conf.ILMRetention,
+			"actions": map[string]interface{}{"delete": map[string]interface{}{}},
+		},
+	}
+	if conf.DataStream {
+		maxAge := conf.ILMRolloverMaxAge
+		if maxAge == "" {
+			maxAge = defaultElasticsearchRollover
+		}
+		phases["hot"] = map[string]interface{}{
+			"actions": map[string]interface{}{
+				"rollover": map[string]interface{}{"max_age": maxAge, "max_size": elasticsearchRolloverMaxSize},
+			},
+		}
+	}
+
+	_, err := e.esClient.PerformRequest(ctx, elastic.PerformRequestOptions{
+		Method: http.MethodPut,
+		Path:   "/_ilm/policy/" + conf.ILMPolicy,
+		Body: map[string]interface{}{
+			"policy": map[string]interface{}{
+				"_meta":  map[string]interface{}{"managed_by": elasticsearchManagedBy},
+				"phases": phases,
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to put elasticsearch ILM policy %s", conf.ILMPolicy)
+	}
+
+	return nil
+}
+
+// elasticsearchIndexTemplate returns the composable index template of the indices written by the pump.
+func elasticsearchIndexTemplate(conf *ElasticsearchConf) map[string]interface{} {
+	keyword := map[string]interface{}{"type": "keyword", "ignore_above": 1024}
+	date := map[string]interface{}{"type": "date", "format": "epoch_second||strict_date_optional_time"}
+
+	patterns := []string{conf.IndexName}
+	if conf.RollingIndex {
+		patterns = append(patterns, conf.IndexName+"-*")
+	}
+
+	template := map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"@timestamp": date,
+				"username":   keyword,
+				"effect":     keyword,
+				"conclusion": map[string]interface{}{
+					"type":   "text",
+					"fields": map[string]interface{}{"keyword": keyword},
+				},
+				// the request is kept as sent, its fields are searched in parsedRequest.
+				"request":  map[string]interface{}{"type": "text", "index": false},
+				"policies": map[string]interface{}{"type": "text", "index": false},
+				"deciders": map[string]interface{}{"type": "text", "index": false},
+				"expireAt": date,
+				elasticsearchParsedRequestKey: map[string]interface{}{
+					"properties": map[string]interface{}{
+						"subject":  keyword,
+						"action":   keyword,
+						"resource": keyword,
+						// the context keys are arbitrary, flattened avoids a mapping per key.
+						"context": map[string]interface{}{"type": "flattened"},
+					},
+				},
+			},
+		},
+	}
+	if conf.ILMPolicy != "" {
+		template["settings"] = map[string]interface{}{"index.lifecycle.name": conf.ILMPolicy}
+	}
+
+	meta := map[string]interface{}{"managed_by": elasticsearchManagedBy}
+	body := map[string]interface{}{
+		"index_patterns": patterns,
+		"priority":       elasticsearchTemplatePriority,
+		"version":        elasticsearchTemplateVersion,
+		"_meta":          meta,
+		"template":       template,
+	}
+	if conf.DataStream {
+		body["data_stream"] = map[string]interface{}{}
+	}
+
+	// the hash identifies the settings the template is generated from, the keys of the maps
+	// are encoded sorted.
+	encoded, _ := json.Marshal(body)
+	meta["hash"] = fmt.Sprintf("%x", sha256.Sum256(encoded))
+
+	return body
+}
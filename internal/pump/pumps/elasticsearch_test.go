@@ -0,0 +1,279 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/marmotedu/component-base/pkg/json"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+)
+
+// mockElasticsearch records the requests and serves an index template of templateVersion, if set.
+type mockElasticsearch struct {
+	mu              sync.Mutex
+	templateVersion int
+	// templateHash is the hash of the installed template, it is managed by iam-pump if set.
+	templateHash string
+	failWrites   bool
+	requests     map[string]map[string]interface{}
+}
+
+func (m *mockElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	key := r.Method + " " + strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case r.URL.Path == "/":
+		_, _ = io.WriteString(w, `{"version":{"number":"7.10.0"}}`)
+
+		return
+	case r.Method == http.MethodGet && m.templateVersion == 0:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":"index template not found","status":404}`)
+
+		return
+	case r.Method == http.MethodGet && m.templateHash != "":
+		_, _ = fmt.Fprintf(w, `{"index_templates":[{"name":"t","index_template":{"version":%d,`+
+			`"_meta":{"managed_by":"iam-pump","hash":%q}}}]}`, m.templateVersion, m.templateHash)
+
+		return
+	case r.Method == http.MethodGet:
+		_, _ = fmt.Fprintf(w, `{"index_templates":[{"name":"t","index_template":{"version":%d}}]}`, m.templateVersion)
+
+		return
+	}
+
+	if m.failWrites && r.URL.Path == "/_bulk" {
+		// the documents of a bulk request fail individually.
+		_, _ = io.WriteString(w, `{"errors":true,"items":[{"index":{"_index":"iam_analytics","status":400,`+
+			`"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}]}`)
+
+		return
+	}
+	if m.failWrites && !strings.HasPrefix(r.URL.Path, "/_index_template") {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = io.WriteString(w, `{"error":"unavailable","status":500}`)
+
+		return
+	}
+
+	var body map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	if op := r.URL.Query().Get("op_type"); op != "" {
+		key += "?op_type=" + op
+	}
+	m.requests[key] = body
+
+	_, _ = io.WriteString(w, `{"acknowledged":true,"_index":"iam_analytics","result":"created"}`)
+}
+
+func (m *mockElasticsearch) request(key string) (map[string]interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	body, ok := m.requests[key]
+
+	return body, ok
+}
+
+func TestElasticsearchPump_SetupIndex(t *testing.T) {
+	tests := []struct {
+		name            string
+		conf            map[string]interface{}
+		templateVersion int
+		// installed is the configuration the installed template is generated from.
+		installed    *ElasticsearchConf
+		wantTemplate bool
+		wantPolicy   bool
+		wantPatterns string
+	}{
+		{
+			name:         "template installed",
+			conf:         map[string]interface{}{"rolling_index": true},
+			wantTemplate: true,
+			wantPatterns: "[iam_analytics iam_analytics-*]",
+		},
+		{
+			name:         "data stream with retention",
+			conf:         map[string]interface{}{"data_stream": true, "ilm_retention": "30d"},
+			wantTemplate: true,
+			wantPolicy:   true,
+			wantPatterns: "[iam_analytics]",
+		},
+		{
+			name:            "template of the same version kept",
+			conf:            map[string]interface{}{},
+			templateVersion: elasticsearchTemplateVersion,
+		},
+		{
+			name:            "managed template of the same settings kept",
+			conf:            map[string]interface{}{},
+			templateVersion: elasticsearchTemplateVersion,
+			installed:       &ElasticsearchConf{IndexName: "iam_analytics"},
+		},
+		{
+			name:            "managed template upgraded to retention and data stream",
+			conf:            map[string]interface{}{"data_stream": true, "ilm_retention": "30d"},
+			templateVersion: elasticsearchTemplateVersion,
+			installed:       &ElasticsearchConf{IndexName: "iam_analytics"},
+			wantTemplate:    true,
+			wantPolicy:      true,
+			wantPatterns:    "[iam_analytics]",
+		},
+		{
+			name:            "newer template kept",
+			conf:            map[string]interface{}{},
+			templateVersion: elasticsearchTemplateVersion + 1,
+		},
+		{
+			name:            "template overwritten",
+			conf:            map[string]interface{}{"template_overwrite": true},
+			templateVersion: elasticsearchTemplateVersion,
+			wantTemplate:    true,
+			wantPatterns:    "[iam_analytics]",
+		},
+		{
+			name: "template disabled",
+			conf: map[string]interface{}{"disable_template": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockElasticsearch{templateVersion: tt.templateVersion, requests: map[string]map[string]interface{}{}}
+			if tt.installed != nil {
+				mock.templateHash = elasticsearchIndexTemplate(tt.installed)["_meta"].(map[string]interface{})["hash"].(string)
+			}
+			server := httptest.NewServer(mock)
+			defer server.Close()
+
+			tt.conf["elasticsearch_url"] = server.URL
+			if err := (&ElasticsearchPump{}).Init(tt.conf); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			template, ok := mock.request("PUT /_index_template/iam_analytics")
+			if ok != tt.wantTemplate {
+				t.Fatalf("template installed = %v, want %v", ok, tt.wantTemplate)
+			}
+			if ok && fmt.Sprint(template["index_patterns"]) != tt.wantPatterns {
+				t.Errorf("index_patterns = %v, want %s", template["index_patterns"], tt.wantPatterns)
+			}
+
+			policy, ok := mock.request("PUT /_ilm/policy/iam_analytics-policy")
+			if ok != tt.wantPolicy {
+				t.Fatalf("policy installed = %v, want %v", ok, tt.wantPolicy)
+			}
+			if ok {
+				phases := policy["policy"].(map[string]interface{})["phases"].(map[string]interface{})
+				if _, ok := phases["hot"]; !ok {
+					t.Errorf("phases = %v, want the rollover of the data stream", phases)
+				}
+
+				settings, _ := template["template"].(map[string]interface{})["settings"].(map[string]interface{})
+				if settings["index.lifecycle.name"] != "iam_analytics-policy" {
+					t.Errorf("template settings = %v, want the ILM policy", settings)
+				}
+				if _, ok := template["data_stream"]; !ok {
+					t.Error("template is not a data stream template")
+				}
+			}
+		})
+	}
+}
+
+func TestElasticsearchPump_WriteDataStream(t *testing.T) {
+	mock := &mockElasticsearch{requests: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	pmp := &ElasticsearchPump{}
+	err := pmp.Init(map[string]interface{}{
+		"elasticsearch_url": server.URL,
+		"data_stream":       true,
+		"disable_bulk":      true,
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	record := analytics.AnalyticsRecord{
+		Username: "colin",
+		Request:  `{"subject":"users:peter","action":"delete","context":{"remoteIP":"10.0.0.1"}}`,
+	}
+	if err := pmp.WriteData(context.Background(), []interface{}{record}); err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+
+	doc, ok := mock.request("POST /iam_analytics/_doc?op_type=create")
+	if !ok {
+		t.Fatalf("no document is created, requests: %v", mock.requests)
+	}
+	parsed, _ := doc[elasticsearchParsedRequestKey].(map[string]interface{})
+	if parsed["action"] != "delete" || parsed["subject"] != "users:peter" || parsed["context"] == nil {
+		t.Errorf("parsedRequest = %v", parsed)
+	}
+}
+
+func TestElasticsearchPump_WriteDataError(t *testing.T) {
+	tests := []struct {
+		name        string
+		disableBulk bool
+	}{
+		{name: "bulk", disableBulk: false},
+		{name: "single", disableBulk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockElasticsearch{failWrites: true, requests: map[string]map[string]interface{}{}}
+			server := httptest.NewServer(mock)
+			defer server.Close()
+
+			pmp := &ElasticsearchPump{}
+			err := pmp.Init(map[string]interface{}{
+				"elasticsearch_url": server.URL,
+				"disable_bulk":      tt.disableBulk,
+			})
+			if err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			record := analytics.AnalyticsRecord{Username: "colin"}
+			if err := pmp.WriteData(context.Background(), []interface{}{record}); err == nil {
+				t.Error("WriteData() error = nil, want the write error")
+			}
+		})
+	}
+}
+
+func TestElasticsearchPump_Init(t *testing.T) {
+	tests := []struct {
+		name string
+		conf map[string]interface{}
+	}{
+		{name: "data stream with rolling index", conf: map[string]interface{}{"data_stream": true, "rolling_index": true}},
+		{name: "data stream without template", conf: map[string]interface{}{"data_stream": true, "disable_template": true}},
+		{name: "invalid retention", conf: map[string]interface{}{"ilm_retention": "30 days"}},
+		{name: "invalid rollover", conf: map[string]interface{}{"ilm_rollover_max_age": "1w"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&ElasticsearchPump{}).Init(tt.conf); err == nil {
+				t.Error("Init() error = nil, want error")
+			}
+		})
+	}
+}
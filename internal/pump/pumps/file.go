@@ -0,0 +1,326 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/marmotedu/errors"
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+const (
+	fileActiveSuffix  = ".ndjson"
+	fileGzipSuffix    = ".gz"
+	fileRotatedLayout = "20060102T150405.000000000"
+
+	defaultFileFilename  = "iam-analytics"
+	defaultFileMaxSizeMB = 100
+)
+
+// FilePump defines a file pump which archives records as newline delimited JSON, with file
+// specific options and common options.
+//
+// Records are appended to <directory>/<filename>.ndjson. The file is rotated to
+// <filename>-<opened at>.ndjson, gzipped if compress is set, when it exceeds max_size_mb or
+// it was opened longer than rotate_interval ago.
+type FilePump struct {
+	fileConf *FileConf
+
+	mu       sync.Mutex
+	file     *os.File
+	writer   *bufio.Writer
+	size     int64
+	openedAt time.Time
+	CommonPumpConfig
+}
+
+// FileConf defines file specific options.
+type FileConf struct {
+	Directory string `mapstructure:"directory"`
+	// Filename is the prefix of the archive files, default iam-analytics.
+	Filename string `mapstructure:"filename"`
+	// MaxSizeMB is the size in megabytes at which the file is rotated, default 100, negative disables it.
+	MaxSizeMB int `mapstructure:"max_size_mb"`
+	// RotateInterval is the maximum time records are appended to the same file, 0 disables it.
+	RotateInterval time.Duration `mapstructure:"rotate_interval"`
+	// Compress gzips the rotated files.
+	Compress bool `mapstructure:"compress"`
+	// MaxAge is how long the rotated files are kept, 0 keeps them forever.
+	MaxAge time.Duration `mapstructure:"max_age"`
+	// MaxFiles is the maximum number of rotated files kept, 0 keeps all of them.
+	MaxFiles int `mapstructure:"max_files"`
+	// Fsync flushes the file to the disk after every batch of records.
+	Fsync bool `mapstructure:"fsync"`
+}
+
+// New create a file pump instance.
+func (f *FilePump) New() Pump {
+	newPump := FilePump{}
+
+	return &newPump
+}
+
+// GetName returns the file pump name.
+func (f *FilePump) GetName() string {
+	return "File Pump"
+}
+
+// Init initialize the file pump instance.
+func (f *FilePump) Init(config interface{}) error {
+	f.fileConf = &FileConf{}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     f.fileConf,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(config); err != nil {
+		return errors.Wrap(err, "failed to decode file configuration")
+	}
+
+	conf := f.fileConf
+	if conf.Directory == "" {
+		return errors.New("directory of the file pump must be set")
+	}
+	if conf.Filename == "" {
+		conf.Filename = defaultFileFilename
+	}
+	if conf.MaxSizeMB == 0 {
+		conf.MaxSizeMB = defaultFileMaxSizeMB
+	}
+	if conf.RotateInterval < 0 || conf.MaxAge < 0 || conf.MaxFiles < 0 {
+		return errors.New("rotate_interval, max_age and max_files of the file pump must not be negative")
+	}
+
+	if err := os.MkdirAll(conf.Directory, 0o750); err != nil {
+		return errors.Wrap(err, "failed to create file pump directory")
+	}
+
+	log.Debugf("File Pump active, archiving records to %s", f.activeName())
+
+	return nil
+}
+
+// WriteData appends the records to the active file, one JSON record per line.
+func (f *FilePump) WriteData(ctx context.Context, data []interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil && f.shouldRotate() {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range data {
+		decoded, _ := v.(analytics.AnalyticsRecord)
+		line, err := json.Marshal(decoded)
+		if err != nil {
+			log.Errorf("Failed to encode analytics record: %s", err.Error())
+
+			continue
+		}
+
+		line = append(line, '\n')
+		if _, err := f.writer.Write(line); err != nil {
+			return errors.Wrapf(err, "failed to write %s", f.activeName())
+		}
+		f.size += int64(len(line))
+	}
+
+	if err := f.writer.Flush(); err != nil {
+		return errors.Wrapf(err, "failed to write %s", f.activeName())
+	}
+
+	if f.fileConf.Fsync {
+		if err := f.file.Sync(); err != nil {
+			return errors.Wrapf(err, "failed to sync %s", f.activeName())
+		}
+	}
+
+	// the records are written already, a failed rotation is retried by the next write.
+	if f.shouldRotate() {
+		if err := f.rotate(); err != nil {
+			log.Errorf("Failed to rotate %s: %s", f.activeName(), err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (f *FilePump) activeName() string {
+	return filepath.Join(f.fileConf.Directory, f.fileConf.Filename+fileActiveSuffix)
+}
+
+func (f *FilePump) open() error {
+	file, err := os.OpenFile(f.activeName(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", f.activeName())
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+
+		return errors.Wrapf(err, "failed to stat %s", f.activeName())
+	}
+
+	f.file = file
+	f.writer = bufio.NewWriter(file)
+	f.size = info.Size()
+	f.openedAt = time.Now()
+
+	return nil
+}
+
+func (f *FilePump) shouldRotate() bool {
+	if f.fileConf.MaxSizeMB > 0 && f.size >= int64(f.fileConf.MaxSizeMB)<<20 {
+		return true
+	}
+
+	return f.fileConf.RotateInterval > 0 && time.Since(f.openedAt) >= f.fileConf.RotateInterval
+}
+
+// rotate renames the active file, compresses it and removes the expired files.
+func (f *FilePump) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close %s", f.activeName())
+	}
+	f.file, f.writer = nil, nil
+
+	rotated := filepath.Join(f.fileConf.Directory,
+		fmt.Sprintf("%s-%s%s", f.fileConf.Filename, f.openedAt.UTC().Format(fileRotatedLayout), fileActiveSuffix))
+	if err := os.Rename(f.activeName(), rotated); err != nil {
+		return errors.Wrapf(err, "failed to rotate %s", f.activeName())
+	}
+
+	if f.fileConf.Compress {
+		if err := gzipFile(rotated); err != nil {
+			// the rotated file is kept uncompressed, the records are not lost.
+			log.Errorf("Failed to compress %s: %s", rotated, err.Error())
+		}
+	}
+
+	f.removeExpired()
+
+	return nil
+}
+
+// removeExpired removes the rotated files older than max_age and the oldest files beyond max_files.
+func (f *FilePump) removeExpired() {
+	if f.fileConf.MaxAge == 0 && f.fileConf.MaxFiles == 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(f.fileConf.Directory)
+	if err != nil {
+		log.Errorf("Failed to list rotated files: %s", err.Error())
+
+		return
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && f.isRotated(entry.Name()) {
+			names = append(names, filepath.Join(f.fileConf.Directory, entry.Name()))
+		}
+	}
+
+	// the rotated files are named after the time they were opened, newest last.
+	sort.Strings(names)
+
+	for i, name := range names {
+		expired := f.fileConf.MaxFiles > 0 && len(names)-i > f.fileConf.MaxFiles
+		if !expired && f.fileConf.MaxAge > 0 {
+			if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > f.fileConf.MaxAge {
+				expired = true
+			}
+		}
+
+		if expired {
+			if err := os.Remove(name); err != nil {
+				log.Warnf("Failed to remove expired file %s: %s", name, err.Error())
+			}
+		}
+	}
+}
+
+// isRotated returns true if name is a file rotated by this pump, <filename>-<timestamp>.ndjson
+// optionally compressed. The files of the pumps with filename as prefix do not match.
+func (f *FilePump) isRotated(name string) bool {
+	timestamp := strings.TrimPrefix(name, f.fileConf.Filename+"-")
+	if timestamp == name {
+		return false
+	}
+
+	timestamp = strings.TrimSuffix(timestamp, fileGzipSuffix)
+	if !strings.HasSuffix(timestamp, fileActiveSuffix) {
+		return false
+	}
+
+	_, err := time.Parse(fileRotatedLayout, strings.TrimSuffix(timestamp, fileActiveSuffix))
+
+	return err == nil
+}
+
+// gzipFile compresses name to name.gz and removes name.
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := name + fileGzipSuffix + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(name)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+
+		return err
+	}
+
+	if err := os.Rename(tmp, name+fileGzipSuffix); err != nil {
+		return err
+	}
+
+	return os.Remove(name)
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marmotedu/component-base/pkg/json"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+)
+
+func readNDJSON(t *testing.T, name string) []analytics.AnalyticsRecord {
+	t.Helper()
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(name, fileGzipSuffix) {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = zr
+	}
+
+	var records []analytics.AnalyticsRecord
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, 2<<20)
+	for scanner.Scan() {
+		var record analytics.AnalyticsRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("line of %s is not a JSON record: %v", name, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return records
+}
+
+func TestFilePump_WriteData(t *testing.T) {
+	large := analytics.AnalyticsRecord{Username: "colin", Request: strings.Repeat("x", 1<<20)}
+	small := analytics.AnalyticsRecord{Username: "james"}
+
+	tests := []struct {
+		name        string
+		meta        map[string]interface{}
+		batches     [][]interface{}
+		pause       time.Duration
+		wantRotated []string
+		wantActive  []string
+	}{
+		{
+			name:        "rotated by size and compressed",
+			meta:        map[string]interface{}{"max_size_mb": 1, "compress": true},
+			batches:     [][]interface{}{{large}, {small}},
+			wantRotated: []string{"colin"},
+			wantActive:  []string{"james"},
+		},
+		{
+			name:        "rotated by time",
+			meta:        map[string]interface{}{"rotate_interval": "20ms", "fsync": true},
+			batches:     [][]interface{}{{large, small}, {small}},
+			pause:       30 * time.Millisecond,
+			wantRotated: []string{"colin", "james"},
+			wantActive:  []string{"james"},
+		},
+		{
+			name:        "retention by count",
+			meta:        map[string]interface{}{"rotate_interval": "1ns", "max_files": 2},
+			batches:     [][]interface{}{{small}, {small}, {large}, {small}},
+			wantRotated: []string{"colin", "james"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.meta["directory"] = dir
+
+			pmp := (&FilePump{}).New()
+			if err := pmp.Init(tt.meta); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			for i, batch := range tt.batches {
+				if i > 0 {
+					time.Sleep(tt.pause)
+				}
+				if err := pmp.WriteData(context.Background(), batch); err != nil {
+					t.Fatalf("WriteData() error = %v", err)
+				}
+			}
+
+			rotated, _ := filepath.Glob(filepath.Join(dir, defaultFileFilename+"-*"))
+			var gotRotated []string
+			for _, name := range rotated {
+				if compress, _ := tt.meta["compress"].(bool); compress != strings.HasSuffix(name, fileGzipSuffix) {
+					t.Errorf("rotated file %s, want compressed %v", name, compress)
+				}
+				for _, record := range readNDJSON(t, name) {
+					gotRotated = append(gotRotated, record.Username)
+				}
+			}
+			if strings.Join(gotRotated, ",") != strings.Join(tt.wantRotated, ",") {
+				t.Errorf("rotated records = %v, want %v", gotRotated, tt.wantRotated)
+			}
+
+			var gotActive []string
+			if _, err := os.Stat(filepath.Join(dir, defaultFileFilename+fileActiveSuffix)); err == nil {
+				for _, record := range readNDJSON(t, filepath.Join(dir, defaultFileFilename+fileActiveSuffix)) {
+					gotActive = append(gotActive, record.Username)
+				}
+			}
+			if strings.Join(gotActive, ",") != strings.Join(tt.wantActive, ",") {
+				t.Errorf("active records = %v, want %v", gotActive, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestFilePump_RemoveExpiredKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	// files of another pump writing iam-analytics-denied to the same directory.
+	others := []string{
+		"iam-analytics-denied.ndjson",
+		"iam-analytics-denied-20200101T000000.000000000.ndjson",
+		"iam-analytics-notes.txt",
+	}
+	for _, name := range others {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pmp := (&FilePump{}).New()
+	err := pmp.Init(map[string]interface{}{"directory": dir, "rotate_interval": "1ns", "max_files": 1})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		data := []interface{}{analytics.AnalyticsRecord{Username: "colin"}}
+		if err := pmp.WriteData(context.Background(), data); err != nil {
+			t.Fatalf("WriteData() error = %v", err)
+		}
+	}
+
+	for _, name := range others {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("file %s of another pump is removed: %v", name, err)
+		}
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, defaultFileFilename+"-2*"))
+	if len(rotated) != 1 {
+		t.Errorf("rotated files = %v, want 1 file", rotated)
+	}
+}
@@ -11,12 +11,17 @@ func init() {
 	availablePumps = make(map[string]Pump)
 
 	// Register all the storage handlers here
+	availablePumps["aggregate"] = &AggregatePump{}
 	availablePumps["csv"] = &CSVPump{}
+	availablePumps["file"] = &FilePump{}
 	availablePumps["mongo"] = &MongoPump{}
 	availablePumps["dummy"] = &DummyPump{}
 	availablePumps["elasticsearch"] = &ElasticsearchPump{}
 	availablePumps["influx"] = &InfluxPump{}
+	availablePumps["plugin"] = &PluginPump{}
 	availablePumps["prometheus"] = &PrometheusPump{}
 	availablePumps["kafka"] = &KafkaPump{}
+	availablePumps["sql"] = &SQLPump{}
 	availablePumps["syslog"] = &SyslogPump{}
+	availablePumps["webhook"] = &WebhookPump{}
 }
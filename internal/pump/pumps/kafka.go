@@ -7,25 +7,39 @@ package pumps
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 	"time"
 
-	"github.com/marmotedu/component-base/pkg/json"
 	"github.com/marmotedu/errors"
 	"github.com/mitchellh/mapstructure"
 	"github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl"
 	"github.com/segmentio/kafka-go/sasl/plain"
 	"github.com/segmentio/kafka-go/sasl/scram"
-	"github.com/segmentio/kafka-go/snappy"
 
 	"github.com/marmotedu/iam/internal/pump/analytics"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
+// Keys of the kafka messages, the records of a key are written to the same partition in order.
+const (
+	KafkaMessageKeyUsername = "username"
+	KafkaMessageKeyResource = "resource"
+)
+
+// Balancers distributing the kafka messages to the partitions.
+const (
+	KafkaBalancerHash       = "hash"
+	KafkaBalancerMurmur2    = "murmur2"
+	KafkaBalancerLeastBytes = "least_bytes"
+)
+
 // KafkaPump defines a kafka pump with kafka specific options and common options.
+// The writer is kept for the lifetime of the pump.
 type KafkaPump struct {
-	kafkaConf    *KafkaConf
-	writerConfig kafka.WriterConfig
+	kafkaConf *KafkaConf
+	writer    *kafka.Writer
+	encoder   kafkaEncoder
 	CommonPumpConfig
 }
 
@@ -48,6 +62,22 @@ type KafkaConf struct {
 	Compressed            bool              `mapstructure:"compressed"`
 	UseSSL                bool              `mapstructure:"use_ssl"`
 	SSLInsecureSkipVerify bool              `mapstructure:"ssl_insecure_skip_verify"`
+	// MessageKey is the field keying the messages, username or resource. The messages are not keyed if empty.
+	MessageKey string `mapstructure:"message_key"`
+	// Balancer is hash, murmur2 or least_bytes, default hash with MessageKey and least_bytes without it.
+	// murmur2 distributes the keys like the java client.
+	Balancer string `mapstructure:"balancer"`
+	// RequiredAcks is none, one or all, default all.
+	RequiredAcks string        `mapstructure:"required_acks"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	BatchBytes   int64         `mapstructure:"batch_bytes"`
+	BatchTimeout time.Duration `mapstructure:"batch_timeout"`
+	// Encoding is json, avro or protobuf, default json.
+	Encoding string `mapstructure:"encoding"`
+	// SchemaFile is the avro schema or protobuf FileDescriptorSet, the versioned schemas of the
+	// schema directory by default. SchemaMessage is the full name of the protobuf message.
+	SchemaFile    string `mapstructure:"schema_file"`
+	SchemaMessage string `mapstructure:"schema_message"`
 }
 
 // New create a kafka pump instance.
@@ -66,9 +96,15 @@ func (k *KafkaPump) GetName() string {
 func (k *KafkaPump) Init(config interface{}) error {
 	// Read configuration file
 	k.kafkaConf = &KafkaConf{}
-	err := mapstructure.Decode(config, &k.kafkaConf)
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     k.kafkaConf,
+	})
 	if err != nil {
-		log.Fatalf("Failed to decode configuration: %s", err.Error())
+		return err
+	}
+	if err := decoder.Decode(config); err != nil {
+		return errors.Wrap(err, "failed to decode kafka configuration")
 	}
 
 	var tlsConfig *tls.Config
@@ -113,7 +149,7 @@ func (k *KafkaPump) Init(config interface{}) error {
 		var mechErr error
 		mechanism, mechErr = scram.Mechanism(algorithm, k.kafkaConf.Username, k.kafkaConf.Password)
 		if mechErr != nil {
-			log.Fatalf("Failed initialize kafka mechanism: %s", mechErr.Error())
+			return errors.Wrap(mechErr, "failed initialize kafka mechanism")
 		}
 	default:
 		log.Warn(
@@ -122,25 +158,56 @@ func (k *KafkaPump) Init(config interface{}) error {
 		)
 	}
 
-	// Kafka writer connection config
-	dialer := &kafka.Dialer{
-		Timeout:       k.kafkaConf.Timeout,
-		ClientID:      k.kafkaConf.ClientID,
-		TLS:           tlsConfig,
-		SASLMechanism: mechanism,
+	switch k.kafkaConf.MessageKey {
+	case "", KafkaMessageKeyUsername, KafkaMessageKeyResource:
+	default:
+		return fmt.Errorf("unknown kafka message_key %q, must be %s or %s",
+			k.kafkaConf.MessageKey, KafkaMessageKeyUsername, KafkaMessageKeyResource)
+	}
+
+	balancer, err := kafkaBalancer(k.kafkaConf.Balancer, k.kafkaConf.MessageKey != "")
+	if err != nil {
+		return err
+	}
+
+	requiredAcks, err := kafkaRequiredAcks(k.kafkaConf.RequiredAcks)
+	if err != nil {
+		return err
+	}
+
+	k.encoder, err = newKafkaEncoder(k.kafkaConf.Encoding, k.kafkaConf.SchemaFile, k.kafkaConf.SchemaMessage,
+		k.kafkaConf.MetaData)
+	if err != nil {
+		return err
+	}
+	// a schema not matching the records fails now rather than on every write.
+	if _, err := k.encoder.encode(analytics.AnalyticsRecord{}); err != nil {
+		return errors.Wrap(err, "kafka schema doesn't match the records")
 	}
 
-	k.writerConfig.Brokers = k.kafkaConf.Broker
-	k.writerConfig.Topic = k.kafkaConf.Topic
-	k.writerConfig.Balancer = &kafka.LeastBytes{}
-	k.writerConfig.Dialer = dialer
-	k.writerConfig.WriteTimeout = k.kafkaConf.Timeout
-	k.writerConfig.ReadTimeout = k.kafkaConf.Timeout
+	k.writer = &kafka.Writer{
+		Addr:         kafka.TCP(k.kafkaConf.Broker...),
+		Topic:        k.kafkaConf.Topic,
+		Balancer:     balancer,
+		BatchSize:    k.kafkaConf.BatchSize,
+		BatchBytes:   k.kafkaConf.BatchBytes,
+		BatchTimeout: k.kafkaConf.BatchTimeout,
+		ReadTimeout:  k.kafkaConf.Timeout,
+		WriteTimeout: k.kafkaConf.Timeout,
+		RequiredAcks: requiredAcks,
+		// Kafka writer connection config
+		Transport: &kafka.Transport{
+			DialTimeout: k.kafkaConf.Timeout,
+			ClientID:    k.kafkaConf.ClientID,
+			TLS:         tlsConfig,
+			SASL:        mechanism,
+		},
+	}
 	if k.kafkaConf.Compressed {
-		k.writerConfig.CompressionCodec = snappy.NewCompressionCodec()
+		k.writer.Compression = kafka.Snappy
 	}
 
-	log.Infof("Kafka config: %s", k.writerConfig)
+	log.Infof("Kafka Pump active, writing to topic %s of %v", k.kafkaConf.Topic, k.kafkaConf.Broker)
 
 	return nil
 }
@@ -149,50 +216,101 @@ func (k *KafkaPump) Init(config interface{}) error {
 func (k *KafkaPump) WriteData(ctx context.Context, data []interface{}) error {
 	startTime := time.Now()
 	log.Infof("Writing %d records ...", len(data))
+
+	kafkaMessages, err := k.messages(data)
+	if err != nil {
+		return err
+	}
+
+	// Send kafka message
+	if err := k.writer.WriteMessages(ctx, kafkaMessages...); err != nil {
+		return errors.Wrapf(err, "failed to write messages to kafka topic %s", k.kafkaConf.Topic)
+	}
+	log.Debugf("ElapsedTime in seconds for %d records %v", len(data), time.Since(startTime))
+
+	return nil
+}
+
+// Close flushes the pending messages and closes the writer.
+func (k *KafkaPump) Close() error {
+	if k.writer == nil {
+		return nil
+	}
+
+	return k.writer.Close()
+}
+
+func (k *KafkaPump) messages(data []interface{}) ([]kafka.Message, error) {
+	headers := k.encoder.headers()
 	kafkaMessages := make([]kafka.Message, len(data))
 	for i, v := range data {
-		// Build message format
 		decoded, _ := v.(analytics.AnalyticsRecord)
-		message := Message{
-			"timestamp":  decoded.TimeStamp,
-			"username":   decoded.Username,
-			"effect":     decoded.Effect,
-			"conclusion": decoded.Conclusion,
-			"request":    decoded.Request,
-			"policies":   decoded.Policies,
-			"deciders":   decoded.Deciders,
-			"expireAt":   decoded.ExpireAt,
-		}
-		// Add static metadata to json
-		for key, value := range k.kafkaConf.MetaData {
-			message[key] = value
-		}
 
-		// Transform object to json string
-		json, jsonError := json.Marshal(message)
-		if jsonError != nil {
-			log.Error("unable to marshal message", log.String("error", jsonError.Error()))
+		value, err := k.encoder.encode(decoded)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to encode message")
 		}
 
 		// Kafka message structure
 		kafkaMessages[i] = kafka.Message{
-			Time:  time.Now(),
-			Value: json,
+			Key:     k.messageKey(decoded),
+			Value:   value,
+			Headers: headers,
+			Time:    time.Now(),
 		}
 	}
-	// Send kafka message
-	kafkaError := k.write(ctx, kafkaMessages)
-	if kafkaError != nil {
-		log.Error("unable to write message", log.String("error", kafkaError.Error()))
-	}
-	log.Debugf("ElapsedTime in seconds for %d records %v", len(data), time.Since(startTime))
 
-	return nil
+	return kafkaMessages, nil
+}
+
+// messageKey returns the key of the message of record, the records without the key field have an empty key.
+func (k *KafkaPump) messageKey(record analytics.AnalyticsRecord) []byte {
+	switch k.kafkaConf.MessageKey {
+	case KafkaMessageKeyUsername:
+		return []byte(record.Username)
+	case KafkaMessageKeyResource:
+		request, err := analytics.DecodeRequest(record.Request)
+		if err != nil {
+			return nil
+		}
+		resource, _ := request["resource"].(string)
+
+		return []byte(resource)
+	default:
+		return nil
+	}
 }
 
-func (k *KafkaPump) write(ctx context.Context, messages []kafka.Message) error {
-	kafkaWriter := kafka.NewWriter(k.writerConfig)
-	defer kafkaWriter.Close()
+func kafkaBalancer(name string, keyed bool) (kafka.Balancer, error) {
+	if name == "" {
+		name = KafkaBalancerLeastBytes
+		if keyed {
+			name = KafkaBalancerHash
+		}
+	}
 
-	return kafkaWriter.WriteMessages(ctx, messages...)
+	switch name {
+	case KafkaBalancerHash:
+		return &kafka.Hash{}, nil
+	case KafkaBalancerMurmur2:
+		return kafka.Murmur2Balancer{}, nil
+	case KafkaBalancerLeastBytes:
+		return &kafka.LeastBytes{}, nil
+	default:
+		return nil, fmt.Errorf("unknown kafka balancer %q, must be %s, %s or %s",
+			name, KafkaBalancerHash, KafkaBalancerMurmur2, KafkaBalancerLeastBytes)
+	}
+}
+
+func kafkaRequiredAcks(acks string) (kafka.RequiredAcks, error) {
+	switch acks {
+	case "", "all":
+		return kafka.RequireAll, nil
+	case "one":
+		return kafka.RequireOne, nil
+	case "none":
+		return kafka.RequireNone, nil
+	default:
+		return 0, fmt.Errorf("unknown kafka required_acks %q, must be none, one or all", acks)
+	}
 }
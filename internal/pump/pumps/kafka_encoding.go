@@ -0,0 +1,332 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	// embed the default avro schema.
+	_ "embed"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/marmotedu/errors"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+)
+
+// Encodings of the kafka messages.
+const (
+	KafkaEncodingJSON     = "json"
+	KafkaEncodingAvro     = "avro"
+	KafkaEncodingProtobuf = "protobuf"
+)
+
+const (
+	// kafkaContentTypeHeader and kafkaSchemaHeader are the message headers identifying the
+	// encoding and the full name of the schema of the value.
+	kafkaContentTypeHeader = "content-type"
+	kafkaSchemaHeader      = "schema"
+
+	// defaultProtoSchemaMessage is the message of protoAnalyticsRecordV1.
+	defaultProtoSchemaMessage = "iam.analytics.v1.AnalyticsRecord"
+)
+
+// avroAnalyticsRecordV1 is the default avro schema of the records.
+//
+//go:embed schema/analytics_record.v1.avsc
+var avroAnalyticsRecordV1 string
+
+// kafkaEncoder encodes the records to the values of the kafka messages.
+type kafkaEncoder interface {
+	encode(record analytics.AnalyticsRecord) ([]byte, error)
+	// headers identify the encoding and the schema of the values.
+	headers() []kafka.Header
+}
+
+// newKafkaEncoder returns the encoder of encoding. The avro schema file is an avro schema, the
+// protobuf schema file is a FileDescriptorSet, like generated by protoc --descriptor_set_out,
+// schemaMessage is the full name of its message. The versioned schemas of the schema directory
+// are used by default. The metadata is added to the records.
+func newKafkaEncoder(encoding, schemaFile, schemaMessage string, metadata map[string]string) (kafkaEncoder, error) {
+	switch encoding {
+	case "", KafkaEncodingJSON:
+		return &jsonKafkaEncoder{metadata: metadata}, nil
+	case KafkaEncodingAvro:
+		return newAvroKafkaEncoder(schemaFile, metadata)
+	case KafkaEncodingProtobuf:
+		return newProtobufKafkaEncoder(schemaFile, schemaMessage, metadata)
+	default:
+		return nil, fmt.Errorf("unknown kafka encoding %q, must be %s, %s or %s",
+			encoding, KafkaEncodingJSON, KafkaEncodingAvro, KafkaEncodingProtobuf)
+	}
+}
+
+// nativeRecord returns the fields of the record by their schema names.
+func nativeRecord(record analytics.AnalyticsRecord, metadata map[string]string) map[string]interface{} {
+	nativeMetadata := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		nativeMetadata[key] = value
+	}
+
+	return map[string]interface{}{
+		"timestamp":  record.TimeStamp,
+		"username":   record.Username,
+		"effect":     record.Effect,
+		"conclusion": record.Conclusion,
+		"request":    record.Request,
+		"policies":   record.Policies,
+		"deciders":   record.Deciders,
+		"expireAt":   record.ExpireAt,
+		"metadata":   nativeMetadata,
+	}
+}
+
+// jsonKafkaEncoder encodes the records to JSON objects, the metadata are added as top level fields.
+type jsonKafkaEncoder struct {
+	metadata map[string]string
+}
+
+func (e *jsonKafkaEncoder) encode(record analytics.AnalyticsRecord) ([]byte, error) {
+	message := Message{
+		"timestamp":  record.TimeStamp,
+		"username":   record.Username,
+		"effect":     record.Effect,
+		"conclusion": record.Conclusion,
+		"request":    record.Request,
+		"policies":   record.Policies,
+		"deciders":   record.Deciders,
+		"expireAt":   record.ExpireAt,
+	}
+	// Add static metadata to json
+	for key, value := range e.metadata {
+		message[key] = value
+	}
+
+	return json.Marshal(message)
+}
+
+func (e *jsonKafkaEncoder) headers() []kafka.Header {
+	return []kafka.Header{{Key: kafkaContentTypeHeader, Value: []byte("application/json")}}
+}
+
+// avroKafkaEncoder encodes the records to avro binary, without the schema.
+type avroKafkaEncoder struct {
+	codec    *goavro.Codec
+	schema   string
+	metadata map[string]string
+}
+
+func newAvroKafkaEncoder(schemaFile string, metadata map[string]string) (*avroKafkaEncoder, error) {
+	schema := avroAnalyticsRecordV1
+	if schemaFile != "" {
+		content, err := os.ReadFile(schemaFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read avro schema")
+		}
+		schema = string(content)
+	}
+
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid avro schema %s", schemaFile)
+	}
+
+	var named struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	}
+	if err := json.Unmarshal([]byte(schema), &named); err != nil || named.Name == "" {
+		return nil, fmt.Errorf("avro schema %s is not a named record", schemaFile)
+	}
+	name := named.Name
+	if named.Namespace != "" {
+		name = named.Namespace + "." + named.Name
+	}
+
+	return &avroKafkaEncoder{codec: codec, schema: name, metadata: metadata}, nil
+}
+
+func (e *avroKafkaEncoder) encode(record analytics.AnalyticsRecord) ([]byte, error) {
+	return e.codec.BinaryFromNative(nil, nativeRecord(record, e.metadata))
+}
+
+func (e *avroKafkaEncoder) headers() []kafka.Header {
+	return []kafka.Header{
+		{Key: kafkaContentTypeHeader, Value: []byte("application/avro")},
+		{Key: kafkaSchemaHeader, Value: []byte(e.schema)},
+	}
+}
+
+// protobufKafkaEncoder encodes the records to protobuf messages. The fields of the message are
+// set by their names or json names, the fields unknown to the records are left empty.
+type protobufKafkaEncoder struct {
+	descriptor protoreflect.MessageDescriptor
+	metadata   map[string]string
+}
+
+func newProtobufKafkaEncoder(schemaFile, schemaMessage string, metadata map[string]string) (*protobufKafkaEncoder, error) {
+	if schemaFile == "" {
+		descriptor, err := protoAnalyticsRecordV1()
+		if err != nil {
+			return nil, err
+		}
+
+		return &protobufKafkaEncoder{descriptor: descriptor, metadata: metadata}, nil
+	}
+
+	content, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read protobuf schema")
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(content, set); err != nil {
+		return nil, errors.Wrapf(err, "protobuf schema %s is not a FileDescriptorSet", schemaFile)
+	}
+
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid protobuf schema %s", schemaFile)
+	}
+
+	if schemaMessage == "" {
+		schemaMessage = defaultProtoSchemaMessage
+	}
+	found, err := files.FindDescriptorByName(protoreflect.FullName(schemaMessage))
+	if err != nil {
+		return nil, errors.Wrapf(err, "protobuf schema %s has no message %s", schemaFile, schemaMessage)
+	}
+	descriptor, ok := found.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s of protobuf schema %s is not a message", schemaMessage, schemaFile)
+	}
+
+	return &protobufKafkaEncoder{descriptor: descriptor, metadata: metadata}, nil
+}
+
+func (e *protobufKafkaEncoder) encode(record analytics.AnalyticsRecord) ([]byte, error) {
+	native := nativeRecord(record, e.metadata)
+	message := dynamicpb.NewMessage(e.descriptor)
+
+	fields := e.descriptor.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+
+		value, ok := native[field.JSONName()]
+		if !ok {
+			if value, ok = native[string(field.Name())]; !ok {
+				continue
+			}
+		}
+
+		protoValue, err := protobufValue(message, field, value)
+		if err != nil {
+			return nil, err
+		}
+		message.Set(field, protoValue)
+	}
+
+	return proto.Marshal(message)
+}
+
+func (e *protobufKafkaEncoder) headers() []kafka.Header {
+	return []kafka.Header{
+		{Key: kafkaContentTypeHeader, Value: []byte("application/x-protobuf")},
+		{Key: kafkaSchemaHeader, Value: []byte(e.descriptor.FullName())},
+	}
+}
+
+// protobufValue converts a field of the native record to a value of field. The times are
+// converted to unix milliseconds.
+func protobufValue(message *dynamicpb.Message, field protoreflect.FieldDescriptor, value interface{}) (protoreflect.Value, error) {
+	if t, ok := value.(time.Time); ok {
+		value = t.UnixMilli()
+	}
+
+	switch {
+	case field.IsMap():
+		native, ok := value.(map[string]interface{})
+		if ok && field.MapKey().Kind() == protoreflect.StringKind && field.MapValue().Kind() == protoreflect.StringKind {
+			m := message.NewField(field).Map()
+			for key, v := range native {
+				m.Set(protoreflect.ValueOfString(key).MapKey(), protoreflect.ValueOfString(fmt.Sprint(v)))
+			}
+
+			return protoreflect.ValueOfMap(m), nil
+		}
+	case field.IsList():
+	case field.Kind() == protoreflect.StringKind:
+		if s, ok := value.(string); ok {
+			return protoreflect.ValueOfString(s), nil
+		}
+	case field.Kind() == protoreflect.Int64Kind, field.Kind() == protoreflect.Sint64Kind,
+		field.Kind() == protoreflect.Sfixed64Kind:
+		if i, ok := value.(int64); ok {
+			return protoreflect.ValueOfInt64(i), nil
+		}
+	}
+
+	return protoreflect.Value{}, fmt.Errorf("field %s of protobuf message %s can't hold %T",
+		field.Name(), field.ContainingMessage().FullName(), value)
+}
+
+// protoAnalyticsRecordV1 returns the descriptor of schema/analytics_record.v1.proto.
+func protoAnalyticsRecordV1() (protoreflect.MessageDescriptor, error) {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   typ.Enum(),
+		}
+	}
+
+	metadata := field("metadata", 9, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	metadata.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	metadata.TypeName = proto.String("." + defaultProtoSchemaMessage + ".MetadataEntry")
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("schema/analytics_record.v1.proto"),
+		Package: proto.String("iam.analytics.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("AnalyticsRecord"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("timestamp", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+				field("username", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("effect", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("conclusion", 4, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("request", 5, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("policies", 6, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("deciders", 7, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("expire_at", 8, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+				metadata,
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("MetadataEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+		}},
+	}
+
+	descriptor, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid protobuf schema analytics_record.v1.proto")
+	}
+
+	return descriptor.Messages().Get(0), nil
+}
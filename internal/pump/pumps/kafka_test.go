@@ -0,0 +1,213 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+)
+
+var kafkaTestRecord = analytics.AnalyticsRecord{
+	TimeStamp: 1609459200,
+	Username:  "colin",
+	Effect:    "allow",
+	Request:   `{"resource":"resources:articles:ladon","subject":"users:peter"}`,
+	ExpireAt:  time.Unix(1609459260, 0).UTC(),
+}
+
+func initKafkaPump(t *testing.T, conf map[string]interface{}) *KafkaPump {
+	t.Helper()
+
+	conf["broker"] = []string{"127.0.0.1:9092"}
+	conf["topic"] = "iam-analytics"
+
+	pmp := (&KafkaPump{}).New().(*KafkaPump)
+	if err := pmp.Init(conf); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	t.Cleanup(func() { _ = pmp.Close() })
+
+	return pmp
+}
+
+func TestKafkaPump_Messages(t *testing.T) {
+	tests := []struct {
+		name         string
+		conf         map[string]interface{}
+		wantKey      string
+		wantBalancer kafka.Balancer
+		wantAcks     kafka.RequiredAcks
+	}{
+		{
+			name:         "unkeyed",
+			conf:         map[string]interface{}{},
+			wantBalancer: &kafka.LeastBytes{},
+			wantAcks:     kafka.RequireAll,
+		},
+		{
+			name:         "keyed by username",
+			conf:         map[string]interface{}{"message_key": "username", "required_acks": "one"},
+			wantKey:      "colin",
+			wantBalancer: &kafka.Hash{},
+			wantAcks:     kafka.RequireOne,
+		},
+		{
+			name:         "keyed by resource with murmur2",
+			conf:         map[string]interface{}{"message_key": "resource", "balancer": "murmur2", "required_acks": "none"},
+			wantKey:      "resources:articles:ladon",
+			wantBalancer: kafka.Murmur2Balancer{},
+			wantAcks:     kafka.RequireNone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pmp := initKafkaPump(t, tt.conf)
+
+			if got, want := fmt.Sprintf("%T", pmp.writer.Balancer), fmt.Sprintf("%T", tt.wantBalancer); got != want {
+				t.Errorf("Balancer = %s, want %s", got, want)
+			}
+			if pmp.writer.RequiredAcks != tt.wantAcks {
+				t.Errorf("RequiredAcks = %v, want %v", pmp.writer.RequiredAcks, tt.wantAcks)
+			}
+
+			messages, err := pmp.messages([]interface{}{kafkaTestRecord})
+			if err != nil {
+				t.Fatalf("messages() error = %v", err)
+			}
+			if string(messages[0].Key) != tt.wantKey {
+				t.Errorf("Key = %s, want %s", messages[0].Key, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestKafkaEncoder_JSON(t *testing.T) {
+	encoder, err := newKafkaEncoder("", "", "", map[string]string{"cluster": "dev"})
+	if err != nil {
+		t.Fatalf("newKafkaEncoder() error = %v", err)
+	}
+
+	value, err := encoder.encode(kafkaTestRecord)
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+
+	var message map[string]interface{}
+	if err := json.Unmarshal(value, &message); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if message["username"] != "colin" || message["cluster"] != "dev" {
+		t.Errorf("message = %v", message)
+	}
+}
+
+func TestKafkaEncoder_Avro(t *testing.T) {
+	encoder, err := newKafkaEncoder(KafkaEncodingAvro, "", "", map[string]string{"cluster": "dev"})
+	if err != nil {
+		t.Fatalf("newKafkaEncoder() error = %v", err)
+	}
+
+	value, err := encoder.encode(kafkaTestRecord)
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+
+	codec, _ := goavro.NewCodec(avroAnalyticsRecordV1)
+	native, _, err := codec.NativeFromBinary(value)
+	if err != nil {
+		t.Fatalf("NativeFromBinary() error = %v", err)
+	}
+
+	record, _ := native.(map[string]interface{})
+	metadata, _ := record["metadata"].(map[string]interface{})
+	if record["username"] != "colin" || record["timestamp"] != int64(1609459200) ||
+		!record["expireAt"].(time.Time).Equal(kafkaTestRecord.ExpireAt) || metadata["cluster"] != "dev" {
+		t.Errorf("record = %v", record)
+	}
+
+	if schema := string(encoder.headers()[1].Value); schema != "iam.analytics.v1.AnalyticsRecord" {
+		t.Errorf("schema header = %s", schema)
+	}
+}
+
+func TestKafkaEncoder_Protobuf(t *testing.T) {
+	descriptor, err := protoAnalyticsRecordV1()
+	if err != nil {
+		t.Fatalf("protoAnalyticsRecordV1() error = %v", err)
+	}
+
+	// the descriptor set of the default schema, as generated by protoc --descriptor_set_out.
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(descriptor.ParentFile())},
+	}
+	content, _ := proto.Marshal(set)
+	schemaFile := filepath.Join(t.TempDir(), "analytics_record.v1.pb")
+	if err := os.WriteFile(schemaFile, content, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	for _, file := range []string{"", schemaFile} {
+		encoder, err := newKafkaEncoder(KafkaEncodingProtobuf, file, "", map[string]string{"cluster": "dev"})
+		if err != nil {
+			t.Fatalf("newKafkaEncoder(%q) error = %v", file, err)
+		}
+
+		value, err := encoder.encode(kafkaTestRecord)
+		if err != nil {
+			t.Fatalf("encode() error = %v", err)
+		}
+
+		message := dynamicpb.NewMessage(descriptor)
+		if err := proto.Unmarshal(value, message); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+
+		fields := descriptor.Fields()
+		metadata := message.Get(fields.ByName("metadata")).Map()
+		if message.Get(fields.ByName("username")).String() != "colin" ||
+			message.Get(fields.ByName("expire_at")).Int() != kafkaTestRecord.ExpireAt.UnixMilli() ||
+			metadata.Get(protoreflect.ValueOfString("cluster").MapKey()).String() != "dev" {
+			t.Errorf("message = %v", message)
+		}
+	}
+}
+
+func TestKafkaPump_Init(t *testing.T) {
+	badSchema := filepath.Join(t.TempDir(), "bad.avsc")
+	_ = os.WriteFile(badSchema, []byte(`{"type":"record","name":"R","fields":[{"name":"timestamp","type":"string"}]}`), 0o600)
+
+	tests := []struct {
+		name string
+		conf map[string]interface{}
+	}{
+		{name: "unknown message key", conf: map[string]interface{}{"message_key": "subject"}},
+		{name: "unknown balancer", conf: map[string]interface{}{"balancer": "random"}},
+		{name: "unknown required acks", conf: map[string]interface{}{"required_acks": "2"}},
+		{name: "unknown encoding", conf: map[string]interface{}{"encoding": "xml"}},
+		{name: "schema not matching", conf: map[string]interface{}{"encoding": "avro", "schema_file": badSchema}},
+		{name: "missing schema", conf: map[string]interface{}{"encoding": "protobuf", "schema_file": "/nonexistent.pb"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&KafkaPump{}).Init(tt.conf); err == nil {
+				t.Error("Init() error = nil, want error")
+			}
+		})
+	}
+}
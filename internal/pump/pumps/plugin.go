@@ -0,0 +1,375 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/marmotedu/errors"
+	"github.com/mitchellh/mapstructure"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/pump/plugin"
+)
+
+const (
+	defaultPluginStartTimeout        = 10 * time.Second
+	defaultPluginHealthCheckInterval = 10 * time.Second
+	// maxPluginRestartBackoff is the maximum time to wait before restarting a crashed plugin.
+	maxPluginRestartBackoff = 30 * time.Second
+	// pluginStopTimeout is how long a plugin has to exit after SIGTERM before it is killed.
+	pluginStopTimeout = 5 * time.Second
+)
+
+// PluginPump defines a pump which writes the records with an out-of-process plugin, with plugin
+// specific options and common options. See package github.com/marmotedu/iam/pkg/pump/plugin.
+//
+// The plugin is launched from command and restarted when it crashes or fails the health check,
+// or it is a running plugin served on address. The plugin is initialized with config every time
+// it becomes healthy, the records written while it is not ready fail and are retried.
+type PluginPump struct {
+	pluginConf *PluginConf
+	conn       *grpc.ClientConn
+	client     *plugin.Client
+	health     healthpb.HealthClient
+
+	mu      sync.Mutex
+	ready   bool
+	cmd     *exec.Cmd
+	exited  chan struct{}
+	stopped chan struct{}
+	// supervised is closed when supervise returns, nil if the plugin is not launched.
+	supervised chan struct{}
+	CommonPumpConfig
+}
+
+// PluginConf defines plugin specific options.
+type PluginConf struct {
+	// Command is the plugin executable launched by iam-pump, exclusive with Address.
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+	// Env is the extra environment of the plugin process, in KEY=VALUE form.
+	Env []string `mapstructure:"env"`
+	// Address of a running plugin, unix:///path/to/socket or host:port.
+	Address string `mapstructure:"address"`
+	// Config is passed to the Init method of the plugin.
+	Config map[string]interface{} `mapstructure:"config"`
+	// StartTimeout is how long to wait for the plugin to be healthy, default 10s.
+	StartTimeout time.Duration `mapstructure:"start_timeout"`
+	// HealthCheckInterval is how often the plugin health is checked, default 10s.
+	HealthCheckInterval time.Duration `mapstructure:"health_check_interval"`
+}
+
+// New create a plugin pump instance.
+func (p *PluginPump) New() Pump {
+	newPump := PluginPump{}
+
+	return &newPump
+}
+
+// GetName returns the plugin pump name.
+func (p *PluginPump) GetName() string {
+	if p.pluginConf != nil && p.pluginConf.Command != "" {
+		return fmt.Sprintf("Plugin Pump (%s)", filepath.Base(p.pluginConf.Command))
+	}
+
+	return "Plugin Pump"
+}
+
+// Init initialize the plugin pump instance, the plugin is launched if needed and initialized.
+func (p *PluginPump) Init(config interface{}) error {
+	p.pluginConf = &PluginConf{}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     p.pluginConf,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(config); err != nil {
+		return errors.Wrap(err, "failed to decode plugin configuration")
+	}
+
+	conf := p.pluginConf
+	if (conf.Command == "") == (conf.Address == "") {
+		return errors.New("exactly one of command and address of the plugin pump must be set")
+	}
+	if conf.StartTimeout <= 0 {
+		conf.StartTimeout = defaultPluginStartTimeout
+	}
+	if conf.HealthCheckInterval <= 0 {
+		conf.HealthCheckInterval = defaultPluginHealthCheckInterval
+	}
+	if conf.Address == "" {
+		conf.Address = fmt.Sprintf("unix://%s", filepath.Join(os.TempDir(),
+			fmt.Sprintf("iam-pump-plugin-%d-%d.sock", os.Getpid(), time.Now().UnixNano())))
+	}
+
+	// the connection is kept for the lifetime of the pump, it reconnects to restarted plugins.
+	p.conn, err = grpc.Dial(conf.Address, grpc.WithInsecure())
+	if err != nil {
+		return errors.Wrapf(err, "failed to dial plugin %s", conf.Address)
+	}
+	p.client = plugin.NewClient(p.conn)
+	p.health = healthpb.NewHealthClient(p.conn)
+	p.stopped = make(chan struct{})
+
+	if conf.Command != "" {
+		if err := p.start(); err != nil {
+			_ = p.conn.Close()
+
+			return err
+		}
+		p.supervised = make(chan struct{})
+		go p.supervise()
+	}
+
+	if err := p.initPlugin(conf.StartTimeout); err != nil {
+		_ = p.Close()
+
+		return err
+	}
+
+	go p.healthLoop()
+
+	log.Debugf("%s active on %s", p.GetName(), conf.Address)
+
+	return nil
+}
+
+// WriteData writes the records with the plugin.
+func (p *PluginPump) WriteData(ctx context.Context, data []interface{}) error {
+	p.mu.Lock()
+	ready := p.ready
+	p.mu.Unlock()
+	if !ready {
+		return errors.Errorf("plugin %s is not ready", p.pluginConf.Address)
+	}
+
+	log.Debugf("Attempting to write %d records...", len(data))
+
+	if err := p.client.WriteData(ctx, data); err != nil {
+		return errors.Wrapf(err, "plugin %s failed to write records", p.pluginConf.Address)
+	}
+
+	return nil
+}
+
+// Close stops the plugin process and closes the connection.
+func (p *PluginPump) Close() error {
+	p.mu.Lock()
+	select {
+	case <-p.stopped:
+		p.mu.Unlock()
+
+		return nil
+	default:
+		close(p.stopped)
+	}
+	p.ready = false
+	p.mu.Unlock()
+
+	// no plugin is launched once supervise returned.
+	if p.supervised != nil {
+		<-p.supervised
+	}
+
+	p.mu.Lock()
+	cmd, exited := p.cmd, p.exited
+	p.mu.Unlock()
+
+	if cmd != nil {
+		_ = cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-exited:
+		case <-time.After(pluginStopTimeout):
+			_ = cmd.Process.Kill()
+			<-exited
+		}
+	}
+
+	return p.conn.Close()
+}
+
+// start launches the plugin process, unless the pump is closed.
+func (p *PluginPump) start() error {
+	conf := p.pluginConf
+
+	// the process is launched under the lock, so that Close either sees it or stops the launch.
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.stopped:
+		return errors.New("plugin pump is closed")
+	default:
+	}
+
+	// nolint: gosec
+	cmd := exec.Command(conf.Command, conf.Args...)
+	cmd.Env = append(append(os.Environ(), conf.Env...), plugin.AddressEnv+"="+conf.Address)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return errors.Wrapf(err, "failed to launch plugin %s", conf.Command)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			log.Warnf("Plugin %s exited: %s", conf.Command, err.Error())
+		}
+		close(exited)
+	}()
+
+	p.cmd, p.exited = cmd, exited
+
+	return nil
+}
+
+// supervise restarts the plugin process when it exits, with exponential backoff.
+func (p *PluginPump) supervise() {
+	defer close(p.supervised)
+
+	backoff := time.Second
+	for {
+		p.mu.Lock()
+		exited := p.exited
+		p.mu.Unlock()
+
+		select {
+		case <-exited:
+		case <-p.stopped:
+			return
+		}
+
+		p.mu.Lock()
+		p.ready = false
+		p.mu.Unlock()
+
+		select {
+		case <-time.After(backoff):
+		case <-p.stopped:
+			return
+		}
+
+		log.Infof("Restarting plugin %s", p.pluginConf.Command)
+		startedAt := time.Now()
+		if err := p.start(); err != nil {
+			log.Errorf("Failed to restart plugin: %s", err.Error())
+		} else if err := p.initPlugin(p.pluginConf.StartTimeout); err != nil {
+			log.Errorf("Failed to initialize restarted plugin: %s", err.Error())
+			// a plugin which can't be initialized is restarted until it works.
+			p.kill()
+		}
+
+		if time.Since(startedAt) > maxPluginRestartBackoff {
+			backoff = time.Second
+		} else if backoff *= 2; backoff > maxPluginRestartBackoff {
+			backoff = maxPluginRestartBackoff
+		}
+	}
+}
+
+// healthLoop checks the plugin health periodically. An unhealthy launched plugin is restarted,
+// a plugin which becomes healthy again is initialized.
+func (p *PluginPump) healthLoop() {
+	ticker := time.NewTicker(p.pluginConf.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.stopped:
+			return
+		}
+
+		err := p.checkHealth(p.pluginConf.HealthCheckInterval)
+
+		p.mu.Lock()
+		ready := p.ready
+		if err != nil {
+			p.ready = false
+		}
+		p.mu.Unlock()
+
+		switch {
+		case err != nil && ready:
+			log.Warnf("Plugin %s is unhealthy: %s", p.pluginConf.Address, err.Error())
+			p.kill()
+		case err == nil && !ready && p.pluginConf.Command == "":
+			// the running plugin may have been restarted without its config.
+			if err := p.initPlugin(p.pluginConf.StartTimeout); err != nil {
+				log.Errorf("Failed to initialize plugin: %s", err.Error())
+			}
+		}
+	}
+}
+
+// initPlugin waits for the plugin to be healthy and initializes it.
+func (p *PluginPump) initPlugin(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for {
+		err := p.checkHealth(time.Second)
+		if err == nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(err, "plugin %s is not healthy after %s", p.pluginConf.Address, timeout)
+		case <-p.stopped:
+			return errors.New("plugin pump is closed")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if err := p.client.Init(ctx, p.pluginConf.Config); err != nil {
+		return errors.Wrapf(err, "failed to initialize plugin %s", p.pluginConf.Address)
+	}
+
+	p.mu.Lock()
+	p.ready = true
+	p.mu.Unlock()
+
+	return nil
+}
+
+func (p *PluginPump) checkHealth(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := p.health.Check(ctx, &healthpb.HealthCheckRequest{Service: plugin.ServiceName})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return errors.Errorf("plugin status is %s", resp.GetStatus())
+	}
+
+	return nil
+}
+
+// kill kills the launched plugin process, which is then restarted by supervise.
+func (p *PluginPump) kill() {
+	p.mu.Lock()
+	cmd := p.cmd
+	p.mu.Unlock()
+
+	if cmd != nil {
+		_ = cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+	"github.com/marmotedu/iam/pkg/pump/plugin"
+)
+
+// recordingPump is a plugin recording its config and the usernames of the records to a file.
+type recordingPump struct {
+	mu     sync.Mutex
+	output string
+}
+
+func (r *recordingPump) Init(ctx context.Context, config map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.output, _ = config["output"].(string)
+
+	return r.appendLine("init " + config["name"].(string))
+}
+
+func (r *recordingPump) WriteData(ctx context.Context, records []plugin.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, record := range records {
+		if err := r.appendLine("record " + record.Username); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *recordingPump) appendLine(line string) error {
+	file, err := os.OpenFile(r.output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(line + "\n")
+
+	return err
+}
+
+// TestPluginHelperProcess is the plugin launched by TestPluginPump_Command, it is not a real test.
+func TestPluginHelperProcess(t *testing.T) {
+	if os.Getenv("IAM_PUMP_PLUGIN_HELPER") != "1" {
+		return
+	}
+
+	if err := plugin.Serve(&recordingPump{}); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func readPluginOutput(t *testing.T, output string) []string {
+	t.Helper()
+
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+func assertPluginOutput(t *testing.T, output string, want []string) {
+	t.Helper()
+
+	got := readPluginOutput(t, output)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("plugin output = %v, want %v", got, want)
+	}
+}
+
+func TestPluginPump_Address(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+	address := "unix://" + filepath.Join(dir, "plugin.sock")
+
+	lis, err := plugin.Listen(address)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	server := plugin.NewServer(&recordingPump{})
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	pmp := &PluginPump{}
+	err = pmp.Init(map[string]interface{}{
+		"address": address,
+		"config":  map[string]interface{}{"name": "address", "output": output},
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer pmp.Close()
+
+	data := []interface{}{
+		analytics.AnalyticsRecord{Username: "colin", Effect: "allow"},
+		analytics.AnalyticsRecord{Username: "peter", Effect: "deny"},
+	}
+	if err := pmp.WriteData(context.Background(), data); err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+
+	assertPluginOutput(t, output, []string{"init address", "record colin", "record peter"})
+}
+
+func TestPluginPump_WriteDataLarge(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+	address := "unix://" + filepath.Join(dir, "plugin.sock")
+
+	lis, err := plugin.Listen(address)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	server := plugin.NewServer(&recordingPump{})
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	pmp := &PluginPump{}
+	err = pmp.Init(map[string]interface{}{
+		"address": address,
+		"config":  map[string]interface{}{"name": "large", "output": output},
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer pmp.Close()
+
+	// the records exceed the 4 MiB message size limit of the plugin server together.
+	policies := strings.Repeat("p", 2<<20)
+	data := []interface{}{
+		analytics.AnalyticsRecord{Username: "colin", Policies: policies},
+		analytics.AnalyticsRecord{Username: "peter", Policies: policies},
+		analytics.AnalyticsRecord{Username: "tom", Policies: policies},
+	}
+	if err := pmp.WriteData(context.Background(), data); err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+
+	assertPluginOutput(t, output, []string{"init large", "record colin", "record peter", "record tom"})
+}
+
+func TestPluginPump_Command(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "output")
+
+	pmp := &PluginPump{}
+	err := pmp.Init(map[string]interface{}{
+		"command": os.Args[0],
+		"args":    []string{"-test.run=TestPluginHelperProcess"},
+		"env":     []string{"IAM_PUMP_PLUGIN_HELPER=1"},
+		"config":  map[string]interface{}{"name": "command", "output": output},
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer pmp.Close()
+
+	ctx := context.Background()
+	if err := pmp.WriteData(ctx, []interface{}{analytics.AnalyticsRecord{Username: "colin"}}); err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+
+	// the crashed plugin is restarted and initialized again.
+	pmp.kill()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		err = pmp.WriteData(ctx, []interface{}{analytics.AnalyticsRecord{Username: "peter"}})
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("WriteData() after restart error = %v", err)
+	}
+
+	assertPluginOutput(t, output, []string{"init command", "record colin", "init command", "record peter"})
+}
+
+func TestPluginPump_Init(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+	}{
+		{name: "no command and address", config: map[string]interface{}{}},
+		{name: "command and address", config: map[string]interface{}{"command": "plugin", "address": "unix:///tmp/p.sock"}},
+		{name: "command not found", config: map[string]interface{}{"command": "/nonexistent/iam-pump-plugin"}},
+		{
+			name: "unhealthy address",
+			config: map[string]interface{}{
+				"address":       "unix://" + filepath.Join(t.TempDir(), "missing.sock"),
+				"start_timeout": "300ms",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&PluginPump{}).Init(tt.config); err == nil {
+				t.Error("Init() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestPluginPump_WriteDataNotReady(t *testing.T) {
+	pmp := &PluginPump{pluginConf: &PluginConf{Address: "unix:///tmp/p.sock"}}
+
+	err := pmp.WriteData(context.Background(), []interface{}{analytics.AnalyticsRecord{}})
+	if err == nil {
+		t.Error("WriteData() error = nil, want error")
+	}
+}
@@ -6,9 +6,14 @@ package pumps
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/marmotedu/errors"
 	"github.com/mitchellh/mapstructure"
 	"github.com/ory/ladon"
 	"github.com/prometheus/client_golang/prometheus"
@@ -18,11 +23,52 @@ import (
 	"github.com/marmotedu/iam/pkg/log"
 )
 
+// Types of the prometheus metrics.
+const (
+	PrometheusCounter   = "counter"
+	PrometheusHistogram = "histogram"
+)
+
+// Fields of the records used as label values and observed values. The fields of the
+// authorization request are referred to by request.<dot separated path>, like request.action.
+const (
+	PrometheusFieldUsername   = "username"
+	PrometheusFieldEffect     = "effect"
+	PrometheusFieldConclusion = "conclusion"
+	// PrometheusFieldCode is 0 for the allowed requests and 1 for the others.
+	PrometheusFieldCode = "code"
+
+	prometheusRequestPrefix = "request."
+)
+
+const (
+	defaultPrometheusPath = "/metrics"
+	// defaultPrometheusOtherValue replaces the label values missing from the allowed values.
+	defaultPrometheusOtherValue = "other"
+	// prometheusShutdownTimeout is how long the scrapes in progress have to finish on close.
+	prometheusShutdownTimeout = 5 * time.Second
+)
+
+// defaultPrometheusMetrics are exposed when no metric is configured. The username is not
+// a label by default, as every user would create a new series.
+var defaultPrometheusMetrics = []PrometheusMetricConf{
+	{
+		Name:   "iam_user_authorization_status_total",
+		Help:   "authorization decisions per effect code",
+		Type:   PrometheusCounter,
+		Labels: []PrometheusLabelConf{{Name: "code", Field: PrometheusFieldCode}},
+	},
+}
+
 // PrometheusPump defines a prometheus pump with prometheus specific options and common options.
+// Every pump has its own registry and listener, so several prometheus pumps can be configured.
 type PrometheusPump struct {
-	conf *PrometheusConf
-	// Per service
-	TotalStatusMetrics *prometheus.CounterVec
+	conf     *PrometheusConf
+	registry *prometheus.Registry
+	metrics  []*prometheusMetric
+	server   *http.Server
+	// decodeRequest is set when a metric uses the fields of the authorization request.
+	decodeRequest bool
 
 	CommonPumpConfig
 }
@@ -31,20 +77,44 @@ type PrometheusPump struct {
 type PrometheusConf struct {
 	Addr string `mapstructure:"listen_address"`
 	Path string `mapstructure:"path"`
+	// Metrics are the metrics exposed by the pump, defaultPrometheusMetrics if empty.
+	Metrics []PrometheusMetricConf `mapstructure:"metrics"`
+}
+
+// PrometheusMetricConf defines a metric updated for every record.
+type PrometheusMetricConf struct {
+	Name string `mapstructure:"name"`
+	Help string `mapstructure:"help"`
+	// Type is counter or histogram, default counter.
+	Type   string                `mapstructure:"type"`
+	Labels []PrometheusLabelConf `mapstructure:"labels"`
+	// Value is the field observed by a histogram, the records without a numeric value are skipped.
+	Value string `mapstructure:"value"`
+	// Buckets of a histogram, prometheus.DefBuckets if empty.
+	Buckets []float64 `mapstructure:"buckets"`
+}
+
+// PrometheusLabelConf defines a label of a metric.
+type PrometheusLabelConf struct {
+	Name  string `mapstructure:"name"`
+	Field string `mapstructure:"field"`
+	// AllowedValues caps the cardinality of the label, the other values are replaced
+	// with OtherValue. All values are allowed if empty.
+	AllowedValues []string `mapstructure:"allowed_values"`
+	// OtherValue defaults to other.
+	OtherValue string `mapstructure:"other_value"`
+}
+
+type prometheusMetric struct {
+	conf      PrometheusMetricConf
+	allowed   []map[string]struct{}
+	counter   *prometheus.CounterVec
+	histogram *prometheus.HistogramVec
 }
 
 // New create a prometheus pump instance.
 func (p *PrometheusPump) New() Pump {
 	newPump := PrometheusPump{}
-	newPump.TotalStatusMetrics = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "iam_user_authorization_status_total",
-			Help: "authorization effect per user",
-		},
-		[]string{"code", "username"},
-	)
-
-	prometheus.MustRegister(newPump.TotalStatusMetrics)
 
 	return &newPump
 }
@@ -54,46 +124,250 @@ func (p *PrometheusPump) GetName() string {
 	return "Prometheus Pump"
 }
 
-// Init initialize the prometheus pump instance.
+// Init initialize the prometheus pump instance, the metrics are registered and served on listen_address.
 func (p *PrometheusPump) Init(conf interface{}) error {
 	p.conf = &PrometheusConf{}
-	err := mapstructure.Decode(conf, &p.conf)
-	if err != nil {
-		log.Fatalf("Failed to decode configuration: %s", err.Error())
+	if err := mapstructure.Decode(conf, p.conf); err != nil {
+		return errors.Wrap(err, "failed to decode prometheus configuration")
 	}
 
 	if p.conf.Path == "" {
-		p.conf.Path = "/metrics"
+		p.conf.Path = defaultPrometheusPath
 	}
-
 	if p.conf.Addr == "" {
-		return errors.New("prometheus listen_addr not set")
+		return errors.New("prometheus listen_address not set")
+	}
+	if len(p.conf.Metrics) == 0 {
+		p.conf.Metrics = defaultPrometheusMetrics
+	}
+
+	p.registry = prometheus.NewRegistry()
+	p.registry.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+
+	p.metrics = nil
+	for _, metricConf := range p.conf.Metrics {
+		metric, err := newPrometheusMetric(metricConf)
+		if err != nil {
+			return err
+		}
+		if err := p.registry.Register(metric.collector()); err != nil {
+			return errors.Wrapf(err, "failed to register prometheus metric %s", metricConf.Name)
+		}
+		p.decodeRequest = p.decodeRequest || metric.usesRequest()
+		p.metrics = append(p.metrics, metric)
+	}
+
+	lis, err := net.Listen("tcp", p.conf.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s", p.conf.Addr)
 	}
 
-	log.Infof("Starting prometheus listener on: %s", p.conf.Addr)
+	mux := http.NewServeMux()
+	mux.Handle(p.conf.Path, promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{}))
+	p.server = &http.Server{Handler: mux}
 
-	http.Handle(p.conf.Path, promhttp.Handler())
+	log.Infof("Starting prometheus listener on: %s", lis.Addr())
 
 	go func() {
-		log.Fatal(http.ListenAndServe(p.conf.Addr, nil).Error())
+		if err := p.server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Prometheus listener on %s stopped: %s", p.conf.Addr, err.Error())
+		}
 	}()
 
 	return nil
 }
 
-// WriteData write analyzed data to prometheus persistent back-end storage.
+// WriteData updates the metrics with the records.
 func (p *PrometheusPump) WriteData(ctx context.Context, data []interface{}) error {
 	log.Debugf("Writing %d records", len(data))
 
 	for _, item := range data {
 		record, _ := item.(analytics.AnalyticsRecord)
-		code := "0"
-		if record.Effect != ladon.AllowAccess {
-			code = "1"
+
+		var request map[string]interface{}
+		if p.decodeRequest {
+			// the request fields of an invalid request are missing.
+			request, _ = analytics.DecodeRequest(record.Request)
 		}
 
-		p.TotalStatusMetrics.WithLabelValues(code, record.Username).Inc()
+		for _, metric := range p.metrics {
+			metric.update(&record, request)
+		}
 	}
 
 	return nil
 }
+
+// Close stops the prometheus listener.
+func (p *PrometheusPump) Close() error {
+	if p.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), prometheusShutdownTimeout)
+	defer cancel()
+
+	return p.server.Shutdown(ctx)
+}
+
+func newPrometheusMetric(conf PrometheusMetricConf) (*prometheusMetric, error) {
+	if conf.Name == "" {
+		return nil, errors.New("prometheus metric name not set")
+	}
+	if conf.Help == "" {
+		conf.Help = conf.Name
+	}
+
+	metric := &prometheusMetric{conf: conf}
+
+	labelNames := make([]string, 0, len(conf.Labels))
+	for _, label := range conf.Labels {
+		if !validPrometheusField(label.Field) {
+			return nil, fmt.Errorf("prometheus metric %s: label %s has invalid field %q", conf.Name, label.Name, label.Field)
+		}
+
+		var allowed map[string]struct{}
+		if len(label.AllowedValues) > 0 {
+			allowed = make(map[string]struct{}, len(label.AllowedValues))
+			for _, value := range label.AllowedValues {
+				allowed[value] = struct{}{}
+			}
+		}
+		metric.allowed = append(metric.allowed, allowed)
+		labelNames = append(labelNames, label.Name)
+	}
+
+	switch conf.Type {
+	case "", PrometheusCounter:
+		metric.counter = prometheus.NewCounterVec(prometheus.CounterOpts{Name: conf.Name, Help: conf.Help}, labelNames)
+	case PrometheusHistogram:
+		if !validPrometheusField(conf.Value) {
+			return nil, fmt.Errorf("prometheus histogram %s has invalid value field %q", conf.Name, conf.Value)
+		}
+		metric.histogram = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{Name: conf.Name, Help: conf.Help, Buckets: conf.Buckets},
+			labelNames,
+		)
+	default:
+		return nil, fmt.Errorf("prometheus metric %s has unknown type %q, must be %s or %s",
+			conf.Name, conf.Type, PrometheusCounter, PrometheusHistogram)
+	}
+
+	return metric, nil
+}
+
+func (m *prometheusMetric) collector() prometheus.Collector {
+	if m.histogram != nil {
+		return m.histogram
+	}
+
+	return m.counter
+}
+
+func (m *prometheusMetric) usesRequest() bool {
+	if strings.HasPrefix(m.conf.Value, prometheusRequestPrefix) {
+		return true
+	}
+	for _, label := range m.conf.Labels {
+		if strings.HasPrefix(label.Field, prometheusRequestPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *prometheusMetric) update(record *analytics.AnalyticsRecord, request map[string]interface{}) {
+	values := make([]string, len(m.conf.Labels))
+	for i, label := range m.conf.Labels {
+		value, _ := prometheusFieldValue(record, request, label.Field)
+		values[i] = formatPrometheusLabel(value)
+
+		if m.allowed[i] == nil {
+			continue
+		}
+		if _, ok := m.allowed[i][values[i]]; !ok {
+			values[i] = label.OtherValue
+			if values[i] == "" {
+				values[i] = defaultPrometheusOtherValue
+			}
+		}
+	}
+
+	if m.counter != nil {
+		m.counter.WithLabelValues(values...).Inc()
+
+		return
+	}
+
+	value, ok := prometheusFieldValue(record, request, m.conf.Value)
+	if !ok {
+		return
+	}
+	if observed, ok := parsePrometheusValue(value); ok {
+		m.histogram.WithLabelValues(values...).Observe(observed)
+	}
+}
+
+func validPrometheusField(field string) bool {
+	switch field {
+	case PrometheusFieldUsername, PrometheusFieldEffect, PrometheusFieldConclusion, PrometheusFieldCode:
+		return true
+	}
+
+	path := strings.TrimPrefix(field, prometheusRequestPrefix)
+
+	return path != field && path != "" && !strings.HasPrefix(path, ".") && !strings.HasSuffix(path, ".") &&
+		!strings.Contains(path, "..")
+}
+
+func prometheusFieldValue(record *analytics.AnalyticsRecord, request map[string]interface{}, field string) (interface{}, bool) {
+	switch field {
+	case PrometheusFieldUsername:
+		return record.Username, true
+	case PrometheusFieldEffect:
+		return record.Effect, true
+	case PrometheusFieldConclusion:
+		return record.Conclusion, true
+	case PrometheusFieldCode:
+		if record.Effect == ladon.AllowAccess {
+			return "0", true
+		}
+
+		return "1", true
+	}
+
+	if request == nil {
+		return nil, false
+	}
+
+	return analytics.LookupPath(request, strings.TrimPrefix(field, prometheusRequestPrefix))
+}
+
+func formatPrometheusLabel(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func parsePrometheusValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case interface{ Float64() (float64, error) }:
+		f, err := v.Float64()
+
+		return f, err == nil
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
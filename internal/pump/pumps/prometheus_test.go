@@ -0,0 +1,201 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+)
+
+func initPrometheusPump(t *testing.T, metrics []interface{}) *PrometheusPump {
+	t.Helper()
+
+	conf := map[string]interface{}{"listen_address": "127.0.0.1:0"}
+	if metrics != nil {
+		conf["metrics"] = metrics
+	}
+
+	pmp := (&PrometheusPump{}).New().(*PrometheusPump)
+	if err := pmp.Init(conf); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	t.Cleanup(func() { _ = pmp.Close() })
+
+	return pmp
+}
+
+func TestPrometheusPump_WriteData(t *testing.T) {
+	data := []interface{}{
+		analytics.AnalyticsRecord{
+			Username: "colin",
+			Effect:   "allow",
+			Request:  `{"action":"delete","context":{"latency":0.3}}`,
+		},
+		analytics.AnalyticsRecord{
+			Username: "peter",
+			Effect:   "deny",
+			Request:  `{"action":"get","context":{"latency":"2"}}`,
+		},
+		analytics.AnalyticsRecord{Username: "admin", Effect: "allow", Request: "invalid"},
+	}
+
+	tests := []struct {
+		name    string
+		metrics []interface{}
+		want    string
+	}{
+		{
+			name: "default metrics",
+			want: `
+# HELP iam_user_authorization_status_total authorization decisions per effect code
+# TYPE iam_user_authorization_status_total counter
+iam_user_authorization_status_total{code="0"} 2
+iam_user_authorization_status_total{code="1"} 1
+`,
+		},
+		{
+			name: "counter with request field and allowed values",
+			metrics: []interface{}{
+				map[string]interface{}{
+					"name": "iam_decisions_total",
+					"help": "decisions",
+					"labels": []interface{}{
+						map[string]interface{}{"name": "action", "field": "request.action"},
+						map[string]interface{}{"name": "user", "field": "username", "allowed_values": []string{"admin"}},
+					},
+				},
+			},
+			want: `
+# HELP iam_decisions_total decisions
+# TYPE iam_decisions_total counter
+iam_decisions_total{action="",user="admin"} 1
+iam_decisions_total{action="delete",user="other"} 1
+iam_decisions_total{action="get",user="other"} 1
+`,
+		},
+		{
+			name: "histogram",
+			metrics: []interface{}{
+				map[string]interface{}{
+					"name":    "iam_request_latency_seconds",
+					"help":    "latency",
+					"type":    "histogram",
+					"value":   "request.context.latency",
+					"buckets": []float64{1},
+					"labels":  []interface{}{map[string]interface{}{"name": "effect", "field": "effect"}},
+				},
+			},
+			want: `
+# HELP iam_request_latency_seconds latency
+# TYPE iam_request_latency_seconds histogram
+iam_request_latency_seconds_bucket{effect="allow",le="1"} 1
+iam_request_latency_seconds_bucket{effect="allow",le="+Inf"} 1
+iam_request_latency_seconds_sum{effect="allow"} 0.3
+iam_request_latency_seconds_count{effect="allow"} 1
+iam_request_latency_seconds_bucket{effect="deny",le="1"} 0
+iam_request_latency_seconds_bucket{effect="deny",le="+Inf"} 1
+iam_request_latency_seconds_sum{effect="deny"} 2
+iam_request_latency_seconds_count{effect="deny"} 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pmp := initPrometheusPump(t, tt.metrics)
+			if err := pmp.WriteData(context.Background(), data); err != nil {
+				t.Fatalf("WriteData() error = %v", err)
+			}
+
+			name := pmp.conf.Metrics[0].Name
+			if err := testutil.GatherAndCompare(pmp.registry, strings.NewReader(tt.want), name); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPrometheusPump_MultipleInstances(t *testing.T) {
+	first := initPrometheusPump(t, nil)
+	second := initPrometheusPump(t, nil)
+
+	if err := first.WriteData(context.Background(), []interface{}{analytics.AnalyticsRecord{Effect: "allow"}}); err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	second.server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status = %d", recorder.Code)
+	}
+	if strings.Contains(recorder.Body.String(), "iam_user_authorization_status_total{") {
+		t.Error("records of the first pump are exposed by the second pump")
+	}
+}
+
+func TestPrometheusPump_Init(t *testing.T) {
+	tests := []struct {
+		name string
+		conf map[string]interface{}
+	}{
+		{name: "no listen address", conf: map[string]interface{}{}},
+		{
+			name: "unknown type",
+			conf: map[string]interface{}{
+				"listen_address": "127.0.0.1:0",
+				"metrics":        []interface{}{map[string]interface{}{"name": "m", "type": "gauge"}},
+			},
+		},
+		{
+			name: "invalid label field",
+			conf: map[string]interface{}{
+				"listen_address": "127.0.0.1:0",
+				"metrics": []interface{}{map[string]interface{}{
+					"name":   "m",
+					"labels": []interface{}{map[string]interface{}{"name": "l", "field": "policies"}},
+				}},
+			},
+		},
+		{
+			name: "histogram without value",
+			conf: map[string]interface{}{
+				"listen_address": "127.0.0.1:0",
+				"metrics":        []interface{}{map[string]interface{}{"name": "m", "type": "histogram"}},
+			},
+		},
+		{
+			name: "duplicate metrics",
+			conf: map[string]interface{}{
+				"listen_address": "127.0.0.1:0",
+				"metrics": []interface{}{
+					map[string]interface{}{"name": "m"},
+					map[string]interface{}{"name": "m"},
+				},
+			},
+		},
+		{
+			name: "invalid metric name",
+			conf: map[string]interface{}{
+				"listen_address": "127.0.0.1:0",
+				"metrics":        []interface{}{map[string]interface{}{"name": "iam-decisions"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pmp := &PrometheusPump{}
+			if err := pmp.Init(tt.conf); err == nil {
+				_ = pmp.Close()
+				t.Error("Init() error = nil, want error")
+			}
+		})
+	}
+}
@@ -19,6 +19,8 @@ type Pump interface {
 	WriteData(context.Context, []interface{}) error
 	SetFilters(analytics.AnalyticsFilters)
 	GetFilters() analytics.AnalyticsFilters
+	SetTransforms(analytics.AnalyticsTransforms)
+	GetTransforms() analytics.AnalyticsTransforms
 	SetTimeout(timeout int)
 	GetTimeout() int
 	SetOmitDetailedRecording(bool)
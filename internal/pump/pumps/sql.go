@@ -0,0 +1,291 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/glebarez/sqlite"
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/marmotedu/errors"
+	"github.com/mitchellh/mapstructure"
+	"github.com/ory/ladon"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+	"github.com/marmotedu/iam/pkg/db"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Databases supported by the sql pump.
+const (
+	SQLTypeMySQL    = "mysql"
+	SQLTypePostgres = "postgres"
+	SQLTypeSQLite   = "sqlite"
+)
+
+const (
+	defaultSQLTableName         = "iam_analytics"
+	defaultSQLBatchSize         = 1000
+	defaultSQLRetentionInterval = time.Hour
+
+	// sqlIndexedColumnSize is the size in bytes of the columns extracted from the request.
+	sqlIndexedColumnSize = 255
+)
+
+// SQLPump defines a sql pump with sql specific options and common options.
+type SQLPump struct {
+	sqlConf *SQLConf
+	db      *gorm.DB
+	// stopCh stops the retention loop, retentionDone is closed once it returns.
+	stopCh        chan struct{}
+	retentionDone chan struct{}
+	closeOnce     sync.Once
+	CommonPumpConfig
+}
+
+// SQLConf defines sql specific options.
+type SQLConf struct {
+	// Type is the database type, one of mysql, postgres and sqlite.
+	Type string `mapstructure:"type"`
+	// ConnectionString is the dsn of the database, or the file path for sqlite.
+	ConnectionString string `mapstructure:"connection_string"`
+	// TableName is the table the records are inserted to, default iam_analytics.
+	TableName string `mapstructure:"table_name"`
+	// BatchSize is the maximum number of records inserted by a statement, default 1000.
+	BatchSize int `mapstructure:"batch_size"`
+	// Retention is how long the records are kept, 0 keeps them forever.
+	Retention time.Duration `mapstructure:"retention"`
+	// RetentionInterval is how often the expired records are deleted, default 1h.
+	RetentionInterval time.Duration `mapstructure:"retention_interval"`
+}
+
+// SQLAnalyticsRecord is the table row of an analytics record. The action, resource and
+// subject of the authorization request are extracted to indexed columns.
+type SQLAnalyticsRecord struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement"`
+	TimeStamp  int64     `gorm:"column:timestamp;index"`
+	Username   string    `gorm:"size:255;index"`
+	Effect     string    `gorm:"size:16;index"`
+	Conclusion string    `gorm:"type:text"`
+	Action     string    `gorm:"size:255;index"`
+	Resource   string    `gorm:"size:255;index"`
+	Subject    string    `gorm:"size:255;index"`
+	Request    string    `gorm:"type:text"`
+	Policies   string    `gorm:"type:text"`
+	Deciders   string    `gorm:"type:text"`
+	ExpireAt   time.Time `gorm:"column:expire_at"`
+}
+
+// TableName returns the default table name of the analytics records.
+func (SQLAnalyticsRecord) TableName() string {
+	return defaultSQLTableName
+}
+
+// New create a sql pump instance.
+func (s *SQLPump) New() Pump {
+	newPump := SQLPump{}
+
+	return &newPump
+}
+
+// GetName returns the sql pump name.
+func (s *SQLPump) GetName() string {
+	return "SQL Pump"
+}
+
+// Init initialize the sql pump instance, the table is created or migrated if needed.
+func (s *SQLPump) Init(config interface{}) error {
+	s.sqlConf = &SQLConf{}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     s.sqlConf,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(config); err != nil {
+		return errors.Wrap(err, "failed to decode sql configuration")
+	}
+
+	conf := s.sqlConf
+	if conf.TableName == "" {
+		conf.TableName = defaultSQLTableName
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = defaultSQLBatchSize
+	}
+	if conf.RetentionInterval <= 0 {
+		conf.RetentionInterval = defaultSQLRetentionInterval
+	}
+
+	s.db, err = openSQL(conf.Type, conf.ConnectionString)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Table(conf.TableName).AutoMigrate(&SQLAnalyticsRecord{}); err != nil {
+		return errors.Wrapf(err, "failed to migrate table %s", conf.TableName)
+	}
+
+	s.stopCh = make(chan struct{})
+	if conf.Retention > 0 {
+		s.retentionDone = make(chan struct{})
+		go s.retentionLoop()
+	}
+
+	log.Debugf("SQL Pump active, writing records to %s table %s", conf.Type, conf.TableName)
+
+	return nil
+}
+
+// WriteData inserts the records to the table in batches.
+func (s *SQLPump) WriteData(ctx context.Context, data []interface{}) error {
+	startTime := time.Now()
+	log.Debugf("Attempting to write %d records...", len(data))
+
+	rows := make([]*SQLAnalyticsRecord, 0, len(data))
+	for _, v := range data {
+		decoded, _ := v.(analytics.AnalyticsRecord)
+		rows = append(rows, newSQLAnalyticsRecord(decoded))
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	if err := s.db.WithContext(ctx).Table(s.sqlConf.TableName).CreateInBatches(rows, s.sqlConf.BatchSize).Error; err != nil {
+		return errors.Wrapf(err, "failed to insert records to table %s", s.sqlConf.TableName)
+	}
+
+	log.Debugf("ElapsedTime in seconds for %d records %v", len(data), time.Since(startTime))
+
+	return nil
+}
+
+// Close stops deleting the expired records and closes the database connections.
+func (s *SQLPump) Close() error {
+	if s.db == nil {
+		return nil
+	}
+
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stopCh)
+		if s.retentionDone != nil {
+			<-s.retentionDone
+		}
+
+		sqlDB, dbErr := s.db.DB()
+		if dbErr != nil {
+			err = dbErr
+
+			return
+		}
+		err = sqlDB.Close()
+	})
+
+	return err
+}
+
+func (s *SQLPump) retentionLoop() {
+	defer close(s.retentionDone)
+
+	ticker := time.NewTicker(s.sqlConf.RetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := s.deleteExpired(); err != nil {
+			log.Errorf("Failed to delete expired records from table %s: %s", s.sqlConf.TableName, err.Error())
+		} else if deleted > 0 {
+			log.Infof("Deleted %d expired records from table %s", deleted, s.sqlConf.TableName)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// deleteExpired deletes the records authorized before the retention and returns the number of deleted records.
+func (s *SQLPump) deleteExpired() (int64, error) {
+	cutoff := time.Now().Add(-s.sqlConf.Retention).Unix()
+	result := s.db.Table(s.sqlConf.TableName).Where("timestamp < ?", cutoff).Delete(&SQLAnalyticsRecord{})
+
+	return result.RowsAffected, result.Error
+}
+
+// openSQL connects to the database of sqlType, one of mysql, postgres and sqlite.
+func openSQL(sqlType, dsn string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch sqlType {
+	case SQLTypeMySQL:
+		dialector = mysql.Open(dsn)
+	case SQLTypePostgres:
+		dialector = postgres.Open(dsn)
+	case SQLTypeSQLite:
+		dialector = sqlite.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unknown sql type %q, must be one of %s, %s and %s",
+			sqlType, SQLTypeMySQL, SQLTypePostgres, SQLTypeSQLite)
+	}
+
+	gdb, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to %s", sqlType)
+	}
+
+	// create spans for the statements executed in the traced pump writes
+	if err := gdb.Use(&db.TracePlugin{}); err != nil {
+		return nil, err
+	}
+
+	return gdb, nil
+}
+
+func newSQLAnalyticsRecord(record analytics.AnalyticsRecord) *SQLAnalyticsRecord {
+	row := &SQLAnalyticsRecord{
+		TimeStamp:  record.TimeStamp,
+		Username:   record.Username,
+		Effect:     record.Effect,
+		Conclusion: record.Conclusion,
+		Request:    record.Request,
+		Policies:   record.Policies,
+		Deciders:   record.Deciders,
+		ExpireAt:   record.ExpireAt,
+	}
+
+	var request ladon.Request
+	if err := json.Unmarshal([]byte(record.Request), &request); err == nil {
+		row.Action = truncateColumn(request.Action, sqlIndexedColumnSize)
+		row.Resource = truncateColumn(request.Resource, sqlIndexedColumnSize)
+		row.Subject = truncateColumn(request.Subject, sqlIndexedColumnSize)
+	}
+
+	return row
+}
+
+// truncateColumn truncates s to size bytes, without splitting a character.
+func truncateColumn(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+
+	end := size
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+
+	return s[:end]
+}
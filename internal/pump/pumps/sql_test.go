@@ -0,0 +1,104 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+)
+
+func TestSQLPump_WriteData(t *testing.T) {
+	pmp := &SQLPump{}
+	err := pmp.Init(map[string]interface{}{
+		"type":              SQLTypeSQLite,
+		"connection_string": filepath.Join(t.TempDir(), "analytics.db"),
+		"table_name":        "decisions",
+		"batch_size":        2,
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	now := time.Now()
+	data := []interface{}{
+		analytics.AnalyticsRecord{
+			TimeStamp: now.Unix(),
+			Username:  "colin",
+			Effect:    "allow",
+			Request:   `{"subject":"users:peter","action":"delete","resource":"resources:articles:ladon"}`,
+		},
+		analytics.AnalyticsRecord{TimeStamp: now.Add(-48 * time.Hour).Unix(), Username: "james", Request: "invalid"},
+		analytics.AnalyticsRecord{
+			TimeStamp: now.Unix(),
+			Username:  "lingfei",
+			Request:   `{"resource":"` + strings.Repeat("资", 100) + `"}`,
+		},
+	}
+	if err := pmp.WriteData(context.Background(), data); err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+
+	var rows []SQLAnalyticsRecord
+	if err := pmp.db.Table("decisions").Order("id").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(data) {
+		t.Fatalf("rows = %d, want %d", len(rows), len(data))
+	}
+
+	got := rows[0]
+	if got.Username != "colin" || got.Action != "delete" || got.Resource != "resources:articles:ladon" ||
+		got.Subject != "users:peter" {
+		t.Errorf("row = %+v, want the request fields extracted", got)
+	}
+	if rows[1].Action != "" {
+		t.Errorf("row of invalid request action = %q, want empty", rows[1].Action)
+	}
+	if resource := rows[2].Resource; len(resource) > sqlIndexedColumnSize || strings.ToValidUTF8(resource, "") != resource {
+		t.Errorf("long resource is truncated to %d bytes, want at most %d valid utf8 bytes", len(resource), sqlIndexedColumnSize)
+	}
+
+	pmp.sqlConf.Retention = 24 * time.Hour
+	deleted, err := pmp.deleteExpired()
+	if err != nil || deleted != 1 {
+		t.Errorf("deleteExpired() = %d, %v, want 1", deleted, err)
+	}
+}
+
+func TestSQLPump_Close(t *testing.T) {
+	pmp := &SQLPump{}
+	err := pmp.Init(map[string]interface{}{
+		"type":               SQLTypeSQLite,
+		"connection_string":  filepath.Join(t.TempDir(), "analytics.db"),
+		"retention":          "24h",
+		"retention_interval": "1h",
+	})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	if err := pmp.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	select {
+	case <-pmp.retentionDone:
+	default:
+		t.Error("retention loop is running after Close()")
+	}
+
+	sqlDB, _ := pmp.db.DB()
+	if err := sqlDB.Ping(); err == nil {
+		t.Error("database is open after Close()")
+	}
+
+	if err := pmp.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
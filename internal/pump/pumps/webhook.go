@@ -0,0 +1,294 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/marmotedu/errors"
+	"github.com/mitchellh/mapstructure"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// Formats of the webhook request bodies.
+const (
+	// WebhookFormatJSON sends a batch as a JSON array of records.
+	WebhookFormatJSON = "json"
+	// WebhookFormatNDJSON sends a batch as newline delimited JSON records.
+	WebhookFormatNDJSON = "ndjson"
+)
+
+const (
+	defaultWebhookHMACHeader = "X-IAM-Signature"
+	// maxWebhookErrorBody is the maximum size of a failed response body kept in the error.
+	maxWebhookErrorBody = 512
+)
+
+// WebhookPump defines a webhook pump with webhook specific options and common options.
+type WebhookPump struct {
+	webhookConf *WebhookConf
+	client      *http.Client
+	CommonPumpConfig
+}
+
+// WebhookConf defines webhook specific options.
+type WebhookConf struct {
+	URL     string            `mapstructure:"url"`
+	Method  string            `mapstructure:"method"`
+	Headers map[string]string `mapstructure:"headers"`
+	// Format is the format of the request body, json or ndjson, default json.
+	Format string `mapstructure:"format"`
+	// BatchSize is the maximum number of records sent in a request, 0 sends all the records at once.
+	BatchSize int `mapstructure:"batch_size"`
+	// MaxConcurrency is the maximum number of requests in flight, default 1.
+	MaxConcurrency int  `mapstructure:"max_concurrency"`
+	Gzip           bool `mapstructure:"gzip"`
+	// HMACSecret signs the request body with HMAC-SHA256, the signature is sent in HMACHeader
+	// as sha256=<hex digest>.
+	HMACSecret            string `mapstructure:"hmac_secret"`
+	HMACHeader            string `mapstructure:"hmac_header"`
+	SSLCAFile             string `mapstructure:"ssl_ca_file"`
+	SSLCertFile           string `mapstructure:"ssl_cert_file"`
+	SSLKeyFile            string `mapstructure:"ssl_key_file"`
+	SSLInsecureSkipVerify bool   `mapstructure:"ssl_insecure_skip_verify"`
+}
+
+// New create a webhook pump instance.
+func (w *WebhookPump) New() Pump {
+	newPump := WebhookPump{}
+
+	return &newPump
+}
+
+// GetName returns the webhook pump name.
+func (w *WebhookPump) GetName() string {
+	return "Webhook Pump"
+}
+
+// Init initialize the webhook pump instance.
+func (w *WebhookPump) Init(config interface{}) error {
+	w.webhookConf = &WebhookConf{}
+	if err := mapstructure.Decode(config, &w.webhookConf); err != nil {
+		return errors.Wrap(err, "failed to decode webhook configuration")
+	}
+
+	conf := w.webhookConf
+	if conf.Method == "" {
+		conf.Method = http.MethodPost
+	}
+	if conf.Format == "" {
+		conf.Format = WebhookFormatJSON
+	}
+	if conf.MaxConcurrency <= 0 {
+		conf.MaxConcurrency = 1
+	}
+	if conf.HMACHeader == "" {
+		conf.HMACHeader = defaultWebhookHMACHeader
+	}
+
+	if u, err := url.Parse(conf.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid webhook url %q, must be a http or https url", conf.URL)
+	}
+	if conf.Format != WebhookFormatJSON && conf.Format != WebhookFormatNDJSON {
+		return fmt.Errorf("unknown webhook format %q, must be %s or %s",
+			conf.Format, WebhookFormatJSON, WebhookFormatNDJSON)
+	}
+
+	tlsConfig, err := conf.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConnsPerHost = conf.MaxConcurrency
+	// the request timeout is controlled by the context passed to WriteData.
+	w.client = &http.Client{Transport: transport}
+
+	log.Debugf("Webhook Pump active, sending %s batches to %s", conf.Format, conf.URL)
+
+	return nil
+}
+
+func (conf *WebhookConf) tlsConfig() (*tls.Config, error) {
+	// nolint: gosec
+	tlsConfig := &tls.Config{InsecureSkipVerify: conf.SSLInsecureSkipVerify}
+
+	if conf.SSLCAFile != "" {
+		ca, err := ioutil.ReadFile(conf.SSLCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read webhook ca file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in webhook ca file %s", conf.SSLCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	switch {
+	case conf.SSLCertFile != "" && conf.SSLKeyFile != "":
+		cert, err := tls.LoadX509KeyPair(conf.SSLCertFile, conf.SSLKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed loading mTLS certificates")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	case conf.SSLCertFile != "" || conf.SSLKeyFile != "":
+		return nil, errors.New("both ssl_cert_file and ssl_key_file must be set to enable mTLS")
+	}
+
+	return tlsConfig, nil
+}
+
+// WriteData sends the records to the webhook in batches. It returns an error if any of the
+// batches is not accepted, the whole data is written again when the pump write is retried.
+func (w *WebhookPump) WriteData(ctx context.Context, data []interface{}) error {
+	startTime := time.Now()
+	log.Debugf("Attempting to write %d records...", len(data))
+
+	batchSize := w.webhookConf.BatchSize
+	if batchSize <= 0 || batchSize > len(data) {
+		batchSize = len(data)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, w.webhookConf.MaxConcurrency)
+
+	for start := 0; start < len(data); start += batchSize {
+		end := start + batchSize
+		if end > len(data) {
+			end = len(data)
+		}
+		batch := data[start:end]
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			// the context is also canceled when a batch fails, report that error first.
+			if err := g.Wait(); err != nil {
+				return err
+			}
+
+			return ctx.Err()
+		}
+
+		g.Go(func() error {
+			defer func() { <-sem }()
+
+			return w.send(ctx, batch)
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	log.Debugf("ElapsedTime in seconds for %d records %v", len(data), time.Since(startTime))
+
+	return nil
+}
+
+func (w *WebhookPump) send(ctx context.Context, batch []interface{}) error {
+	body, contentType, err := w.encode(batch)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, w.webhookConf.Method, w.webhookConf.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create webhook request")
+	}
+
+	for key, value := range w.webhookConf.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if w.webhookConf.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if w.webhookConf.HMACSecret != "" {
+		req.Header.Set(w.webhookConf.HMACHeader, "sha256="+signWebhookBody(w.webhookConf.HMACSecret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send webhook request")
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxWebhookErrorBody))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
+
+// encode returns the request body of the batch and its content type.
+func (w *WebhookPump) encode(batch []interface{}) ([]byte, string, error) {
+	records := make([]analytics.AnalyticsRecord, 0, len(batch))
+	for _, item := range batch {
+		if record, ok := item.(analytics.AnalyticsRecord); ok {
+			records = append(records, record)
+		}
+	}
+
+	var buf bytes.Buffer
+	var writer io.Writer = &buf
+	var zw *gzip.Writer
+	if w.webhookConf.Gzip {
+		zw = gzip.NewWriter(&buf)
+		writer = zw
+	}
+
+	contentType := "application/json"
+	if w.webhookConf.Format == WebhookFormatNDJSON {
+		contentType = "application/x-ndjson"
+		encoder := json.NewEncoder(writer)
+		for _, record := range records {
+			// Encode terminates every record with a newline.
+			if err := encoder.Encode(record); err != nil {
+				return nil, "", errors.Wrap(err, "failed to encode analytics record")
+			}
+		}
+	} else if err := json.NewEncoder(writer).Encode(records); err != nil {
+		return nil, "", errors.Wrap(err, "failed to encode analytics records")
+	}
+
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return nil, "", errors.Wrap(err, "failed to compress webhook request")
+		}
+	}
+
+	return buf.Bytes(), contentType, nil
+}
+
+// signWebhookBody returns the hex encoded HMAC-SHA256 of body, receivers verify the webhook requests by
+// comparing it with the signature header.
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
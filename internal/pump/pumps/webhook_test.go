@@ -0,0 +1,232 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pumps
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marmotedu/component-base/pkg/json"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+)
+
+// webhookRequest is a request received by the test webhook.
+type webhookRequest struct {
+	header  http.Header
+	raw     []byte
+	records []analytics.AnalyticsRecord
+}
+
+type webhookRecorder struct {
+	mu       sync.Mutex
+	requests []webhookRequest
+	status   int
+	delay    time.Duration
+}
+
+func (rec *webhookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(rec.delay)
+
+	raw, _ := ioutil.ReadAll(r.Body)
+	var body io.Reader = bytes.NewReader(raw)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+		body = zr
+	}
+
+	var records []analytics.AnalyticsRecord
+	if r.Header.Get("Content-Type") == "application/x-ndjson" {
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			var record analytics.AnalyticsRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+			records = append(records, record)
+		}
+	} else if err := json.NewDecoder(body).Decode(&records); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	rec.mu.Lock()
+	rec.requests = append(rec.requests, webhookRequest{header: r.Header, raw: raw, records: records})
+	rec.mu.Unlock()
+
+	if rec.status != 0 {
+		http.Error(w, "unavailable", rec.status)
+	}
+}
+
+func TestWebhookPump_WriteData(t *testing.T) {
+	data := []interface{}{
+		analytics.AnalyticsRecord{Username: "colin", Effect: "allow"},
+		analytics.AnalyticsRecord{Username: "james", Effect: "deny"},
+		analytics.AnalyticsRecord{Username: "lingfei", Effect: "allow"},
+	}
+
+	tests := []struct {
+		name         string
+		meta         map[string]interface{}
+		status       int
+		delay        time.Duration
+		timeout      time.Duration
+		wantErr      bool
+		wantRequests int
+	}{
+		{
+			name:         "json",
+			meta:         map[string]interface{}{},
+			wantRequests: 1,
+		},
+		{
+			name: "batched ndjson",
+			meta: map[string]interface{}{
+				"format":          "ndjson",
+				"batch_size":      2,
+				"max_concurrency": 2,
+			},
+			wantRequests: 2,
+		},
+		{
+			name: "gzip signed",
+			meta: map[string]interface{}{
+				"gzip":        true,
+				"hmac_secret": "secret",
+				"headers":     map[string]string{"authorization": "Bearer token"},
+			},
+			wantRequests: 1,
+		},
+		{
+			name:    "rejected",
+			meta:    map[string]interface{}{"batch_size": 1},
+			status:  http.StatusServiceUnavailable,
+			wantErr: true,
+		},
+		{
+			name:    "timeout",
+			meta:    map[string]interface{}{},
+			delay:   200 * time.Millisecond,
+			timeout: 50 * time.Millisecond,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &webhookRecorder{status: tt.status, delay: tt.delay}
+			server := httptest.NewServer(rec)
+			defer server.Close()
+
+			tt.meta["url"] = server.URL
+			pmp := (&WebhookPump{}).New()
+			if err := pmp.Init(tt.meta); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			err := pmp.WriteData(ctx, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			if len(rec.requests) != tt.wantRequests {
+				t.Fatalf("requests = %d, want %d", len(rec.requests), tt.wantRequests)
+			}
+
+			received := 0
+			for _, req := range rec.requests {
+				received += len(req.records)
+
+				if secret, ok := tt.meta["hmac_secret"].(string); ok {
+					want := "sha256=" + signWebhookBody(secret, req.raw)
+					if got := req.header.Get(defaultWebhookHMACHeader); got != want {
+						t.Errorf("signature = %q, want %q", got, want)
+					}
+				}
+				if headers, ok := tt.meta["headers"].(map[string]string); ok {
+					for key, value := range headers {
+						if got := req.header.Get(key); got != value {
+							t.Errorf("header %s = %q, want %q", key, got, value)
+						}
+					}
+				}
+			}
+			if received != len(data) {
+				t.Errorf("received records = %d, want %d", received, len(data))
+			}
+		})
+	}
+}
+
+func TestWebhookPump_Init_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(&webhookRecorder{})
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	pmp := (&WebhookPump{}).New()
+	if err := pmp.Init(map[string]interface{}{"url": server.URL, "ssl_ca_file": caFile}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	data := []interface{}{analytics.AnalyticsRecord{Username: "colin"}}
+	if err := pmp.WriteData(context.Background(), data); err != nil {
+		t.Errorf("WriteData() error = %v", err)
+	}
+}
+
+func TestWebhookPump_Init_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		meta map[string]interface{}
+	}{
+		{name: "missing url", meta: map[string]interface{}{}},
+		{name: "unknown format", meta: map[string]interface{}{"url": "http://localhost", "format": "xml"}},
+		{name: "cert without key", meta: map[string]interface{}{"url": "https://localhost", "ssl_cert_file": "cert.pem"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&WebhookPump{}).Init(tt.meta); err == nil {
+				t.Error("Init() error = nil, want error")
+			}
+		})
+	}
+}
@@ -5,18 +5,32 @@
 package pump
 
 import (
+	"github.com/marmotedu/errors"
+
 	genericapiserver "github.com/marmotedu/iam/internal/pkg/server"
 	"github.com/marmotedu/iam/internal/pump/config"
 )
 
 // Run runs the specified pump server. This should never exit.
 func Run(cfg *config.Config, stopCh <-chan struct{}) error {
-	go genericapiserver.ServeHealthCheck(cfg.HealthCheckPath, cfg.HealthCheckAddress)
-
 	server, err := createPumpServer(cfg)
 	if err != nil {
 		return err
 	}
 
-	return server.PrepareRun().Run(stopCh)
+	prepared := server.PrepareRun()
+
+	if cfg.DeadLetter.Replay {
+		err := server.replayDeadLetters()
+
+		return errors.NewAggregate([]error{err, server.shutdown()})
+	}
+
+	go genericapiserver.ServeHealthCheck(cfg.HealthCheckPath, cfg.HealthCheckAddress, server.readyzChecks()...)
+
+	if cfg.Admin.BindAddress != "" {
+		go server.serveAdmin(cfg.Admin.BindAddress)
+	}
+
+	return prepared.Run(stopCh)
 }
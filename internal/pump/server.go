@@ -7,31 +7,58 @@ package pump
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"sync"
 	"time"
 
 	goredislib "github.com/go-redis/redis/v8"
 	"github.com/go-redsync/redsync/v4"
 	"github.com/go-redsync/redsync/v4/redis/goredis/v8"
+	"github.com/marmotedu/errors"
 	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel/attribute"
 
+	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
+	"github.com/marmotedu/iam/internal/pkg/server/healthz"
+	"github.com/marmotedu/iam/internal/pkg/tracing"
 	"github.com/marmotedu/iam/internal/pump/analytics"
 	"github.com/marmotedu/iam/internal/pump/config"
+	"github.com/marmotedu/iam/internal/pump/deadletter"
 	"github.com/marmotedu/iam/internal/pump/options"
 	"github.com/marmotedu/iam/internal/pump/pumps"
 	"github.com/marmotedu/iam/internal/pump/storage"
+	"github.com/marmotedu/iam/internal/pump/storage/kafka"
 	"github.com/marmotedu/iam/internal/pump/storage/redis"
+	"github.com/marmotedu/iam/internal/pump/storage/spool"
 	"github.com/marmotedu/iam/pkg/log"
 )
 
-var pmps []pumps.Pump
+var pmps []*configuredPump
+
+// configuredPump is an initialized pump with the iam-pump options of its configuration.
+type configuredPump struct {
+	pumps.Pump
+	// key is the key of the pump in the pumps configuration.
+	key   string
+	retry options.RetryOptions
+	stats pumpStats
+}
 
 type pumpServer struct {
 	secInterval    int
 	omitDetails    bool
 	mutex          *redsync.Mutex
+	redisClient    *goredislib.Client
 	analyticsStore storage.AnalyticsStorage
 	pumps          map[string]options.PumpConfig
+	deadLetters    *deadletter.Queue
+	tracingOptions *genericoptions.TracingOptions
+	shutdownTracer func(context.Context) error
+	// initErrors are the errors of the pumps failed to initialize, by key.
+	initErrors map[string]string
+	// purgeCh triggers a purge outside of the purge-delay ticker.
+	purgeCh chan struct{}
 }
 
 // preparedGenericAPIServer is a private wrapper that enforces a call of PrepareRun() before Run can be invoked.
@@ -40,36 +67,91 @@ type preparedPumpServer struct {
 }
 
 func createPumpServer(cfg *config.Config) (*pumpServer, error) {
-	// use the same redis database with authorization log history
-	client := goredislib.NewClient(&goredislib.Options{
-		Addr:     fmt.Sprintf("%s:%d", cfg.RedisOptions.Host, cfg.RedisOptions.Port),
-		Username: cfg.RedisOptions.Username,
-		Password: cfg.RedisOptions.Password,
-	})
-
-	rs := redsync.New(goredis.NewPool(client))
-
 	server := &pumpServer{
 		secInterval:    cfg.PurgeDelay,
 		omitDetails:    cfg.OmitDetailedRecording,
-		mutex:          rs.NewMutex("iam-pump", redsync.WithExpiry(10*time.Minute)),
-		analyticsStore: &redis.RedisClusterStorageManager{},
 		pumps:          cfg.Pumps,
+		tracingOptions: cfg.TracingOptions,
+		purgeCh:        make(chan struct{}, 1),
 	}
 
-	if err := server.analyticsStore.Init(cfg.RedisOptions); err != nil {
-		return nil, err
+	if cfg.DeadLetter.Dir != "" {
+		server.deadLetters = deadletter.NewQueue(cfg.DeadLetter.Dir)
+	}
+
+	switch cfg.Input.Type {
+	case options.InputFile:
+		// the spool is local to the host, there is no other iam-pump instance to coordinate with.
+		server.analyticsStore = &spool.SpoolStorage{}
+		if err := server.analyticsStore.Init(cfg.Input); err != nil {
+			return nil, err
+		}
+	case options.InputKafka:
+		// iam-pump instances are coordinated by the kafka consumer group.
+		server.analyticsStore = &kafka.KafkaStorage{}
+		if err := server.analyticsStore.Init(cfg.Input); err != nil {
+			return nil, err
+		}
+	default:
+		// use the same redis database with authorization log history
+		client := goredislib.NewClient(&goredislib.Options{
+			Addr:     fmt.Sprintf("%s:%d", cfg.RedisOptions.Host, cfg.RedisOptions.Port),
+			Username: cfg.RedisOptions.Username,
+			Password: cfg.RedisOptions.Password,
+		})
+
+		rs := redsync.New(goredis.NewPool(client))
+
+		server.mutex = rs.NewMutex("iam-pump", redsync.WithExpiry(10*time.Minute))
+		server.redisClient = client
+		server.analyticsStore = &redis.RedisClusterStorageManager{}
+		if err := server.analyticsStore.Init(cfg.RedisOptions); err != nil {
+			return nil, err
+		}
+	}
+
+	if !server.analyticsStore.Connect() {
+		return nil, fmt.Errorf("failed to connect to the %s analytics storage", server.analyticsStore.GetName())
 	}
 
 	return server, nil
 }
 
 func (s *pumpServer) PrepareRun() preparedPumpServer {
+	var err error
+	if s.shutdownTracer, err = s.tracingOptions.Install("iam-pump"); err != nil {
+		log.Fatalf("failed to install tracer provider: %s", err.Error())
+	}
+
 	s.initialize()
 
 	return preparedPumpServer{s}
 }
 
+// readyzChecks returns the checks which report whether the pump server is able to work.
+func (s *pumpServer) readyzChecks() []healthz.Checker {
+	checks := []healthz.Checker{
+		healthz.NamedCheck("pumps", func(*http.Request) error {
+			for _, pmp := range pmps {
+				if pmp != nil {
+					return nil
+				}
+			}
+
+			return errors.New("no pump is initialized")
+		}),
+	}
+
+	if s.redisClient != nil {
+		// the same redis is used by the lock and the analytics store.
+		checks = append(checks, healthz.NamedCheck("redis", func(r *http.Request) error {
+			return s.redisClient.Ping(r.Context()).Err()
+		}))
+	}
+
+	return checks
+}
+
 func (s preparedPumpServer) Run(stopCh <-chan struct{}) error {
 	ticker := time.NewTicker(time.Duration(s.secInterval) * time.Second)
 	defer ticker.Stop()
@@ -79,33 +161,51 @@ func (s preparedPumpServer) Run(stopCh <-chan struct{}) error {
 		select {
 		case <-ticker.C:
 			s.pump()
+		case <-s.purgeCh:
+			log.Info("Purge triggered by the admin API")
+			s.pump()
 		// exit consumption cycle when receive SIGINT and SIGTERM signal
 		case <-stopCh:
 			log.Info("stop purge loop")
 
-			return nil
+			return s.shutdown()
 		}
 	}
 }
 
-// pump get authorization log from redis and write to pumps.
+// shutdown releases the pumps and flushes the pending spans.
+func (s *pumpServer) shutdown() error {
+	closePumps()
+
+	return s.shutdownTracer(context.Background())
+}
+
+// pump get authorization log from the input and write to pumps. The records are only removed
+// from the input once every pump has written them or they are dead-lettered.
 func (s *pumpServer) pump() {
-	if err := s.mutex.Lock(); err != nil {
-		log.Info("there is already an iam-pump instance running.")
+	if s.mutex != nil {
+		if err := s.mutex.Lock(); err != nil {
+			log.Info("there is already an iam-pump instance running.")
 
-		return
-	}
-	defer func() {
-		if _, err := s.mutex.Unlock(); err != nil {
-			log.Errorf("could not release iam-pump lock. err: %v", err)
+			return
 		}
-	}()
+		defer func() {
+			if _, err := s.mutex.Unlock(); err != nil {
+				log.Errorf("could not release iam-pump lock. err: %v", err)
+			}
+		}()
+	}
 
-	analyticsValues := s.analyticsStore.GetAndDeleteSet(storage.AnalyticsKeyName)
+	analyticsValues, ack := s.analyticsStore.GetSet(storage.AnalyticsKeyName)
 	if len(analyticsValues) == 0 {
 		return
 	}
 
+	ctx, span := tracing.Start(context.Background(), "pump.purge")
+	span.SetAttributes(attribute.Int("pump.records", len(analyticsValues)))
+	var err error
+	defer func() { tracing.End(span, err) }()
+
 	// Convert to something clean
 	keys := make([]interface{}, len(analyticsValues))
 
@@ -125,11 +225,34 @@ func (s *pumpServer) pump() {
 	}
 
 	// Send to pumps
-	writeToPumps(keys, s.secInterval)
+	if err = writeToPumps(ctx, keys, s.secInterval, s.deadLetters); err != nil {
+		log.Errorf("Records are kept in the %s input and will be pumped again: %s", s.analyticsStore.GetName(), err.Error())
+
+		return
+	}
+
+	if err = ack(); err != nil {
+		log.Errorf("Failed to remove pumped records from the %s input: %s", s.analyticsStore.GetName(), err.Error())
+	}
+}
+
+// closePumps closes the pumps holding resources, like the plugin processes.
+func closePumps() {
+	for _, pmp := range pmps {
+		if pmp == nil {
+			continue
+		}
+		if closer, ok := pmp.Pump.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Warnf("Failed to close %s: %s", pmp.GetName(), err.Error())
+			}
+		}
+	}
 }
 
 func (s *pumpServer) initialize() {
-	pmps = make([]pumps.Pump, len(s.pumps))
+	pmps = make([]*configuredPump, len(s.pumps))
+	s.initErrors = make(map[string]string)
 	i := 0
 	for key, pmp := range s.pumps {
 		pumpTypeName := pmp.Type
@@ -140,46 +263,162 @@ func (s *pumpServer) initialize() {
 		pmpType, err := pumps.GetPumpByName(pumpTypeName)
 		if err != nil {
 			log.Errorf("Pump load error (skipping): %s", err.Error())
+			s.initErrors[key] = err.Error()
 		} else {
 			pmpIns := pmpType.New()
 			initErr := pmpIns.Init(pmp.Meta)
 			if initErr != nil {
 				log.Errorf("Pump init error (skipping): %s", initErr.Error())
+				s.initErrors[key] = initErr.Error()
 			} else {
 				log.Infof("Init Pump: %s", pmpIns.GetName())
 				pmpIns.SetFilters(pmp.Filters)
+				pmpIns.SetTransforms(pmp.Transforms)
 				pmpIns.SetTimeout(pmp.Timeout)
 				pmpIns.SetOmitDetailedRecording(pmp.OmitDetailedRecording)
-				pmps[i] = pmpIns
+				pmps[i] = &configuredPump{Pump: pmpIns, key: key, retry: pmp.Retry}
 			}
 		}
 		i++
 	}
 }
 
-func writeToPumps(keys []interface{}, purgeDelay int) {
-	// Send to pumps
-	if pmps != nil {
-		var wg sync.WaitGroup
-		wg.Add(len(pmps))
-		for _, pmp := range pmps {
-			go execPumpWriting(&wg, pmp, &keys, purgeDelay)
+// replayDeadLetters re-sends the dead-lettered records to their pumps. A batch is removed once
+// it is written, the remaining batches of a pump are kept when one of them fails again.
+func (s *pumpServer) replayDeadLetters() error {
+	var errs []error
+	for _, pmp := range pmps {
+		if pmp == nil {
+			continue
 		}
-		wg.Wait()
-	} else {
+
+		batches, err := s.deadLetters.Batches(pmp.key)
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		for _, batch := range batches {
+			records, err := s.deadLetters.Read(batch)
+			if err != nil {
+				errs = append(errs, err)
+
+				continue
+			}
+
+			if err := retryPumpWriting(context.Background(), pmp, records, s.secInterval); err != nil {
+				errs = append(errs, fmt.Errorf("replay %s to %s failed: %w", batch, pmp.key, err))
+
+				break
+			}
+
+			if err := s.deadLetters.Remove(batch); err != nil {
+				errs = append(errs, err)
+			}
+			log.Infof("Replayed %d records to %s", len(records), pmp.key)
+		}
+	}
+
+	return errors.NewAggregate(errs)
+}
+
+// writeToPumps delivers keys to every pump. The delivery is at-least-once: without a dead-letter
+// queue, a failure of one pump keeps keys in the input, and the next purge writes them again to
+// all the pumps, including the ones which have already written them.
+func writeToPumps(ctx context.Context, keys []interface{}, purgeDelay int, deadLetters *deadletter.Queue) error {
+	// Send to pumps
+	if pmps == nil {
 		log.Warn("No pumps defined!")
+
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(pmps))
+	for i, pmp := range pmps {
+		if pmp == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, pmp *configuredPump) {
+			defer wg.Done()
+			errs[i] = deliver(ctx, pmp, keys, purgeDelay, deadLetters)
+		}(i, pmp)
+	}
+	wg.Wait()
+
+	return errors.NewAggregate(errs)
+}
+
+// deliver writes keys to pmp with retries, and dead-letters them if all retries fail.
+// The keys of a paused pump are dead-lettered without writing. Without a dead-letter queue an error
+// is returned, so that the input is not acked and the keys are not lost.
+func deliver(ctx context.Context, pmp *configuredPump, keys []interface{}, purgeDelay int, deadLetters *deadletter.Queue) error {
+	filteredKeys := filterData(pmp, keys)
+	pmp.stats.recordFiltered(len(keys) - len(filteredKeys))
+
+	if pmp.stats.isPaused() {
+		if deadLetters == nil {
+			return fmt.Errorf("%s is paused without a dead-letter queue", pmp.key)
+		}
+		if len(filteredKeys) > 0 {
+			if err := deadLetters.Put(pmp.key, filteredKeys); err != nil {
+				return fmt.Errorf("failed to dead-letter %d records of paused %s: %w", len(filteredKeys), pmp.key, err)
+			}
+		}
+		pmp.stats.recordSkipped(len(filteredKeys))
+
+		return nil
+	}
+
+	startTime := time.Now()
+	err := retryPumpWriting(ctx, pmp, filteredKeys, purgeDelay)
+	pmp.stats.recordWrite(len(filteredKeys), time.Since(startTime), err)
+	if err == nil || deadLetters == nil {
+		return err
+	}
+
+	if dlqErr := deadLetters.Put(pmp.key, filteredKeys); dlqErr != nil {
+		log.Errorf("Failed to dead-letter %d records of %s: %s", len(filteredKeys), pmp.key, dlqErr.Error())
+
+		return err
+	}
+	log.Warnf("Dead-lettered %d records of %s after %d retries: %s", len(filteredKeys), pmp.key, pmp.retry.MaxRetries, err.Error())
+
+	return nil
+}
+
+// retryPumpWriting writes keys to pmp, and retries with exponential backoff on failure.
+func retryPumpWriting(ctx context.Context, pmp *configuredPump, keys []interface{}, purgeDelay int) error {
+	for attempts := 1; ; attempts++ {
+		err := execPumpWriting(ctx, pmp, keys, purgeDelay)
+		if err == nil || attempts > pmp.retry.MaxRetries {
+			return err
+		}
+
+		backoff := pmp.retry.Backoff(attempts)
+		log.Warnf("Retry writing to %s in %s (%d/%d)", pmp.key, backoff, attempts, pmp.retry.MaxRetries)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 }
 
 func filterData(pump pumps.Pump, keys []interface{}) []interface{} {
 	filters := pump.GetFilters()
-	if !filters.HasFilter() && !pump.GetOmitDetailedRecording() {
+	transforms := pump.GetTransforms()
+	if !filters.HasFilter() && !transforms.HasTransforms() && !pump.GetOmitDetailedRecording() {
 		return keys
 	}
-	filteredKeys := keys[:] // nolint: gocritic
-	newLenght := 0
+	// keys are shared by all pumps and kept for dead-lettering, so filter into a new slice.
+	filteredKeys := make([]interface{}, 0, len(keys))
 
-	for _, key := range filteredKeys {
+	for _, key := range keys {
 		decoded, _ := key.(analytics.AnalyticsRecord)
 		if pump.GetOmitDetailedRecording() {
 			decoded.Policies = ""
@@ -188,15 +427,14 @@ func filterData(pump pumps.Pump, keys []interface{}) []interface{} {
 		if filters.ShouldFilter(decoded) {
 			continue
 		}
-		filteredKeys[newLenght] = decoded
-		newLenght++
+		// filter on the original request, the transforms may remove the filtered fields.
+		filteredKeys = append(filteredKeys, transforms.Transform(decoded))
 	}
-	filteredKeys = filteredKeys[:newLenght]
 
 	return filteredKeys
 }
 
-func execPumpWriting(wg *sync.WaitGroup, pmp pumps.Pump, keys *[]interface{}, purgeDelay int) {
+func execPumpWriting(ctx context.Context, pmp pumps.Pump, keys []interface{}, purgeDelay int) (err error) {
 	timer := time.AfterFunc(time.Duration(purgeDelay)*time.Second, func() {
 		if pmp.GetTimeout() == 0 {
 			log.Warnf(
@@ -208,40 +446,43 @@ func execPumpWriting(wg *sync.WaitGroup, pmp pumps.Pump, keys *[]interface{}, pu
 		}
 	})
 	defer timer.Stop()
-	defer wg.Done()
 
 	log.Debugf("Writing to: %s", pmp.GetName())
 
+	ctx, span := tracing.Start(ctx, "pump.write")
+	span.SetAttributes(attribute.String("pump.name", pmp.GetName()))
+	defer func() { tracing.End(span, err) }()
+
 	ch := make(chan error, 1)
-	var ctx context.Context
 	var cancel context.CancelFunc
 	// Initialize context depending if the pump has a configured timeout
 	if tm := pmp.GetTimeout(); tm > 0 {
-		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(tm)*time.Second)
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(tm)*time.Second)
 	} else {
-		ctx, cancel = context.WithCancel(context.Background())
+		ctx, cancel = context.WithCancel(ctx)
 	}
 
 	defer cancel()
 
-	go func(ch chan error, ctx context.Context, pmp pumps.Pump, keys *[]interface{}) {
-		filteredKeys := filterData(pmp, *keys)
-
-		ch <- pmp.WriteData(ctx, filteredKeys)
+	go func(ch chan error, ctx context.Context, pmp pumps.Pump, keys []interface{}) {
+		ch <- pmp.WriteData(ctx, keys)
 	}(ch, ctx, pmp, keys)
 
 	select {
-	case err := <-ch:
+	case err = <-ch:
 		if err != nil {
 			log.Warnf("Error Writing to: %s - Error: %s", pmp.GetName(), err.Error())
 		}
 	case <-ctx.Done():
+		err = ctx.Err()
 		//nolint: errorlint
-		switch ctx.Err() {
+		switch err {
 		case context.Canceled:
 			log.Warnf("The writing to %s have got canceled.", pmp.GetName())
 		case context.DeadlineExceeded:
 			log.Warnf("Timeout Writing to: %s", pmp.GetName())
 		}
 	}
+
+	return err
 }
@@ -0,0 +1,111 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pump
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/internal/pump/analytics"
+	"github.com/marmotedu/iam/internal/pump/deadletter"
+	"github.com/marmotedu/iam/internal/pump/options"
+	"github.com/marmotedu/iam/internal/pump/pumps"
+)
+
+// flakyPump fails the first failures writes.
+type flakyPump struct {
+	pumps.CommonPumpConfig
+	failures int
+	writes   int
+}
+
+func (p *flakyPump) GetName() string             { return "flaky" }
+func (p *flakyPump) New() pumps.Pump             { return &flakyPump{} }
+func (p *flakyPump) Init(conf interface{}) error { return nil }
+
+func (p *flakyPump) WriteData(ctx context.Context, data []interface{}) error {
+	p.writes++
+	if p.writes <= p.failures {
+		return errors.New("unavailable")
+	}
+
+	return nil
+}
+
+func TestDeliver(t *testing.T) {
+	retry := options.RetryOptions{MaxRetries: 2, InitialBackoff: time.Millisecond}
+	keys := []interface{}{analytics.AnalyticsRecord{Username: "colin"}}
+
+	tests := []struct {
+		name           string
+		failures       int
+		paused         bool
+		deadLetters    bool
+		wantErr        bool
+		wantWrites     int
+		wantDeadLetter bool
+	}{
+		{name: "retried until success", failures: 2, deadLetters: true, wantWrites: 3},
+		{name: "dead-lettered", failures: 3, deadLetters: true, wantWrites: 3, wantDeadLetter: true},
+		{name: "kept without dead-letter queue", failures: 3, wantErr: true, wantWrites: 3},
+		{name: "paused is dead-lettered", paused: true, deadLetters: true, wantWrites: 0, wantDeadLetter: true},
+		{name: "paused is kept without dead-letter queue", paused: true, wantErr: true, wantWrites: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &flakyPump{failures: tt.failures}
+			pmp := &configuredPump{Pump: fake, key: "flaky", retry: retry}
+			pmp.stats.setPaused(tt.paused)
+
+			var queue *deadletter.Queue
+			if tt.deadLetters {
+				queue = deadletter.NewQueue(t.TempDir())
+			}
+
+			err := deliver(context.Background(), pmp, keys, 10, queue)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("deliver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if fake.writes != tt.wantWrites {
+				t.Errorf("writes = %d, want %d", fake.writes, tt.wantWrites)
+			}
+
+			if queue != nil {
+				batches, _ := queue.Batches("flaky")
+				if (len(batches) == 1) != tt.wantDeadLetter {
+					t.Errorf("dead-lettered batches = %v, want dead-lettered %v", batches, tt.wantDeadLetter)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterData(t *testing.T) {
+	pmp := &flakyPump{}
+	pmp.SetFilters(analytics.AnalyticsFilters{Match: &analytics.FilterRule{Field: "context.remoteIP", Glob: "10.*"}})
+	pmp.SetTransforms(analytics.AnalyticsTransforms{Drop: []string{"context.remoteIP"}})
+
+	keys := []interface{}{
+		analytics.AnalyticsRecord{Username: "colin", Request: `{"context":{"remoteIP":"10.0.0.1"}}`},
+		analytics.AnalyticsRecord{Username: "james", Request: `{"context":{"remoteIP":"192.168.0.1"}}`},
+	}
+
+	got := filterData(pmp, keys)
+	if len(got) != 1 {
+		t.Fatalf("filterData() = %v, want 1 record", got)
+	}
+
+	// filtered on the original request, written with the transformed one.
+	if record, _ := got[0].(analytics.AnalyticsRecord); record.Username != "colin" || record.Request != `{"context":{}}` {
+		t.Errorf("filterData() = %+v, want the request of colin without remoteIP", record)
+	}
+	if record, _ := keys[0].(analytics.AnalyticsRecord); record.Request != `{"context":{"remoteIP":"10.0.0.1"}}` {
+		t.Errorf("keys are modified: %+v", record)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pump
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/iam/internal/pump/storage"
+)
+
+// States of the pumps.
+const (
+	pumpStateActive = "active"
+	pumpStatePaused = "paused"
+	// pumpStateFailed is the state of a pump failed to initialize, which is never written.
+	pumpStateFailed = "failed"
+)
+
+// pumpStats are the runtime statistics of a pump, it is safe for concurrent use.
+type pumpStats struct {
+	mu            sync.Mutex
+	paused        bool
+	lastWrite     time.Time
+	lastError     string
+	lastErrorTime time.Time
+	written       int64
+	filtered      int64
+	failed        int64
+	skipped       int64
+	writes        int64
+	latency       time.Duration
+}
+
+// pumpStatus is the status of a pump shown by the admin API.
+type pumpStatus struct {
+	Key                 string     `json:"key"`
+	Name                string     `json:"name,omitempty"`
+	State               string     `json:"state"`
+	LastSuccessfulWrite *time.Time `json:"lastSuccessfulWrite,omitempty"`
+	RecordsWritten      int64      `json:"recordsWritten"`
+	RecordsFiltered     int64      `json:"recordsFiltered"`
+	RecordsFailed       int64      `json:"recordsFailed"`
+	// RecordsSkipped are the records not written as the pump is paused.
+	RecordsSkipped int64      `json:"recordsSkipped"`
+	LastError      string     `json:"lastError,omitempty"`
+	LastErrorTime  *time.Time `json:"lastErrorTime,omitempty"`
+	AverageLatency string     `json:"averageLatency,omitempty"`
+}
+
+// serverStatus is the status of the pump server shown by the admin API.
+type serverStatus struct {
+	Input string `json:"input"`
+	// Backlog is the number of records waiting in the input, if the input is able to count them.
+	Backlog      *int64       `json:"backlog,omitempty"`
+	BacklogError string       `json:"backlogError,omitempty"`
+	Pumps        []pumpStatus `json:"pumps"`
+}
+
+// recordWrite records the result of writing n records.
+func (s *pumpStats) recordWrite(n int, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes++
+	s.latency += latency
+	if err != nil {
+		s.failed += int64(n)
+		s.lastError = err.Error()
+		s.lastErrorTime = time.Now()
+
+		return
+	}
+
+	s.written += int64(n)
+	s.lastWrite = time.Now()
+}
+
+func (s *pumpStats) recordFiltered(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.filtered += int64(n)
+}
+
+func (s *pumpStats) recordSkipped(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.skipped += int64(n)
+}
+
+func (s *pumpStats) setPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.paused = paused
+}
+
+func (s *pumpStats) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.paused
+}
+
+// status returns the status of the pump key from its statistics.
+func (s *pumpStats) status(key, name string) pumpStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := pumpStatus{
+		Key:             key,
+		Name:            name,
+		State:           pumpStateActive,
+		RecordsWritten:  s.written,
+		RecordsFiltered: s.filtered,
+		RecordsFailed:   s.failed,
+		RecordsSkipped:  s.skipped,
+		LastError:       s.lastError,
+	}
+	if s.paused {
+		status.State = pumpStatePaused
+	}
+	if !s.lastWrite.IsZero() {
+		lastWrite := s.lastWrite
+		status.LastSuccessfulWrite = &lastWrite
+	}
+	if !s.lastErrorTime.IsZero() {
+		lastErrorTime := s.lastErrorTime
+		status.LastErrorTime = &lastErrorTime
+	}
+	if s.writes > 0 {
+		status.AverageLatency = (s.latency / time.Duration(s.writes)).String()
+	}
+
+	return status
+}
+
+// status returns the status of the input and of the configured pumps, sorted by key.
+func (s *pumpServer) status() serverStatus {
+	status := serverStatus{Input: s.analyticsStore.GetName()}
+
+	if backlog, ok := s.analyticsStore.(storage.BacklogStorage); ok {
+		n, err := backlog.Len(storage.AnalyticsKeyName)
+		if err != nil {
+			status.BacklogError = err.Error()
+		} else {
+			status.Backlog = &n
+		}
+	}
+
+	status.Pumps = make([]pumpStatus, 0, len(pmps)+len(s.initErrors))
+	for _, pmp := range pmps {
+		if pmp != nil {
+			status.Pumps = append(status.Pumps, pmp.stats.status(pmp.key, pmp.GetName()))
+		}
+	}
+	for key, err := range s.initErrors {
+		status.Pumps = append(status.Pumps, pumpStatus{Key: key, State: pumpStateFailed, LastError: err})
+	}
+	sort.Slice(status.Pumps, func(i, j int) bool { return status.Pumps[i].Key < status.Pumps[j].Key })
+
+	return status
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package kafka provides a kafka implementation of the AnalyticsStorage storage interface.
+package kafka
+
+import (
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// Config defines options for the kafka storage.
+type Config struct {
+	KafkaBrokers []string `mapstructure:"kafka-brokers"`
+	KafkaTopic   string   `mapstructure:"kafka-topic"`
+	KafkaGroupID string   `mapstructure:"kafka-group-id"`
+}
+
+// KafkaStorage consumes the analytics records produced to a kafka topic by iam-authz-server.
+// iam-pump instances sharing the consumer group split the partitions of the topic.
+type KafkaStorage struct {
+	kafka *storage.Kafka
+}
+
+// GetName returns the kafka storage name.
+func (k *KafkaStorage) GetName() string {
+	return "kafka"
+}
+
+// Init initialize the kafka storage.
+func (k *KafkaStorage) Init(config interface{}) error {
+	conf := Config{}
+	if err := mapstructure.Decode(config, &conf); err != nil {
+		log.Fatalf("Failed to decode configuration: %s", err.Error())
+	}
+
+	k.kafka = storage.NewKafka(storage.KafkaConfig{
+		Brokers: conf.KafkaBrokers,
+		Topic:   conf.KafkaTopic,
+		GroupID: conf.KafkaGroupID,
+	})
+
+	return nil
+}
+
+// Connect does nothing, the consumer connects to the brokers on the first read.
+func (k *KafkaStorage) Connect() bool {
+	return true
+}
+
+// GetSet consumes the records available in the topic, ack commits them.
+func (k *KafkaStorage) GetSet(keyName string) ([]interface{}, func() error) {
+	return k.kafka.FetchSet(keyName)
+}
@@ -243,11 +243,10 @@ func (r *RedisClusterStorageManager) Connect() bool {
 		return true
 	}
 
+	// r.db is the singleton already, it is not reassigned so that Len can read it from
+	// other goroutines.
 	log.Debug("Storage Engine already initialized...")
 
-	// Reset it just in case
-	r.db = redisClusterSingleton
-
 	return true
 }
 
@@ -304,6 +303,50 @@ func (r *RedisClusterStorageManager) GetAndDeleteSet(keyName string) []interface
 	return result
 }
 
+// GetSet gets the values of key from redis, ack removes them from the head of the list.
+// Values appended by iam-authz-server in the meantime are kept.
+func (r *RedisClusterStorageManager) GetSet(keyName string) ([]interface{}, func() error) {
+	if r.db == nil {
+		log.Warn("Connection dropped, connecting..")
+		r.Connect()
+	}
+
+	fixedKey := r.fixKey(keyName)
+
+	vals, err := r.db.LRange(fixedKey, 0, -1).Result()
+	if err != nil {
+		log.Errorf("Failed to get raw key set %s: %s", fixedKey, err)
+		r.Connect()
+	}
+
+	result := make([]interface{}, len(vals))
+	for i, v := range vals {
+		result[i] = v
+	}
+
+	log.Debugf("Unpacked vals: %d", len(result))
+
+	ack := func() error {
+		if len(vals) == 0 {
+			return nil
+		}
+
+		return r.db.LTrim(fixedKey, int64(len(vals)), -1).Err()
+	}
+
+	return result, ack
+}
+
+// Len returns the number of values of key waiting to be pumped. It is called by the admin API
+// concurrently with the pumping, so it only reads the client and never connects.
+func (r *RedisClusterStorageManager) Len(keyName string) (int64, error) {
+	if r.db == nil {
+		return 0, errors.New("redis is not connected")
+	}
+
+	return r.db.LLen(r.fixKey(keyName)).Result()
+}
+
 // SetKey will create (or update) a key value in the store.
 func (r *RedisClusterStorageManager) SetKey(keyName, session string, timeout int64) error {
 	log.Debugf("[STORE] SET Raw key is: %s", keyName)
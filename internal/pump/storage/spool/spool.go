@@ -0,0 +1,63 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package spool provides a local spool directory implementation of the AnalyticsStorage storage interface.
+package spool
+
+import (
+	"os"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// Config defines options for the spool storage.
+type Config struct {
+	SpoolDir string `mapstructure:"spool-dir"`
+}
+
+// SpoolStorage reads the analytics records appended to a spool directory by iam-authz-server.
+type SpoolStorage struct {
+	config Config
+}
+
+// GetName returns the spool storage name.
+func (s *SpoolStorage) GetName() string {
+	return "spool"
+}
+
+// Init initialize the spool storage.
+func (s *SpoolStorage) Init(config interface{}) error {
+	if err := mapstructure.Decode(config, &s.config); err != nil {
+		log.Fatalf("Failed to decode configuration: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Connect creates the spool directory if iam-authz-server has not created it yet.
+func (s *SpoolStorage) Connect() bool {
+	if err := os.MkdirAll(s.config.SpoolDir, 0o750); err != nil {
+		log.Errorf("Failed to create spool directory %s: %s", s.config.SpoolDir, err.Error())
+
+		return false
+	}
+
+	return true
+}
+
+// GetSet reads the sealed spool segments of keyName, ack deletes them.
+func (s *SpoolStorage) GetSet(keyName string) ([]interface{}, func() error) {
+	values, ack, err := storage.PeekSpool(s.config.SpoolDir, keyName)
+	if err != nil {
+		log.Errorf("Failed to read spool: %s", err.Error())
+	}
+	if ack == nil {
+		ack = func() error { return nil }
+	}
+
+	return values, ack
+}
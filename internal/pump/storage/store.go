@@ -10,7 +10,15 @@ type AnalyticsStorage interface {
 	Init(config interface{}) error
 	GetName() string
 	Connect() bool
-	GetAndDeleteSet(string) []interface{}
+	// GetSet returns the records of a key without deleting them. The returned ack function deletes
+	// the returned records, it is called once the records are written by all pumps.
+	GetSet(string) ([]interface{}, func() error)
+}
+
+// BacklogStorage is implemented by the analytics storages able to count the records waiting to be pumped.
+type BacklogStorage interface {
+	// Len returns the number of records of a key.
+	Len(keyName string) (int64, error)
 }
 
 const (
@@ -11,7 +11,9 @@ import (
 
 // Run runs the specified pump server. This should never exit.
 func Run(cfg *config.Config) error {
-	go genericapiserver.ServeHealthCheck(cfg.HealthCheckPath, cfg.HealthCheckAddress)
+	prepared := createWatcherServer(cfg).PrepareRun()
 
-	return createWatcherServer(cfg).PrepareRun().Run()
+	go genericapiserver.ServeHealthCheck(cfg.HealthCheckPath, cfg.HealthCheckAddress, prepared.readyzChecks()...)
+
+	return prepared.Run()
 }
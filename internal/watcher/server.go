@@ -5,19 +5,25 @@
 package watcher
 
 import (
+	"context"
+	"net/http"
 	"time"
 
+	"github.com/marmotedu/iam/internal/apiserver/store"
 	"github.com/marmotedu/iam/internal/apiserver/store/mysql"
 	genericoptions "github.com/marmotedu/iam/internal/pkg/options"
+	"github.com/marmotedu/iam/internal/pkg/server/healthz"
 	"github.com/marmotedu/iam/internal/watcher/config"
 	"github.com/marmotedu/iam/internal/watcher/options"
 	"github.com/marmotedu/iam/pkg/log"
 	"github.com/marmotedu/iam/pkg/shutdown"
 	"github.com/marmotedu/iam/pkg/shutdown/shutdownmanagers/posixsignal"
+	"github.com/marmotedu/iam/pkg/storage"
 )
 
 type watcherServer struct {
 	gs             *shutdown.GracefulShutdown
+	store          store.Factory
 	cron           *watchJob
 	redisOptions   *genericoptions.RedisOptions
 	mysqlOptions   *genericoptions.MySQLOptions
@@ -50,15 +56,61 @@ func (s *watcherServer) PrepareRun() preparedWatcherServer {
 		panic(err)
 	}
 
+	s.store = mysqlStore
 	s.gs.AddShutdownCallback(shutdown.ShutdownFunc(func(string) error {
 		return mysqlStore.Close()
 	}))
 
+	// keep redis connected, watchers use it to publish notifications.
+	ctx, cancel := context.WithCancel(context.Background())
+	go storage.ConnectToRedis(ctx, s.buildStorageConfig())
+
+	s.gs.AddShutdownCallback(shutdown.ShutdownFunc(func(string) error {
+		cancel()
+
+		return nil
+	}))
+
 	s.cron = newWatchJob(s.redisOptions, s.watcherOptions).addWatchers()
 
 	return preparedWatcherServer{s}
 }
 
+// readyzChecks returns the checks which report whether the watcher server is able to work.
+func (s *watcherServer) readyzChecks() []healthz.Checker {
+	checks := []healthz.Checker{
+		healthz.RedisCheck(),
+		// watchers take a distributed lock before running.
+		healthz.NamedCheck("lock", func(r *http.Request) error {
+			return s.cron.client.Ping(r.Context()).Err()
+		}),
+	}
+
+	if pinger, ok := s.store.(healthz.Pinger); ok {
+		checks = append(checks, healthz.PingCheck("mysql", pinger))
+	}
+
+	return checks
+}
+
+func (s *watcherServer) buildStorageConfig() *storage.Config {
+	return &storage.Config{
+		Host:                  s.redisOptions.Host,
+		Port:                  s.redisOptions.Port,
+		Addrs:                 s.redisOptions.Addrs,
+		MasterName:            s.redisOptions.MasterName,
+		Username:              s.redisOptions.Username,
+		Password:              s.redisOptions.Password,
+		Database:              s.redisOptions.Database,
+		MaxIdle:               s.redisOptions.MaxIdle,
+		MaxActive:             s.redisOptions.MaxActive,
+		Timeout:               s.redisOptions.Timeout,
+		EnableCluster:         s.redisOptions.EnableCluster,
+		UseSSL:                s.redisOptions.UseSSL,
+		SSLInsecureSkipVerify: s.redisOptions.SSLInsecureSkipVerify,
+	}
+}
+
 func (s preparedWatcherServer) Run() error {
 	stopCh := make(chan struct{})
 	s.gs.AddShutdownCallback(shutdown.ShutdownFunc(func(string) error {
@@ -27,6 +27,7 @@ import (
 type watchJob struct {
 	*cron.Cron
 	config *options.WatcherOptions
+	client *goredislib.Client
 	rs     *redsync.Redsync
 }
 
@@ -49,6 +50,7 @@ func newWatchJob(redisOptions *genericoptions.RedisOptions, watcherOptions *opti
 	return &watchJob{
 		Cron:   cronjob,
 		config: watcherOptions,
+		client: client,
 		rs:     rs,
 	}
 }
@@ -7,5 +7,6 @@ package all
 // nolint: golint
 import (
 	_ "github.com/marmotedu/iam/internal/watcher/watcher/clean"
+	_ "github.com/marmotedu/iam/internal/watcher/watcher/grant"
 	_ "github.com/marmotedu/iam/internal/watcher/watcher/task"
 )
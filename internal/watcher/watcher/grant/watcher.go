@@ -0,0 +1,101 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package grant
+
+import (
+	"context"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/go-redsync/redsync/v4"
+	"github.com/marmotedu/component-base/pkg/json"
+	metav1 "github.com/marmotedu/component-base/pkg/meta/v1"
+
+	"github.com/marmotedu/iam/internal/apiserver/store/mysql"
+	"github.com/marmotedu/iam/internal/authzserver/load"
+	"github.com/marmotedu/iam/internal/watcher/watcher"
+	"github.com/marmotedu/iam/pkg/grant"
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+type grantWatcher struct {
+	ctx   context.Context
+	mutex *redsync.Mutex
+}
+
+// Run runs the watcher job.
+func (gw *grantWatcher) Run() {
+	if err := gw.mutex.Lock(); err != nil {
+		log.L(gw.ctx).Info("grantWatcher already run.")
+
+		return
+	}
+	defer func() {
+		if _, err := gw.mutex.Unlock(); err != nil {
+			log.L(gw.ctx).Errorf("could not release grantWatcher lock. err: %v", err)
+
+			return
+		}
+	}()
+
+	db, _ := mysql.GetMySQLFactoryOr(nil)
+
+	policies, err := db.Policies().List(gw.ctx, "", metav1.ListOptions{Limit: pointer.ToInt64(-1)})
+	if err != nil {
+		log.L(gw.ctx).Errorw("list policies failed", "error", err)
+
+		return
+	}
+
+	now := time.Now()
+	deleted := 0
+	for _, policy := range policies.Items {
+		g := grant.FromPolicy(&policy.Policy.DefaultPolicy)
+		if g == nil || !g.Expired(now) {
+			continue
+		}
+
+		if err := db.Policies().Delete(gw.ctx, policy.Username, policy.Name, metav1.DeleteOptions{}); err != nil {
+			log.L(gw.ctx).Errorw("delete expired grant failed", "username", policy.Username, "name", policy.Name, "error", err)
+
+			continue
+		}
+
+		log.L(gw.ctx).Infof("grant %s of user %s approved by %s expired at %s, deleted",
+			policy.Name, policy.Username, g.Approver, g.NotAfter.Format(time.RFC3339))
+		deleted++
+	}
+
+	if deleted == 0 {
+		return
+	}
+
+	redisStore := &storage.RedisCluster{}
+	message, _ := json.Marshal(load.Notification{Command: load.NoticePolicyChanged})
+
+	if err := redisStore.Publish(load.RedisPubSubChannel, string(message)); err != nil {
+		log.L(gw.ctx).Errorw("publish redis message failed", "error", err.Error())
+	}
+}
+
+// Spec is parsed using the time zone of grant Cron instance as the default.
+func (gw *grantWatcher) Spec() string {
+	return "@every 1m"
+}
+
+// Init initializes the watcher for later execution.
+func (gw *grantWatcher) Init(ctx context.Context, rs *redsync.Mutex, config interface{}) error {
+	*gw = grantWatcher{
+		ctx:   ctx,
+		mutex: rs,
+	}
+
+	return nil
+}
+
+func init() {
+	watcher.Register("grant", &grantWatcher{})
+}
@@ -0,0 +1,273 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ory/ladon"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// KeyName is the key of the set the records are appended to, iam-pump consumes it.
+const KeyName = "iam-system-analytics"
+
+const (
+	recordsBufferForcedFlushInterval = 1 * time.Second
+)
+
+// Overflow policies of the records buffer.
+const (
+	// OverflowBlock blocks the authorization until the record is buffered.
+	OverflowBlock = "block"
+	// OverflowDropNewest drops the record which does not fit in the buffer.
+	OverflowDropNewest = "drop-newest"
+	// OverflowDropOldest drops the oldest buffered record to make room for the new one.
+	OverflowDropOldest = "drop-oldest"
+)
+
+// Reasons a record is dropped, reported to the drop observer.
+const (
+	DropReasonStopped  = "stopped"
+	DropReasonSampled  = "sampled"
+	DropReasonOverflow = "overflow"
+	DropReasonEncoding = "encoding"
+)
+
+// Record encodes the details of a authorization request. It is encoded with msgpack,
+// so the field names must not change.
+type Record struct {
+	TimeStamp  int64     `json:"timestamp"`
+	Username   string    `json:"username"`
+	Effect     string    `json:"effect"`
+	Conclusion string    `json:"conclusion"`
+	Request    string    `json:"request"`
+	Policies   string    `json:"policies"`
+	Deciders   string    `json:"deciders"`
+	ExpireAt   time.Time `json:"expireAt"   bson:"expireAt"`
+}
+
+// SetExpiry set expiration time to a key.
+func (a *Record) SetExpiry(expiresInSeconds int64) {
+	expiry := time.Duration(expiresInSeconds) * time.Second
+	if expiresInSeconds == 0 {
+		// Expiry is set to 100 years
+		expiry = 24 * 365 * 100 * time.Hour
+	}
+
+	t := time.Now()
+	t2 := t.Add(expiry)
+	a.ExpireAt = t2
+}
+
+// Store appends the encoded records to a set, storage.AnalyticsHandler implements it.
+type Store interface {
+	AppendToSetPipelined(string, [][]byte)
+}
+
+// Options defines options for the recorder.
+type Options struct {
+	// PoolSize is the number of workers writing records to the store.
+	PoolSize int
+	// RecordsBufferSize is the number of records buffered in memory.
+	RecordsBufferSize uint64
+	// FlushInterval is the interval in milliseconds to write the buffered records.
+	FlushInterval uint64
+	// StorageExpirationTime is how long iam-pump keeps the records, 0 means 100 years.
+	StorageExpirationTime time.Duration
+	// EnableDetailedRecording records the policies and deciders of the decisions.
+	EnableDetailedRecording bool
+	// OverflowPolicy is one of OverflowBlock, OverflowDropNewest and OverflowDropOldest.
+	OverflowPolicy string
+	// SampleRate is the fraction of allowed decisions which are recorded.
+	SampleRate float64
+	// UserSampleRates overrides SampleRate for the given usernames.
+	UserSampleRates map[string]float64
+}
+
+// Recorder buffers the records and writes them to the store with a pool of workers.
+type Recorder struct {
+	store            Store
+	opts             Options
+	workerBufferSize uint64
+	onDrop           func(reason string)
+
+	// lock guards records against being closed while a record is sent.
+	lock    sync.RWMutex
+	stopped bool
+	records chan *Record
+	poolWg  sync.WaitGroup
+}
+
+// NewRecorder returns a recorder which writes the records to store. Records are buffered
+// until Start is called.
+func NewRecorder(opts *Options, store Store) *Recorder {
+	workerBufferSize := opts.RecordsBufferSize / uint64(opts.PoolSize)
+	log.Debug("Analytics pool worker buffer size", log.Uint64("workerBufferSize", workerBufferSize))
+
+	return &Recorder{
+		store:            store,
+		opts:             *opts,
+		workerBufferSize: workerBufferSize,
+		onDrop:           func(string) {},
+		records:          make(chan *Record, opts.RecordsBufferSize),
+	}
+}
+
+// WithDropObserver sets the function called with the reason every time a record is dropped.
+func (r *Recorder) WithDropObserver(fn func(reason string)) *Recorder {
+	r.onDrop = fn
+
+	return r
+}
+
+// Start starts the worker pool.
+func (r *Recorder) Start() {
+	for i := 0; i < r.opts.PoolSize; i++ {
+		r.poolWg.Add(1)
+		go r.recordWorker()
+	}
+}
+
+// Stop stops accepting records and waits for the workers to write the buffered ones.
+func (r *Recorder) Stop() {
+	r.lock.Lock()
+	r.stopped = true
+	close(r.records)
+	r.lock.Unlock()
+
+	r.poolWg.Wait()
+}
+
+// Queued returns the number of records waiting to be written.
+func (r *Recorder) Queued() int {
+	return len(r.records)
+}
+
+// Record buffers the record. Allowed decisions may be sampled out, and records may be
+// dropped when the buffer is full depending on the overflow policy.
+func (r *Recorder) Record(record *Record) {
+	if !r.sampled(record) {
+		r.onDrop(DropReasonSampled)
+
+		return
+	}
+
+	if !r.opts.EnableDetailedRecording {
+		record.Policies = ""
+		record.Deciders = ""
+	}
+	record.SetExpiry(int64(r.opts.StorageExpirationTime / time.Second))
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if r.stopped {
+		r.onDrop(DropReasonStopped)
+
+		return
+	}
+
+	// just send record to channel consumed by pool of workers
+	// leave all data crunching and I/O work for pool workers
+	switch r.opts.OverflowPolicy {
+	case OverflowDropNewest:
+		select {
+		case r.records <- record:
+		default:
+			r.onDrop(DropReasonOverflow)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case r.records <- record:
+				return
+			default:
+			}
+
+			// make room for the record, the workers may have done it already.
+			select {
+			case <-r.records:
+				r.onDrop(DropReasonOverflow)
+			default:
+			}
+		}
+	default:
+		r.records <- record
+	}
+}
+
+// sampled reports whether the record should be recorded. Denied decisions are always recorded.
+func (r *Recorder) sampled(record *Record) bool {
+	if record.Effect != ladon.AllowAccess {
+		return true
+	}
+
+	rate := r.opts.SampleRate
+	if userRate, ok := r.opts.UserSampleRates[record.Username]; ok {
+		rate = userRate
+	}
+
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	default:
+		return rand.Float64() < rate // nolint: gosec
+	}
+}
+
+func (r *Recorder) recordWorker() {
+	defer r.poolWg.Done()
+
+	// this is buffer to send one pipelined command to the store
+	// use r.workerBufferSize as cap to reduce slice re-allocations
+	recordsBuffer := make([][]byte, 0, r.workerBufferSize)
+
+	// read records from channel and process
+	lastSentTS := time.Now()
+	for {
+		var readyToSend bool
+		select {
+		case record, ok := <-r.records:
+			// check if channel was closed and it is time to exit from worker
+			if !ok {
+				// send what is left in buffer
+				r.store.AppendToSetPipelined(KeyName, recordsBuffer)
+
+				return
+			}
+
+			// we have new record - prepare it and add to buffer
+
+			if encoded, err := msgpack.Marshal(record); err != nil {
+				log.Errorf("Error encoding analytics data: %s", err.Error())
+				r.onDrop(DropReasonEncoding)
+			} else {
+				recordsBuffer = append(recordsBuffer, encoded)
+			}
+
+			// identify that buffer is ready to be sent
+			readyToSend = uint64(len(recordsBuffer)) == r.workerBufferSize
+
+		case <-time.After(time.Duration(r.opts.FlushInterval) * time.Millisecond):
+			// nothing was received for that period of time
+			// anyways send whatever we have, don't hold data too long in buffer
+			readyToSend = true
+		}
+
+		// send data to the store and reset buffer
+		if len(recordsBuffer) > 0 && (readyToSend || time.Since(lastSentTS) >= recordsBufferForcedFlushInterval) {
+			r.store.AppendToSetPipelined(KeyName, recordsBuffer)
+			recordsBuffer = recordsBuffer[:0]
+			lastSentTS = time.Now()
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package analytics
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ory/ladon"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type fakeStore struct {
+	lock   sync.Mutex
+	values map[string][][]byte
+}
+
+func (s *fakeStore) AppendToSetPipelined(key string, values [][]byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, val := range values {
+		s.values[key] = append(s.values[key], append([]byte(nil), val...))
+	}
+}
+
+func newTestRecorder(modify func(*Options)) *Recorder {
+	opts := &Options{
+		PoolSize:                1,
+		RecordsBufferSize:       2,
+		FlushInterval:           200,
+		EnableDetailedRecording: true,
+		OverflowPolicy:          OverflowBlock,
+		SampleRate:              1,
+	}
+	modify(opts)
+
+	return NewRecorder(opts, &fakeStore{values: map[string][][]byte{}})
+}
+
+func bufferedConclusions(r *Recorder) []string {
+	conclusions := []string{}
+	for len(r.records) > 0 {
+		conclusions = append(conclusions, (<-r.records).Conclusion)
+	}
+
+	return conclusions
+}
+
+func TestRecorder_Record_Overflow(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		want        []string
+		wantDropped int
+	}{
+		{
+			name:        "drop newest",
+			policy:      OverflowDropNewest,
+			want:        []string{"1", "2"},
+			wantDropped: 1,
+		},
+		{
+			name:        "drop oldest",
+			policy:      OverflowDropOldest,
+			want:        []string{"2", "3"},
+			wantDropped: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dropped := 0
+			r := newTestRecorder(func(o *Options) {
+				o.OverflowPolicy = tt.policy
+			}).WithDropObserver(func(reason string) {
+				if reason == DropReasonOverflow {
+					dropped++
+				}
+			})
+
+			for _, conclusion := range []string{"1", "2", "3"} {
+				r.Record(&Record{Effect: ladon.DenyAccess, Conclusion: conclusion})
+			}
+
+			if got := bufferedConclusions(r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buffered records = %v, want %v", got, tt.want)
+			}
+			if dropped != tt.wantDropped {
+				t.Errorf("dropped records = %d, want %d", dropped, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestRecorder_Record_Sampling(t *testing.T) {
+	r := newTestRecorder(func(o *Options) {
+		o.SampleRate = 0
+		o.UserSampleRates = map[string]float64{"admin": 1}
+	})
+
+	r.Record(&Record{Username: "colin", Effect: ladon.AllowAccess, Conclusion: "sampled out"})
+	r.Record(&Record{Username: "colin", Effect: ladon.DenyAccess, Conclusion: "deny"})
+	r.Record(&Record{Username: "admin", Effect: ladon.AllowAccess, Conclusion: "admin allow"})
+
+	want := []string{"deny", "admin allow"}
+	if got := bufferedConclusions(r); !reflect.DeepEqual(got, want) {
+		t.Errorf("buffered records = %v, want %v", got, want)
+	}
+}
+
+func TestRecorder_Record_Detail(t *testing.T) {
+	r := newTestRecorder(func(o *Options) {
+		o.EnableDetailedRecording = false
+		o.StorageExpirationTime = time.Hour
+	})
+
+	record := &Record{Effect: ladon.DenyAccess, Policies: "p", Deciders: "d"}
+	r.Record(record)
+
+	if record.Policies != "" || record.Deciders != "" {
+		t.Errorf("detailed fields are recorded: policies %q, deciders %q", record.Policies, record.Deciders)
+	}
+
+	if expiry := time.Until(record.ExpireAt); expiry <= 0 || expiry > time.Hour {
+		t.Errorf("record expires in %v, want about 1h", expiry)
+	}
+}
+
+func TestRecorder_Stop(t *testing.T) {
+	store := &fakeStore{values: map[string][][]byte{}}
+	r := NewRecorder(&Options{
+		PoolSize:          2,
+		RecordsBufferSize: 10,
+		FlushInterval:     1000,
+		SampleRate:        1,
+	}, store)
+	r.Start()
+
+	for _, username := range []string{"colin", "ken", "tom"} {
+		r.Record(&Record{Username: username, Effect: ladon.AllowAccess})
+	}
+
+	// the flush interval is not reached, the records are written when the recorder stops.
+	r.Stop()
+	r.Record(&Record{Username: "late", Effect: ladon.DenyAccess})
+
+	got := []string{}
+	for _, val := range store.values[KeyName] {
+		var record Record
+		if err := msgpack.Unmarshal(val, &record); err != nil {
+			t.Fatalf("msgpack.Unmarshal() error = %v", err)
+		}
+		got = append(got, record.Username)
+	}
+	sort.Strings(got)
+
+	want := []string{"colin", "ken", "tom"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("written records = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,6 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package analytics buffers authorization analytics records and writes them to the set consumed by iam-pump.
+package analytics // import "github.com/marmotedu/iam/pkg/analytics"
@@ -0,0 +1,47 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	redis "github.com/go-redis/redis/v7"
+
+	"github.com/marmotedu/iam/pkg/analytics"
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// analyticsKeyPrefix must be the same as the key prefix of iam-authz-server, so that
+// iam-pump consumes the forwarded records.
+const analyticsKeyPrefix = "analytics-"
+
+// redisAnalyticsStore appends the encoded records to the redis list consumed by iam-pump.
+type redisAnalyticsStore struct {
+	client redis.UniversalClient
+}
+
+var _ analytics.Store = &redisAnalyticsStore{}
+
+// AppendToSetPipelined appends values to the list of key.
+func (s *redisAnalyticsStore) AppendToSetPipelined(key string, values [][]byte) {
+	if len(values) == 0 {
+		return
+	}
+
+	pipe := s.client.Pipeline()
+	for _, val := range values {
+		pipe.RPush(analyticsKeyPrefix+key, val)
+	}
+
+	if _, err := pipe.Exec(); err != nil {
+		log.Errorf("Error writing %d analytics records: %s", len(values), err.Error())
+	}
+}
+
+// newRecorder starts a recorder which writes the records to store, opts must be completed.
+func newRecorder(store analytics.Store, opts AnalyticsOptions) *analytics.Recorder {
+	r := analytics.NewRecorder(opts.recorderOptions(), store)
+	r.Start()
+
+	return r
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ory/ladon"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/marmotedu/iam/pkg/analytics"
+)
+
+type fakeAnalyticsStore struct {
+	lock   sync.Mutex
+	values map[string][][]byte
+}
+
+func (s *fakeAnalyticsStore) AppendToSetPipelined(key string, values [][]byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, val := range values {
+		s.values[key] = append(s.values[key], append([]byte(nil), val...))
+	}
+}
+
+func TestAuditLogger(t *testing.T) {
+	opts, err := AnalyticsOptions{PoolSize: 2, FlushInterval: 60000}.complete()
+	if err != nil {
+		t.Fatalf("AnalyticsOptions.complete() error = %v", err)
+	}
+
+	store := &fakeAnalyticsStore{values: map[string][][]byte{}}
+	r := newRecorder(store, opts)
+	logger := &auditLogger{recorder: r}
+
+	policies := ladon.Policies{&ladon.DefaultPolicy{ID: "p1", Effect: ladon.AllowAccess}}
+	for _, username := range []string{"colin", "ken"} {
+		request := &ladon.Request{Context: ladon.Context{"username": username}}
+		logger.LogGrantedAccessRequest(request, policies, policies)
+	}
+	logger.LogRejectedAccessRequest(&ladon.Request{Context: ladon.Context{"username": "tom"}}, nil, nil)
+
+	// the flush interval is not reached, the records are written when the recorder stops.
+	r.Stop()
+
+	got := map[string]string{}
+	for _, val := range store.values[analytics.KeyName] {
+		var record analytics.Record
+		if err := msgpack.Unmarshal(val, &record); err != nil {
+			t.Fatalf("msgpack.Unmarshal() error = %v", err)
+		}
+		if record.ExpireAt.Before(time.Now().Add(23 * time.Hour)) {
+			t.Errorf("record %s expires at %v, want about 24 hours later", record.Username, record.ExpireAt)
+		}
+		if record.Effect == ladon.AllowAccess && record.Deciders == "" {
+			t.Errorf("record %s does not record the deciders", record.Username)
+		}
+		got[record.Username] = record.Effect
+	}
+
+	want := map[string]string{"colin": ladon.AllowAccess, "ken": ladon.AllowAccess, "tom": ladon.DenyAccess}
+	for username, effect := range want {
+		if got[username] != effect {
+			t.Errorf("record of %s has effect %q, want %q", username, got[username], effect)
+		}
+	}
+}
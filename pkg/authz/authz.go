@@ -0,0 +1,245 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	redis "github.com/go-redis/redis/v7"
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/ory/ladon"
+	"google.golang.org/grpc"
+
+	"github.com/marmotedu/iam/pkg/analytics"
+	"github.com/marmotedu/iam/pkg/log"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+// Change notifications published by iam-apiserver, see iam-authz-server.
+const (
+	notificationChannel = "iam.cluster.notifications"
+	noticePolicyChanged = "PolicyChanged"
+	noticeSecretChanged = "SecretChanged"
+)
+
+// ErrMissingUsername is returned when the request context does not contain the username
+// which owns the policies to evaluate.
+var ErrMissingUsername = errors.New("request context does not contain username")
+
+// notification is a message published on notificationChannel.
+type notification struct {
+	Command string `json:"command"`
+}
+
+// Authorizer authorizes requests against a local snapshot of the iam policies.
+type Authorizer struct {
+	snapshot *snapshot
+	warden   ladon.Warden
+	conn     *grpc.ClientConn
+	redis    redis.UniversalClient
+	recorder *analytics.Recorder
+	reload   chan struct{}
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// New connects to iam-apiserver, loads the secrets and policies and returns an authorizer
+// which keeps them up to date until Close is called. ctx bounds the initial connection.
+func New(ctx context.Context, opts *Options) (*Authorizer, error) {
+	if opts.Analytics != nil && opts.Redis == nil {
+		return nil, errors.New("analytics requires redis options")
+	}
+
+	conn, err := dialAPIServer(ctx, opts.Server, opts.ClientCA)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := newAuthorizer(&grpcSource{cli: pb.NewCacheClient(conn)}, opts)
+	if err != nil {
+		_ = conn.Close()
+
+		return nil, err
+	}
+	a.conn = conn
+
+	return a, nil
+}
+
+func newAuthorizer(src source, opts *Options) (*Authorizer, error) {
+	var analyticsOptions AnalyticsOptions
+	if opts.Analytics != nil {
+		var err error
+		if analyticsOptions, err = opts.Analytics.complete(); err != nil {
+			return nil, err
+		}
+	}
+
+	s := newSnapshot(src)
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &Authorizer{
+		snapshot: s,
+		reload:   make(chan struct{}, 1),
+		cancel:   cancel,
+	}
+
+	if opts.Redis != nil {
+		a.redis = storage.NewRedisClusterPool(false, opts.Redis)
+
+		a.wg.Add(1)
+		go a.subscribe(ctx)
+	}
+
+	var logger ladon.AuditLogger = &ladon.AuditLoggerNoOp{}
+	if opts.Analytics != nil {
+		a.recorder = newRecorder(&redisAnalyticsStore{client: a.redis}, analyticsOptions)
+		logger = &auditLogger{recorder: a.recorder}
+	}
+
+	a.warden = &ladon.Ladon{
+		Manager:     &policyManager{snapshot: s},
+		Matcher:     ladon.NewRegexpMatcher(512),
+		AuditLogger: logger,
+	}
+
+	interval := opts.RefreshInterval
+	if interval <= 0 {
+		interval = defaultRefreshInterval
+	}
+
+	a.wg.Add(1)
+	go a.reloadLoop(ctx, interval)
+
+	return a, nil
+}
+
+// IsAllowed returns nil if the request is allowed and an error describing why it is denied otherwise.
+// The policies of the user set by the `username` request context are evaluated.
+func (a *Authorizer) IsAllowed(ctx context.Context, r *ladon.Request) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, ok := r.Context["username"].(string); !ok {
+		return ErrMissingUsername
+	}
+
+	return a.warden.IsAllowed(r)
+}
+
+// Reload reloads the secrets and policies from iam-apiserver immediately.
+func (a *Authorizer) Reload() error {
+	return a.snapshot.Reload()
+}
+
+// Close stops refreshing the snapshot, flushes the pending analytics records and closes
+// the connections to iam-apiserver and redis.
+func (a *Authorizer) Close() {
+	a.cancel()
+	a.wg.Wait()
+
+	if a.recorder != nil {
+		a.recorder.Stop()
+	}
+
+	if a.redis != nil {
+		if err := a.redis.Close(); err != nil {
+			log.Warnf("failed to close redis connection: %s", err.Error())
+		}
+	}
+
+	if a.conn != nil {
+		if err := a.conn.Close(); err != nil {
+			log.Warnf("failed to close iam-apiserver connection: %s", err.Error())
+		}
+	}
+}
+
+func (a *Authorizer) reloadLoop(ctx context.Context, interval time.Duration) {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-a.reload:
+		}
+
+		if err := a.snapshot.Reload(); err != nil {
+			log.Errorf("failed to reload secrets and policies: %s", err.Error())
+		}
+	}
+}
+
+// subscribe queues a reload on the change notifications until ctx is done.
+func (a *Authorizer) subscribe(ctx context.Context) {
+	defer a.wg.Done()
+
+	for ctx.Err() == nil {
+		pubsub := a.redis.Subscribe(notificationChannel)
+		if _, err := pubsub.Receive(); err != nil {
+			log.Errorf("Connection to Redis failed, reconnect in 10s: %s", err.Error())
+			_ = pubsub.Close()
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(10 * time.Second):
+			}
+
+			continue
+		}
+
+		a.receive(ctx, pubsub.Channel())
+		_ = pubsub.Close()
+	}
+}
+
+func (a *Authorizer) receive(ctx context.Context, messages <-chan *redis.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			a.handleNotification(message)
+		}
+	}
+}
+
+// handleNotification queues a reload on secret and policy changes. Reloads which are
+// requested while another one is queued are merged.
+func (a *Authorizer) handleNotification(message *redis.Message) {
+	var notif notification
+	if err := json.Unmarshal([]byte(message.Payload), &notif); err != nil {
+		log.Errorf("Unmarshalling message body failed, malformed: %s", err.Error())
+
+		return
+	}
+
+	switch notif.Command {
+	case noticePolicyChanged, noticeSecretChanged:
+		select {
+		case a.reload <- struct{}{}:
+		default:
+		}
+	default:
+		log.Warnf("Unknown notification command: %q", notif.Command)
+	}
+}
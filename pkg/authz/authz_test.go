@@ -0,0 +1,192 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/pkg/grant"
+)
+
+type fakeSource struct {
+	secrets  map[string]*pb.SecretInfo
+	policies map[string][]*ladon.DefaultPolicy
+}
+
+func (s *fakeSource) ListSecrets() (map[string]*pb.SecretInfo, error) {
+	return s.secrets, nil
+}
+
+func (s *fakeSource) ListPolicies() (map[string][]*ladon.DefaultPolicy, error) {
+	return s.policies, nil
+}
+
+func newTestAuthorizer(t *testing.T) *Authorizer {
+	t.Helper()
+
+	expired := &ladon.DefaultPolicy{
+		ID:        "expired-grant",
+		Subjects:  []string{"users:colin"},
+		Resources: []string{"resources:articles:<.*>"},
+		Actions:   []string{"create"},
+		Effect:    ladon.AllowAccess,
+	}
+	now := time.Now()
+	if err := (&grant.Grant{NotBefore: now.Add(-2 * time.Hour), NotAfter: now.Add(-time.Hour)}).ApplyTo(expired); err != nil {
+		t.Fatalf("Grant.ApplyTo() error = %v", err)
+	}
+
+	src := &fakeSource{
+		secrets: map[string]*pb.SecretInfo{
+			"secret-id":      {Username: "colin", SecretId: "secret-id", SecretKey: "secret-key"},
+			"expired-secret": {Username: "colin", SecretId: "expired-secret", SecretKey: "secret-key", Expires: 1},
+		},
+		policies: map[string][]*ladon.DefaultPolicy{
+			"colin": {
+				{
+					ID:        "allow-articles",
+					Subjects:  []string{"users:<colin|ken>"},
+					Resources: []string{"resources:articles:<.*>"},
+					Actions:   []string{"<delete|update>"},
+					Effect:    ladon.AllowAccess,
+				},
+				{
+					ID:        "deny-ken-delete",
+					Subjects:  []string{"users:ken"},
+					Resources: []string{"resources:articles:<.*>"},
+					Actions:   []string{"delete"},
+					Effect:    ladon.DenyAccess,
+				},
+				expired,
+			},
+		},
+	}
+
+	a, err := newAuthorizer(src, &Options{})
+	if err != nil {
+		t.Fatalf("newAuthorizer() error = %v", err)
+	}
+	t.Cleanup(a.Close)
+
+	return a
+}
+
+func TestAuthorizer_IsAllowed(t *testing.T) {
+	a := newTestAuthorizer(t)
+
+	tests := []struct {
+		name    string
+		request *ladon.Request
+		wantErr error
+	}{
+		{
+			name: "allowed",
+			request: &ladon.Request{
+				Subject:  "users:colin",
+				Action:   "delete",
+				Resource: "resources:articles:ladon",
+				Context:  ladon.Context{"username": "colin"},
+			},
+		},
+		{
+			name: "forcefully denied",
+			request: &ladon.Request{
+				Subject:  "users:ken",
+				Action:   "delete",
+				Resource: "resources:articles:ladon",
+				Context:  ladon.Context{"username": "colin"},
+			},
+			wantErr: ladon.ErrRequestForcefullyDenied,
+		},
+		{
+			name: "denied by default",
+			request: &ladon.Request{
+				Subject:  "users:colin",
+				Action:   "read",
+				Resource: "resources:articles:ladon",
+				Context:  ladon.Context{"username": "colin"},
+			},
+			wantErr: ladon.ErrRequestDenied,
+		},
+		{
+			name: "expired grant",
+			request: &ladon.Request{
+				Subject:  "users:colin",
+				Action:   "create",
+				Resource: "resources:articles:ladon",
+				Context:  ladon.Context{"username": "colin"},
+			},
+			wantErr: ladon.ErrRequestDenied,
+		},
+		{
+			name: "missing username",
+			request: &ladon.Request{
+				Subject:  "users:colin",
+				Action:   "delete",
+				Resource: "resources:articles:ladon",
+			},
+			wantErr: ErrMissingUsername,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.IsAllowed(context.Background(), tt.request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Authorizer.IsAllowed() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func newTestToken(t *testing.T, kid, key string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString([]byte(key))
+	if err != nil {
+		t.Fatalf("token.SignedString() error = %v", err)
+	}
+
+	return signed
+}
+
+func TestAuthorizer_VerifyToken(t *testing.T) {
+	a := newTestAuthorizer(t)
+
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid", token: newTestToken(t, "secret-id", "secret-key"), want: "colin"},
+		{name: "wrong key", token: newTestToken(t, "secret-id", "other-key"), wantErr: true},
+		{name: "unknown secret", token: newTestToken(t, "unknown", "secret-key"), wantErr: true},
+		{name: "expired secret", token: newTestToken(t, "expired-secret", "secret-key"), wantErr: true},
+		{name: "malformed", token: "malformed", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.VerifyToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authorizer.VerifyToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Authorizer.VerifyToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
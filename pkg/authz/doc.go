@@ -0,0 +1,30 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package authz is an embeddable authorization library. It keeps a local snapshot of the
+// secrets and policies served by the iam-apiserver grpc cache service, refreshes the snapshot
+// when a change is published on the iam.cluster.notifications redis channel, and evaluates
+// requests with the same ladon warden used by iam-authz-server, so that services can
+// authorize requests in process instead of calling iam-authz-server.
+//
+//	authorizer, err := authz.New(ctx, &authz.Options{
+//		Server:   "127.0.0.1:8081",
+//		ClientCA: "/etc/iam/cert/ca.pem",
+//		Redis:    &storage.Config{Host: "127.0.0.1", Port: 6379},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer authorizer.Close()
+//
+//	err = authorizer.IsAllowed(ctx, &ladon.Request{
+//		Subject:  "users:colin",
+//		Action:   "delete",
+//		Resource: "resources:articles:ladon",
+//		Context:  ladon.Context{"username": "colin"},
+//	})
+//
+// If analytics is enabled, every decision is forwarded to redis in the format consumed
+// by iam-pump.
+package authz // import "github.com/marmotedu/iam/pkg/authz"
@@ -0,0 +1,110 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/marmotedu/iam/pkg/analytics"
+	"github.com/marmotedu/iam/pkg/storage"
+)
+
+const defaultRefreshInterval = 5 * time.Minute
+
+// Overflow policies of the analytics records buffer.
+const (
+	// OverflowBlock blocks the authorization until the record is buffered.
+	OverflowBlock = analytics.OverflowBlock
+	// OverflowDropNewest drops the record which does not fit in the buffer.
+	OverflowDropNewest = analytics.OverflowDropNewest
+	// OverflowDropOldest drops the oldest buffered record to make room for the new one.
+	OverflowDropOldest = analytics.OverflowDropOldest
+)
+
+// Defaults of AnalyticsOptions, the same as the defaults of iam-authz-server.
+const (
+	defaultPoolSize              = 50
+	defaultRecordsBufferSize     = 1000
+	defaultFlushInterval         = 200
+	defaultStorageExpirationTime = 24 * time.Hour
+)
+
+// Options defines options for the authorization library.
+type Options struct {
+	// Server is the address of the iam-apiserver grpc service.
+	Server string
+	// ClientCA is the CA file used to verify the iam-apiserver grpc certificate.
+	ClientCA string
+	// Redis is used to receive change notifications and to forward analytics records.
+	// If it is nil, the snapshot is only refreshed every RefreshInterval.
+	Redis *storage.Config
+	// RefreshInterval is the interval to refresh the snapshot regardless of notifications.
+	// Defaults to 5 minutes.
+	RefreshInterval time.Duration
+	// Analytics enables forwarding authorization decisions to redis if it is not nil.
+	// Requires Redis.
+	Analytics *AnalyticsOptions
+}
+
+// AnalyticsOptions defines options for forwarding authorization decisions to iam-pump.
+// Zero values are replaced by the defaults of iam-authz-server.
+type AnalyticsOptions struct {
+	// PoolSize is the number of workers writing records to redis. Defaults to 50.
+	PoolSize int
+	// RecordsBufferSize is the number of records buffered in memory. Defaults to 1000.
+	RecordsBufferSize uint64
+	// FlushInterval is the interval in milliseconds to write the buffered records. Defaults to 200.
+	FlushInterval uint64
+	// StorageExpirationTime is how long iam-pump keeps the records. Defaults to 24 hours.
+	StorageExpirationTime time.Duration
+	// OverflowPolicy is one of "block", "drop-newest" and "drop-oldest". Defaults to "block".
+	OverflowPolicy string
+}
+
+// complete returns a copy of the options with the defaults applied.
+func (o AnalyticsOptions) complete() (AnalyticsOptions, error) {
+	if o.PoolSize <= 0 {
+		o.PoolSize = defaultPoolSize
+	}
+	if o.RecordsBufferSize == 0 {
+		o.RecordsBufferSize = defaultRecordsBufferSize
+	}
+	// every worker buffers at least one record.
+	if o.RecordsBufferSize < uint64(o.PoolSize) {
+		o.RecordsBufferSize = uint64(o.PoolSize)
+	}
+	if o.FlushInterval == 0 {
+		o.FlushInterval = defaultFlushInterval
+	}
+	if o.StorageExpirationTime <= 0 {
+		o.StorageExpirationTime = defaultStorageExpirationTime
+	}
+
+	switch o.OverflowPolicy {
+	case "":
+		o.OverflowPolicy = OverflowBlock
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+	default:
+		return o, fmt.Errorf("invalid analytics overflow policy %q, must be one of %s, %s and %s",
+			o.OverflowPolicy, OverflowBlock, OverflowDropNewest, OverflowDropOldest)
+	}
+
+	return o, nil
+}
+
+// recorderOptions returns the options of the recorder, the options must be completed.
+// The decisions are recorded in detail and are not sampled.
+func (o AnalyticsOptions) recorderOptions() *analytics.Options {
+	return &analytics.Options{
+		PoolSize:                o.PoolSize,
+		RecordsBufferSize:       o.RecordsBufferSize,
+		FlushInterval:           o.FlushInterval,
+		StorageExpirationTime:   o.StorageExpirationTime,
+		EnableDetailedRecording: true,
+		OverflowPolicy:          o.OverflowPolicy,
+		SampleRate:              1,
+	}
+}
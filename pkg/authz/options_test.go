@@ -0,0 +1,58 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnalyticsOptions_complete(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    AnalyticsOptions
+		want    AnalyticsOptions
+		wantErr bool
+	}{
+		{
+			name: "zero value",
+			want: AnalyticsOptions{
+				PoolSize:              50,
+				RecordsBufferSize:     1000,
+				FlushInterval:         200,
+				StorageExpirationTime: 24 * time.Hour,
+				OverflowPolicy:        OverflowBlock,
+			},
+		},
+		{
+			name: "buffer smaller than pool",
+			opts: AnalyticsOptions{PoolSize: 10, RecordsBufferSize: 5, OverflowPolicy: OverflowDropOldest},
+			want: AnalyticsOptions{
+				PoolSize:              10,
+				RecordsBufferSize:     10,
+				FlushInterval:         200,
+				StorageExpirationTime: 24 * time.Hour,
+				OverflowPolicy:        OverflowDropOldest,
+			},
+		},
+		{
+			name:    "invalid overflow policy",
+			opts:    AnalyticsOptions{OverflowPolicy: "drop-all"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.complete()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AnalyticsOptions.complete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AnalyticsOptions.complete() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
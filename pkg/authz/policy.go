@@ -0,0 +1,137 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/ory/ladon"
+
+	"github.com/marmotedu/iam/pkg/analytics"
+	"github.com/marmotedu/iam/pkg/grant"
+)
+
+// policyManager is a read only ladon manager which finds the candidate policies in the snapshot.
+type policyManager struct {
+	snapshot *snapshot
+}
+
+var _ ladon.Manager = &policyManager{}
+
+// Create persists the policy.
+func (*policyManager) Create(policy ladon.Policy) error {
+	return nil
+}
+
+// Update updates an existing policy.
+func (*policyManager) Update(policy ladon.Policy) error {
+	return nil
+}
+
+// Get retrieves a policy.
+func (*policyManager) Get(id string) (ladon.Policy, error) {
+	return nil, nil
+}
+
+// Delete removes a policy.
+func (*policyManager) Delete(id string) error {
+	return nil
+}
+
+// GetAll retrieves all policies.
+func (*policyManager) GetAll(limit, offset int64) (ladon.Policies, error) {
+	return nil, nil
+}
+
+// FindRequestCandidates returns the policies of the user set by the `username` request context.
+// Time-bound grants are only returned within their validity window.
+func (m *policyManager) FindRequestCandidates(r *ladon.Request) (ladon.Policies, error) {
+	username, _ := r.Context["username"].(string)
+
+	policies, err := m.snapshot.GetPolicy(username)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ret := make(ladon.Policies, 0, len(policies))
+	for _, policy := range policies {
+		if grant.IsActive(policy, now) {
+			ret = append(ret, policy)
+		}
+	}
+
+	return ret, nil
+}
+
+// FindPoliciesForSubject returns policies that could match the subject.
+func (*policyManager) FindPoliciesForSubject(subject string) (ladon.Policies, error) {
+	return nil, nil
+}
+
+// FindPoliciesForResource returns policies that could match the resource.
+func (*policyManager) FindPoliciesForResource(resource string) (ladon.Policies, error) {
+	return nil, nil
+}
+
+// auditLogger forwards the authorization decisions to the recorder in the format of iam-authz-server.
+type auditLogger struct {
+	recorder *analytics.Recorder
+}
+
+var _ ladon.AuditLogger = &auditLogger{}
+
+// LogRejectedAccessRequest records a denied request.
+func (l *auditLogger) LogRejectedAccessRequest(r *ladon.Request, p ladon.Policies, d ladon.Policies) {
+	var conclusion string
+	switch {
+	case len(d) > 1:
+		allowed := joinPoliciesNames(d[0 : len(d)-1])
+		denied := d[len(d)-1].GetID()
+		conclusion = fmt.Sprintf("policies %s allow access, but policy %s forcefully denied it", allowed, denied)
+	case len(d) == 1:
+		conclusion = fmt.Sprintf("policy %s forcefully denied the access", d[0].GetID())
+	default:
+		conclusion = "no policy allowed access"
+	}
+
+	l.recorder.Record(newAnalyticsRecord(r, p, d, ladon.DenyAccess, conclusion))
+}
+
+// LogGrantedAccessRequest records an allowed request.
+func (l *auditLogger) LogGrantedAccessRequest(r *ladon.Request, p ladon.Policies, d ladon.Policies) {
+	conclusion := fmt.Sprintf("policies %s allow access", joinPoliciesNames(d))
+
+	l.recorder.Record(newAnalyticsRecord(r, p, d, ladon.AllowAccess, conclusion))
+}
+
+func newAnalyticsRecord(r *ladon.Request, p, d ladon.Policies, effect, conclusion string) *analytics.Record {
+	rbytes, _ := json.Marshal(r)
+	pbytes, _ := json.Marshal(p)
+	dbytes, _ := json.Marshal(d)
+	username, _ := r.Context["username"].(string)
+
+	return &analytics.Record{
+		TimeStamp:  time.Now().Unix(),
+		Username:   username,
+		Effect:     effect,
+		Conclusion: conclusion,
+		Request:    string(rbytes),
+		Policies:   string(pbytes),
+		Deciders:   string(dbytes),
+	}
+}
+
+func joinPoliciesNames(policies ladon.Policies) string {
+	names := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		names = append(names, policy.GetID())
+	}
+
+	return strings.Join(names, ", ")
+}
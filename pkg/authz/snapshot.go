@@ -0,0 +1,76 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	"sync"
+
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+)
+
+// ErrSecretNotFound is returned when a secret is not in the local snapshot.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// snapshot is the local copy of the secrets and policies served by iam-apiserver.
+type snapshot struct {
+	src source
+
+	lock     sync.RWMutex
+	secrets  map[string]*pb.SecretInfo
+	policies map[string][]*ladon.DefaultPolicy
+}
+
+func newSnapshot(src source) *snapshot {
+	return &snapshot{
+		src:      src,
+		secrets:  map[string]*pb.SecretInfo{},
+		policies: map[string][]*ladon.DefaultPolicy{},
+	}
+}
+
+// GetSecret return secret detail for the given secret id.
+func (s *snapshot) GetSecret(key string) (*pb.SecretInfo, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	secret, ok := s.secrets[key]
+	if !ok {
+		return nil, ErrSecretNotFound
+	}
+
+	return secret, nil
+}
+
+// GetPolicy return user's ladon policies for the given user.
+func (s *snapshot) GetPolicy(key string) ([]*ladon.DefaultPolicy, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.policies[key], nil
+}
+
+// Reload fetches all the secrets and policies from iam-apiserver. The snapshot is only
+// replaced if both of them are fetched successfully.
+func (s *snapshot) Reload() error {
+	secrets, err := s.src.ListSecrets()
+	if err != nil {
+		return err
+	}
+
+	policies, err := s.src.ListPolicies()
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.secrets = secrets
+	s.policies = policies
+
+	return nil
+}
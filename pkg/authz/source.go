@@ -0,0 +1,110 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	"context"
+
+	"github.com/AlekSi/pointer"
+	"github.com/avast/retry-go"
+	pb "github.com/marmotedu/api/proto/apiserver/v1"
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/marmotedu/errors"
+	"github.com/ory/ladon"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+// source provides the secrets and policies of all the users.
+type source interface {
+	ListSecrets() (map[string]*pb.SecretInfo, error)
+	ListPolicies() (map[string][]*ladon.DefaultPolicy, error)
+}
+
+// grpcSource lists the secrets and policies from the iam-apiserver grpc cache service.
+type grpcSource struct {
+	cli pb.CacheClient
+}
+
+// dialAPIServer connects to the iam-apiserver grpc service, ctx bounds the connection.
+func dialAPIServer(ctx context.Context, address string, clientCA string) (*grpc.ClientConn, error) {
+	creds, err := credentials.NewClientTLSFromFile(clientCA, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "load client ca failed")
+	}
+
+	conn, err := grpc.DialContext(ctx, address,
+		grpc.WithBlock(),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect to grpc server %s failed", address)
+	}
+
+	return conn, nil
+}
+
+// ListSecrets returns all the secrets indexed by secret id.
+func (s *grpcSource) ListSecrets() (map[string]*pb.SecretInfo, error) {
+	req := &pb.ListSecretsRequest{
+		Offset: pointer.ToInt64(0),
+		Limit:  pointer.ToInt64(-1),
+	}
+
+	var resp *pb.ListSecretsResponse
+	err := retry.Do(func() error {
+		var err error
+		resp, err = s.cli.ListSecrets(context.Background(), req)
+
+		return err
+	}, retry.Attempts(3))
+	if err != nil {
+		return nil, errors.Wrap(err, "list secrets failed")
+	}
+
+	secrets := make(map[string]*pb.SecretInfo, len(resp.Items))
+	for _, v := range resp.Items {
+		secrets[v.SecretId] = v
+	}
+
+	return secrets, nil
+}
+
+// ListPolicies returns all the policies indexed by the username which owns them.
+func (s *grpcSource) ListPolicies() (map[string][]*ladon.DefaultPolicy, error) {
+	req := &pb.ListPoliciesRequest{
+		Offset: pointer.ToInt64(0),
+		Limit:  pointer.ToInt64(-1),
+	}
+
+	var resp *pb.ListPoliciesResponse
+	err := retry.Do(func() error {
+		var err error
+		resp, err = s.cli.ListPolicies(context.Background(), req)
+
+		return err
+	}, retry.Attempts(3))
+	if err != nil {
+		return nil, errors.Wrap(err, "list policies failed")
+	}
+
+	policies := make(map[string][]*ladon.DefaultPolicy)
+	for _, v := range resp.Items {
+		var policy ladon.DefaultPolicy
+		if err := json.Unmarshal([]byte(v.PolicyShadow), &policy); err != nil {
+			log.Warnf("failed to load policy %s of %s, error: %s", v.Name, v.Username, err.Error())
+
+			continue
+		}
+
+		policies[v.Username] = append(policies[v.Username], &policy)
+	}
+
+	return policies, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package authz
+
+import (
+	"fmt"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/marmotedu/errors"
+)
+
+// Errors returned by VerifyToken.
+var (
+	ErrMissingKID   = errors.New("invalid token format: missing kid field in header")
+	ErrSecretExpire = errors.New("secret is expired")
+)
+
+// VerifyToken verifies the signature and expiration of a jwt token signed by an iam secret
+// and returns the username which owns the secret. The token is verified the same way as
+// iam-authz-server does.
+func (a *Authorizer) VerifyToken(token string) (string, error) {
+	var username string
+	var expires int64
+
+	parsed, err := jwt.ParseWithClaims(token, &jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+
+		kid, ok := t.Header["kid"].(string)
+		if !ok {
+			return nil, ErrMissingKID
+		}
+
+		secret, err := a.snapshot.GetSecret(kid)
+		if err != nil {
+			return nil, err
+		}
+		username, expires = secret.Username, secret.Expires
+
+		return []byte(secret.SecretKey), nil
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "invalid token")
+	}
+	if !parsed.Valid {
+		return "", errors.New("invalid token")
+	}
+
+	if expires >= 1 && time.Now().After(time.Unix(expires, 0)) {
+		return "", ErrSecretExpire
+	}
+
+	return username, nil
+}
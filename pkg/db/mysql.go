@@ -43,6 +43,11 @@ func New(opts *Options) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// create spans for sql statements executed in traced requests
+	if err = db.Use(&TracePlugin{}); err != nil {
+		return nil, err
+	}
+
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
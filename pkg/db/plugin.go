@@ -5,8 +5,14 @@
 package db
 
 import (
+	"errors"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 
 	"github.com/marmotedu/iam/pkg/log"
@@ -16,9 +22,13 @@ const (
 	callBackBeforeName = "core:before"
 	callBackAfterName  = "core:after"
 	startTime          = "_start_time"
+	spanKey            = "_span"
+
+	instrumentationName = "github.com/marmotedu/iam/pkg/db"
 )
 
 // TracePlugin defines gorm plugin used to trace sql.
+// A span is created for every sql statement executed with a context carrying a span.
 type TracePlugin struct{}
 
 // Name returns the name of trace plugin.
@@ -29,12 +39,12 @@ func (op *TracePlugin) Name() string {
 // Initialize initialize the trace plugin.
 func (op *TracePlugin) Initialize(db *gorm.DB) (err error) {
 	// 开始前
-	_ = db.Callback().Create().Before("gorm:before_create").Register(callBackBeforeName, before)
-	_ = db.Callback().Query().Before("gorm:query").Register(callBackBeforeName, before)
-	_ = db.Callback().Delete().Before("gorm:before_delete").Register(callBackBeforeName, before)
-	_ = db.Callback().Update().Before("gorm:setup_reflect_value").Register(callBackBeforeName, before)
-	_ = db.Callback().Row().Before("gorm:row").Register(callBackBeforeName, before)
-	_ = db.Callback().Raw().Before("gorm:raw").Register(callBackBeforeName, before)
+	_ = db.Callback().Create().Before("gorm:before_create").Register(callBackBeforeName, before("create"))
+	_ = db.Callback().Query().Before("gorm:query").Register(callBackBeforeName, before("query"))
+	_ = db.Callback().Delete().Before("gorm:before_delete").Register(callBackBeforeName, before("delete"))
+	_ = db.Callback().Update().Before("gorm:setup_reflect_value").Register(callBackBeforeName, before("update"))
+	_ = db.Callback().Row().Before("gorm:row").Register(callBackBeforeName, before("row"))
+	_ = db.Callback().Raw().Before("gorm:raw").Register(callBackBeforeName, before("raw"))
 
 	// 结束后
 	_ = db.Callback().Create().After("gorm:after_create").Register(callBackAfterName, after)
@@ -49,11 +59,50 @@ func (op *TracePlugin) Initialize(db *gorm.DB) (err error) {
 
 var _ gorm.Plugin = &TracePlugin{}
 
-func before(db *gorm.DB) {
-	db.InstanceSet(startTime, time.Now())
+func before(operation string) func(db *gorm.DB) {
+	tracer := otel.Tracer(instrumentationName)
+
+	return func(db *gorm.DB) {
+		db.InstanceSet(startTime, time.Now())
+
+		ctx := db.Statement.Context
+		if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+
+		_, span := tracer.Start(ctx, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(dbSystem(db), semconv.DBOperationKey.String(operation)),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+// dbSystem returns the db.system attribute of the dialector, like mysql or sqlite.
+func dbSystem(db *gorm.DB) attribute.KeyValue {
+	if db.Dialector == nil {
+		return semconv.DBSystemOtherSQL
+	}
+
+	return semconv.DBSystemKey.String(db.Dialector.Name())
 }
 
 func after(db *gorm.DB) {
+	if v, ok := db.InstanceGet(spanKey); ok {
+		if span, ok := v.(trace.Span); ok {
+			span.SetAttributes(
+				semconv.DBStatementKey.String(db.Statement.SQL.String()),
+				semconv.DBSQLTableKey.String(db.Statement.Table),
+				attribute.Int64("db.rows_affected", db.RowsAffected),
+			)
+			if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+				span.RecordError(db.Error)
+				span.SetStatus(codes.Error, db.Error.Error())
+			}
+			span.End()
+		}
+	}
+
 	_ts, isExist := db.InstanceGet(startTime)
 	if !isExist {
 		return
@@ -64,5 +113,5 @@ func after(db *gorm.DB) {
 		return
 	}
 	// sql := db.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...)
-	log.Infof("sql cost time: %fs", time.Since(ts).Seconds())
+	log.Debugf("sql cost time: %fs", time.Since(ts).Seconds())
 }
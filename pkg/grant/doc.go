@@ -0,0 +1,6 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package grant defines time-bound access grants which are stored as ladon policies.
+package grant // import "github.com/marmotedu/iam/pkg/grant"
@@ -0,0 +1,71 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package grant
+
+import (
+	"time"
+
+	"github.com/marmotedu/component-base/pkg/json"
+	"github.com/ory/ladon"
+)
+
+// Grant records the validity window, approver and justification of a time-bound policy.
+// It is stored in the `grant` field of the ladon policy meta, so it is delivered
+// to iam-authz-server together with the policy itself.
+type Grant struct {
+	NotBefore     time.Time `json:"notBefore"`
+	NotAfter      time.Time `json:"notAfter"`
+	Approver      string    `json:"approver"`
+	Justification string    `json:"justification"`
+}
+
+type meta struct {
+	Grant *Grant `json:"grant,omitempty"`
+}
+
+// FromPolicy returns the grant attached to the policy, or nil if the policy is not a grant.
+func FromPolicy(policy ladon.Policy) *Grant {
+	data := policy.GetMeta()
+	if len(data) == 0 {
+		return nil
+	}
+
+	var m meta
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+
+	return m.Grant
+}
+
+// ApplyTo attaches the grant to the policy meta.
+func (g *Grant) ApplyTo(policy *ladon.DefaultPolicy) error {
+	data, err := json.Marshal(meta{Grant: g})
+	if err != nil {
+		return err
+	}
+
+	policy.Meta = data
+
+	return nil
+}
+
+// Active returns true if the grant is valid at the given time.
+func (g *Grant) Active(t time.Time) bool {
+	return !t.Before(g.NotBefore) && t.Before(g.NotAfter)
+}
+
+// Expired returns true if the grant is no longer valid at the given time.
+func (g *Grant) Expired(t time.Time) bool {
+	return !t.Before(g.NotAfter)
+}
+
+// IsActive returns false only if the policy is a grant which is not valid at the given time.
+// Ordinary policies are always active.
+func IsActive(policy ladon.Policy, t time.Time) bool {
+	g := FromPolicy(policy)
+
+	return g == nil || g.Active(t)
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package grant
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ory/ladon"
+)
+
+func TestIsActive(t *testing.T) {
+	now := time.Now()
+
+	newPolicy := func(g *Grant) *ladon.DefaultPolicy {
+		policy := &ladon.DefaultPolicy{ID: "grant"}
+		if g != nil {
+			if err := g.ApplyTo(policy); err != nil {
+				t.Fatalf("Grant.ApplyTo() error = %v", err)
+			}
+		}
+
+		return policy
+	}
+
+	tests := []struct {
+		name   string
+		policy ladon.Policy
+		want   bool
+	}{
+		{
+			name:   "ordinary policy",
+			policy: newPolicy(nil),
+			want:   true,
+		},
+		{
+			name:   "within window",
+			policy: newPolicy(&Grant{NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)}),
+			want:   true,
+		},
+		{
+			name:   "not started",
+			policy: newPolicy(&Grant{NotBefore: now.Add(time.Minute), NotAfter: now.Add(time.Hour)}),
+			want:   false,
+		},
+		{
+			name:   "expired",
+			policy: newPolicy(&Grant{NotBefore: now.Add(-time.Hour), NotAfter: now}),
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsActive(tt.policy, now); got != tt.want {
+				t.Errorf("IsActive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
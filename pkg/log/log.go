@@ -25,6 +25,7 @@ import (
 	"log"
 	"sync"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
@@ -561,6 +562,12 @@ func (l *zapLogger) L(ctx context.Context) *zapLogger {
 	if watcherName := ctx.Value(KeyWatcherName); watcherName != nil {
 		lg.zapLogger = lg.zapLogger.With(zap.Any(KeyWatcherName, watcherName))
 	}
+	if traceID := ctx.Value(KeyTraceID); traceID != nil {
+		lg.zapLogger = lg.zapLogger.With(zap.Any(KeyTraceID, traceID), zap.Any(KeySpanID, ctx.Value(KeySpanID)))
+	} else if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		lg.zapLogger = lg.zapLogger.With(zap.String(KeyTraceID, sc.TraceID().String()),
+			zap.String(KeySpanID, sc.SpanID().String()))
+	}
 
 	return lg
 }
@@ -28,6 +28,8 @@ const (
 	KeyRequestID   string = "requestID"
 	KeyUsername    string = "username"
 	KeyWatcherName string = "watcher"
+	KeyTraceID     string = "traceID"
+	KeySpanID      string = "spanID"
 )
 
 // Field is an alias for the field structure in the underlying log frame.
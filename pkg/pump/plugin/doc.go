@@ -0,0 +1,12 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package plugin implements the protocol of the iam-pump plugins, which write the analytics
+// records to back-ends out of the iam-pump process.
+//
+// A plugin is a gRPC server implementing the iam.pump.plugin.v1.Pump service defined in
+// plugin.proto, and the standard grpc.health.v1.Health service. iam-pump either launches the
+// plugin executable, passing the address to listen on in the IAM_PUMP_PLUGIN_ADDRESS
+// environment variable, or connects to a running plugin. Go plugins implement Pump and call Serve.
+package plugin // import "github.com/marmotedu/iam/pkg/pump/plugin"
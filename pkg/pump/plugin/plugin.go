@@ -0,0 +1,167 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"time"
+
+	"github.com/marmotedu/component-base/pkg/json"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ServiceName is the full name of the pump plugin service.
+const ServiceName = "iam.pump.plugin.v1.Pump"
+
+// maxWriteDataSize is the maximum size of the records sent in one WriteData call. It is below
+// the 4 MiB default message size limit of grpc servers.
+const maxWriteDataSize = 4<<20 - 64<<10
+
+// AddressEnv is the environment variable holding the address a launched plugin listens on,
+// either unix:///path/to/socket or host:port.
+const AddressEnv = "IAM_PUMP_PLUGIN_ADDRESS"
+
+// Record is an analytics record, the authorization decision of a request.
+type Record struct {
+	TimeStamp  int64     `json:"timestamp"`
+	Username   string    `json:"username"`
+	Effect     string    `json:"effect"`
+	Conclusion string    `json:"conclusion"`
+	Request    string    `json:"request"`
+	Policies   string    `json:"policies"`
+	Deciders   string    `json:"deciders"`
+	ExpireAt   time.Time `json:"expireAt"`
+}
+
+// Pump is implemented by the plugins.
+type Pump interface {
+	// Init configures the pump, it is called every time the plugin is (re)started.
+	Init(ctx context.Context, config map[string]interface{}) error
+	// WriteData writes the records, the records of a failed call are sent again.
+	WriteData(ctx context.Context, records []Record) error
+}
+
+// Client calls a pump plugin.
+type Client struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewClient returns a client of the pump plugin served on cc.
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc: cc}
+}
+
+// Init calls the Init method of the plugin.
+func (c *Client) Init(ctx context.Context, config map[string]interface{}) error {
+	in, err := structpb.NewStruct(config)
+	if err != nil {
+		return err
+	}
+
+	return c.cc.Invoke(ctx, "/"+ServiceName+"/Init", in, &emptypb.Empty{})
+}
+
+// WriteData calls the WriteData method of the plugin. The records are encoded with their json fields.
+// Records exceeding maxWriteDataSize are split into several calls, the calls made before a failed
+// one are not rolled back.
+func (c *Client) WriteData(ctx context.Context, records []interface{}) error {
+	in, err := encodeRecords(records)
+	if err != nil {
+		return err
+	}
+
+	if len(records) > 1 && proto.Size(in) > maxWriteDataSize {
+		half := len(records) / 2
+		if err := c.WriteData(ctx, records[:half]); err != nil {
+			return err
+		}
+
+		return c.WriteData(ctx, records[half:])
+	}
+
+	return c.cc.Invoke(ctx, "/"+ServiceName+"/WriteData", in, &emptypb.Empty{})
+}
+
+func encodeRecords(records []interface{}) (*structpb.ListValue, error) {
+	encoded, err := json.Marshal(records)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []interface{}
+	if err := json.Unmarshal(encoded, &values); err != nil {
+		return nil, err
+	}
+
+	return structpb.NewList(values)
+}
+
+func decodeRecords(in *structpb.ListValue) ([]Record, error) {
+	encoded, err := json.Marshal(in.AsSlice())
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	if err := json.Unmarshal(encoded, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// serviceDesc is the grpc.ServiceDesc of the pump plugin service.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*Pump)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Init", Handler: initHandler},
+		{MethodName: "WriteData", Handler: writeDataHandler},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "plugin.proto",
+}
+
+func initHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &emptypb.Empty{}, srv.(Pump).Init(ctx, req.(*structpb.Struct).AsMap())
+	}
+	if interceptor == nil {
+		return handler(ctx, in)
+	}
+
+	return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/Init"}, handler)
+}
+
+func writeDataHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(structpb.ListValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		records, err := decodeRecords(req.(*structpb.ListValue))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid records: %s", err.Error())
+		}
+
+		return &emptypb.Empty{}, srv.(Pump).WriteData(ctx, records)
+	}
+	if interceptor == nil {
+		return handler(ctx, in)
+	}
+
+	return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/WriteData"}, handler)
+}
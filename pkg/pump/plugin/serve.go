@@ -0,0 +1,69 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Serve serves the pump on the address of the IAM_PUMP_PLUGIN_ADDRESS environment variable,
+// until the process receives SIGINT or SIGTERM.
+func Serve(pump Pump) error {
+	address := os.Getenv(AddressEnv)
+	if address == "" {
+		return fmt.Errorf("%s is not set, the plugin should be launched by iam-pump", AddressEnv)
+	}
+
+	lis, err := Listen(address)
+	if err != nil {
+		return err
+	}
+
+	server := NewServer(pump)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		server.GracefulStop()
+	}()
+
+	return server.Serve(lis)
+}
+
+// Listen listens on address, either unix:///path/to/socket or host:port. A socket left by a
+// previous plugin process is removed.
+func Listen(address string) (net.Listener, error) {
+	if path := strings.TrimPrefix(address, "unix://"); path != address {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		return net.Listen("unix", path)
+	}
+
+	return net.Listen("tcp", address)
+}
+
+// NewServer returns a grpc server serving the pump and the health service.
+func NewServer(pump Pump) *grpc.Server {
+	server := grpc.NewServer()
+	server.RegisterService(&serviceDesc, pump)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	return server
+}
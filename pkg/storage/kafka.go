@@ -0,0 +1,213 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/errors"
+	"github.com/segmentio/kafka-go"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+const (
+	kafkaWriteTimeout = 5 * time.Second
+	// kafkaFetchWait is how long GetAndDeleteSet waits for new messages.
+	kafkaFetchWait = time.Second
+	// kafkaMaxBatch is the maximum number of messages returned by GetAndDeleteSet.
+	kafkaMaxBatch = 10000
+)
+
+// KafkaConfig defines options for the kafka analytics handler.
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+	// GroupID is the consumer group used to read the topic, only required by readers.
+	GroupID string
+}
+
+// kafkaReader is the part of kafka.Reader used by Kafka.
+type kafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Kafka is an AnalyticsHandler which produces values to a kafka topic instead of redis.
+// The key of AppendToSetPipelined and GetAndDeleteSet is ignored, all values go to Topic.
+type Kafka struct {
+	config    KafkaConfig
+	newReader func(KafkaConfig) kafkaReader
+
+	mu     sync.Mutex
+	writer *kafka.Writer
+	reader kafkaReader
+	// unacked is true while the messages returned by the last FetchSet are not committed.
+	unacked bool
+}
+
+// NewKafka returns a kafka analytics handler.
+func NewKafka(config KafkaConfig) *Kafka {
+	return &Kafka{config: config, newReader: newKafkaReader}
+}
+
+func newKafkaReader(config KafkaConfig) kafkaReader {
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers: config.Brokers,
+		Topic:   config.Topic,
+		GroupID: config.GroupID,
+	})
+}
+
+// Connect creates the kafka producer, the brokers are connected on the first write.
+func (k *Kafka) Connect() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.writer == nil {
+		k.writer = &kafka.Writer{
+			Addr:         kafka.TCP(k.config.Brokers...),
+			Topic:        k.config.Topic,
+			Balancer:     &kafka.LeastBytes{},
+			WriteTimeout: kafkaWriteTimeout,
+		}
+	}
+
+	return true
+}
+
+// Close closes the kafka producer and consumer.
+func (k *Kafka) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var errs []error
+	if k.writer != nil {
+		errs = append(errs, k.writer.Close())
+		k.writer = nil
+	}
+	if k.reader != nil {
+		errs = append(errs, k.reader.Close())
+		k.reader = nil
+	}
+
+	return errors.NewAggregate(errs)
+}
+
+// AppendToSetPipelined produces values to the topic.
+func (k *Kafka) AppendToSetPipelined(_ string, values [][]byte) {
+	if len(values) == 0 {
+		return
+	}
+
+	k.mu.Lock()
+	writer := k.writer
+	k.mu.Unlock()
+	if writer == nil {
+		log.Warn("Kafka producer is not connected, dropping analytics records")
+
+		return
+	}
+
+	msgs := make([]kafka.Message, len(values))
+	for i, value := range values {
+		msgs[i] = kafka.Message{Value: value}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaWriteTimeout)
+	defer cancel()
+
+	if err := writer.WriteMessages(ctx, msgs...); err != nil {
+		log.Errorf("Failed to produce analytics records to kafka: %s", err.Error())
+	}
+}
+
+// GetAndDeleteSet consumes the messages available in the topic and commits them.
+func (k *Kafka) GetAndDeleteSet(keyName string) []interface{} {
+	values, ack := k.FetchSet(keyName)
+	if err := ack(); err != nil {
+		log.Errorf("Failed to commit analytics records to kafka: %s", err.Error())
+	}
+
+	return values
+}
+
+// FetchSet consumes the messages available in the topic without committing them.
+// The returned ack function commits the messages. If the messages of the previous call are
+// not acked, the reader is recreated so that they are consumed again from the committed offsets,
+// instead of being skipped and committed by the next ack.
+func (k *Kafka) FetchSet(string) ([]interface{}, func() error) {
+	k.mu.Lock()
+	if k.reader != nil && k.unacked {
+		log.Info("Previous kafka analytics records are not committed, consuming them again")
+		if err := k.reader.Close(); err != nil {
+			log.Warnf("Failed to close kafka consumer: %s", err.Error())
+		}
+		k.reader = nil
+	}
+	if k.reader == nil {
+		k.reader = k.newReader(k.config)
+	}
+	reader := k.reader
+	k.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaFetchWait)
+	defer cancel()
+
+	var msgs []kafka.Message
+	for len(msgs) < kafkaMaxBatch {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if !errors.Is(err, context.DeadlineExceeded) {
+				log.Errorf("Failed to consume analytics records from kafka: %s", err.Error())
+			}
+
+			break
+		}
+		msgs = append(msgs, msg)
+	}
+
+	values := make([]interface{}, len(msgs))
+	for i, msg := range msgs {
+		values[i] = string(msg.Value)
+	}
+
+	k.mu.Lock()
+	k.unacked = len(msgs) > 0
+	k.mu.Unlock()
+
+	ack := func() error {
+		if len(msgs) == 0 {
+			return nil
+		}
+
+		if err := reader.CommitMessages(context.Background(), msgs...); err != nil {
+			return err
+		}
+
+		k.mu.Lock()
+		if k.reader == reader {
+			k.unacked = false
+		}
+		k.mu.Unlock()
+
+		return nil
+	}
+
+	return values, ack
+}
+
+// SetExp is not supported by kafka, retention is configured on the topic.
+func (k *Kafka) SetExp(string, time.Duration) error {
+	return nil
+}
+
+// GetExp is not supported by kafka.
+func (k *Kafka) GetExp(string) (int64, error) {
+	return 0, ErrKeyNotFound
+}
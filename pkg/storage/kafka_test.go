@@ -0,0 +1,89 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeTopic is a single partition topic with the committed offset of a consumer group.
+type fakeTopic struct {
+	mu        sync.Mutex
+	messages  []string
+	committed int
+}
+
+// fakeKafkaReader consumes fakeTopic from the committed offset, like a consumer group reader.
+type fakeKafkaReader struct {
+	topic  *fakeTopic
+	offset int
+}
+
+func (r *fakeKafkaReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.topic.mu.Lock()
+	defer r.topic.mu.Unlock()
+
+	if r.offset >= len(r.topic.messages) {
+		return kafka.Message{}, context.DeadlineExceeded
+	}
+
+	msg := kafka.Message{Offset: int64(r.offset), Value: []byte(r.topic.messages[r.offset])}
+	r.offset++
+
+	return msg, nil
+}
+
+func (r *fakeKafkaReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.topic.mu.Lock()
+	defer r.topic.mu.Unlock()
+
+	r.topic.committed = int(msgs[len(msgs)-1].Offset) + 1
+
+	return nil
+}
+
+func (r *fakeKafkaReader) Close() error {
+	return nil
+}
+
+func TestKafka_FetchSet_RedeliversUnacked(t *testing.T) {
+	topic := &fakeTopic{messages: []string{"a", "b"}}
+	k := NewKafka(KafkaConfig{})
+	k.newReader = func(KafkaConfig) kafkaReader {
+		topic.mu.Lock()
+		defer topic.mu.Unlock()
+
+		return &fakeKafkaReader{topic: topic, offset: topic.committed}
+	}
+
+	// the pumps fail to write the first batch, it is not acked.
+	if values, _ := k.FetchSet(""); !reflect.DeepEqual(values, []interface{}{"a", "b"}) {
+		t.Fatalf("FetchSet() = %v, want [a b]", values)
+	}
+
+	topic.mu.Lock()
+	topic.messages = append(topic.messages, "c")
+	topic.mu.Unlock()
+
+	values, ack := k.FetchSet("")
+	if want := []interface{}{"a", "b", "c"}; !reflect.DeepEqual(values, want) {
+		t.Fatalf("FetchSet() after failure = %v, want %v", values, want)
+	}
+	if err := ack(); err != nil {
+		t.Fatalf("ack() error = %v", err)
+	}
+
+	if values, _ := k.FetchSet(""); len(values) != 0 {
+		t.Errorf("FetchSet() after ack = %v, want empty", values)
+	}
+	if topic.committed != 3 {
+		t.Errorf("committed offset = %d, want 3", topic.committed)
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marmotedu/errors"
+
+	"github.com/marmotedu/iam/pkg/log"
+)
+
+const (
+	spoolActiveSuffix  = ".active"
+	spoolSegmentSuffix = ".seg"
+	// spoolCorruptSuffix is appended to a truncated segment once its readable values are
+	// acked, it is kept for inspection instead of being removed.
+	spoolCorruptSuffix = ".corrupt"
+
+	// defaultSpoolSegmentMaxAge is how long records stay in the active segment before they are
+	// visible to readers.
+	defaultSpoolSegmentMaxAge = time.Second
+	// defaultSpoolSegmentMaxSize is the size of the active segment at which it is sealed.
+	defaultSpoolSegmentMaxSize = 64 << 20
+)
+
+// Spool is an AnalyticsHandler which appends values to local files instead of redis.
+//
+// Values are appended to an active segment named <key>-<unixnano>.active, which is renamed
+// to <key>-<unixnano>.seg when it is older than a second or larger than 64MB. Only sealed
+// segments are read by GetAndDeleteSet or ReadSpool, so a directory must have a single writer.
+// Each value is stored with a 4 bytes big endian length prefix. A segment truncated by a crash
+// is renamed to <key>-<unixnano>.seg.corrupt instead of being deleted.
+type Spool struct {
+	Dir string
+
+	mu       sync.Mutex
+	segments map[string]*spoolSegment
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+type spoolSegment struct {
+	file     *os.File
+	writer   *bufio.Writer
+	size     int64
+	openedAt time.Time
+}
+
+// NewSpool returns a spool which stores values under dir.
+func NewSpool(dir string) *Spool {
+	return &Spool{
+		Dir:      dir,
+		segments: make(map[string]*spoolSegment),
+	}
+}
+
+// Connect creates the spool directory, seals the segments left by a previous writer and
+// starts sealing segments periodically.
+func (s *Spool) Connect() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopCh != nil {
+		return true
+	}
+
+	if err := os.MkdirAll(s.Dir, 0o750); err != nil {
+		log.Errorf("Failed to create spool directory %s: %s", s.Dir, err.Error())
+
+		return false
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(s.Dir, "*"+spoolActiveSuffix))
+	for _, name := range leftovers {
+		if err := os.Rename(name, strings.TrimSuffix(name, spoolActiveSuffix)+spoolSegmentSuffix); err != nil {
+			log.Warnf("Failed to seal spool segment %s: %s", name, err.Error())
+		}
+	}
+
+	s.stopCh = make(chan struct{})
+	s.wg.Add(1)
+	go s.sealLoop(s.stopCh)
+
+	return true
+}
+
+// Close seals all active segments.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	if s.stopCh != nil {
+		close(s.stopCh)
+		s.stopCh = nil
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for key := range s.segments {
+		if err := s.seal(key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.NewAggregate(errs)
+}
+
+// AppendToSetPipelined appends values to the active segment of key.
+func (s *Spool) AppendToSetPipelined(key string, values [][]byte) {
+	if len(values) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seg, err := s.active(key)
+	if err != nil {
+		log.Errorf("Failed to open spool segment: %s", err.Error())
+
+		return
+	}
+
+	var header [4]byte
+	for _, value := range values {
+		binary.BigEndian.PutUint32(header[:], uint32(len(value)))
+		if _, err = seg.writer.Write(header[:]); err == nil {
+			_, err = seg.writer.Write(value)
+		}
+		if err != nil {
+			log.Errorf("Failed to write spool segment %s: %s", seg.file.Name(), err.Error())
+
+			return
+		}
+		seg.size += int64(len(header) + len(value))
+	}
+
+	if err := seg.writer.Flush(); err != nil {
+		log.Errorf("Failed to write spool segment %s: %s", seg.file.Name(), err.Error())
+	}
+
+	if seg.size >= defaultSpoolSegmentMaxSize {
+		if err := s.seal(key); err != nil {
+			log.Errorf("Failed to seal spool segment: %s", err.Error())
+		}
+	}
+}
+
+// GetAndDeleteSet returns the values of the sealed segments of key and deletes them.
+func (s *Spool) GetAndDeleteSet(key string) []interface{} {
+	values, err := ReadSpool(s.Dir, key)
+	if err != nil {
+		log.Errorf("Failed to read spool: %s", err.Error())
+	}
+
+	return values
+}
+
+// SetExp is not supported by the spool, records are deleted once they are read.
+func (s *Spool) SetExp(string, time.Duration) error {
+	return nil
+}
+
+// GetExp is not supported by the spool.
+func (s *Spool) GetExp(string) (int64, error) {
+	return 0, ErrKeyNotFound
+}
+
+func (s *Spool) active(key string) (*spoolSegment, error) {
+	if seg, ok := s.segments[key]; ok {
+		return seg, nil
+	}
+
+	now := time.Now()
+	name := filepath.Join(s.Dir, fmt.Sprintf("%s-%020d%s", key, now.UnixNano(), spoolActiveSuffix))
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := &spoolSegment{file: file, writer: bufio.NewWriter(file), openedAt: now}
+	s.segments[key] = seg
+
+	return seg, nil
+}
+
+// seal closes the active segment of key and makes it visible to readers.
+func (s *Spool) seal(key string) error {
+	seg, ok := s.segments[key]
+	if !ok {
+		return nil
+	}
+	delete(s.segments, key)
+
+	name := seg.file.Name()
+	if err := seg.writer.Flush(); err != nil {
+		_ = seg.file.Close()
+
+		return errors.Wrapf(err, "flush spool segment %s failed", name)
+	}
+
+	if err := seg.file.Close(); err != nil {
+		return errors.Wrapf(err, "close spool segment %s failed", name)
+	}
+
+	return os.Rename(name, strings.TrimSuffix(name, spoolActiveSuffix)+spoolSegmentSuffix)
+}
+
+func (s *Spool) sealLoop(stopCh <-chan struct{}) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(defaultSpoolSegmentMaxAge / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			for key, seg := range s.segments {
+				if time.Since(seg.openedAt) < defaultSpoolSegmentMaxAge {
+					continue
+				}
+				if err := s.seal(key); err != nil {
+					log.Errorf("Failed to seal spool segment: %s", err.Error())
+				}
+			}
+			s.mu.Unlock()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// ReadSpool returns the values of the sealed segments of key under dir, oldest first,
+// and deletes the segments. The values are strings to match the values read from redis.
+func ReadSpool(dir, key string) ([]interface{}, error) {
+	values, ack, err := PeekSpool(dir, key)
+	if err != nil {
+		return values, err
+	}
+
+	return values, ack()
+}
+
+// PeekSpool returns the values of the sealed segments of key under dir, oldest first,
+// without deleting them. The returned ack function deletes the segments the values were read from.
+func PeekSpool(dir, key string) ([]interface{}, func() error, error) {
+	names, err := filepath.Glob(filepath.Join(dir, key+"-*"+spoolSegmentSuffix))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(names)
+
+	var values []interface{}
+	read := make([]string, 0, len(names))
+	truncated := map[string]bool{}
+	for _, name := range names {
+		segValues, isTruncated, readErr := readSpoolSegment(name)
+		if readErr != nil {
+			err = errors.Wrapf(readErr, "read spool segment %s failed", name)
+
+			break
+		}
+		values = append(values, segValues...)
+		read = append(read, name)
+		truncated[name] = isTruncated
+	}
+
+	ack := func() error {
+		var errs []error
+		for _, name := range read {
+			if truncated[name] {
+				if err := os.Rename(name, name+spoolCorruptSuffix); err != nil {
+					errs = append(errs, err)
+				} else {
+					log.Warnf("Truncated spool segment is kept as %s", name+spoolCorruptSuffix)
+				}
+
+				continue
+			}
+
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+
+		return errors.NewAggregate(errs)
+	}
+
+	return values, ack, err
+}
+
+// readSpoolSegment returns the values of the segment. Segments are only appended to, so a crashed
+// writer leaves at most one partially written value at the end, which is dropped and reported
+// with truncated.
+func readSpoolSegment(name string) (values []interface{}, truncated bool, err error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var header [4]byte
+	var offset int
+	for {
+		n, err := io.ReadFull(reader, header[:])
+		if errors.Is(err, io.EOF) {
+			return values, false, nil
+		}
+		if err == nil {
+			value := make([]byte, binary.BigEndian.Uint32(header[:]))
+			var m int
+			m, err = io.ReadFull(reader, value)
+			n += m
+			if err == nil {
+				values = append(values, string(value))
+				offset += n
+
+				continue
+			}
+		}
+
+		log.Warnf("Spool segment %s is truncated at offset %d after %d values, "+
+			"dropped 1 partially written value of %d bytes", name, offset, len(values), n)
+
+		return values, true, nil
+	}
+}
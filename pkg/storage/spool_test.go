@@ -0,0 +1,73 @@
+// Copyright 2020 Lingfei Kong <colin404@foxmail.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSpool(dir)
+	if !s.Connect() {
+		t.Fatal("Connect() = false")
+	}
+
+	s.AppendToSetPipelined("analytics", [][]byte{[]byte("a"), []byte("b\nc")})
+
+	// the active segment is not visible to readers.
+	if got := s.GetAndDeleteSet("analytics"); len(got) != 0 {
+		t.Fatalf("GetAndDeleteSet() before seal = %v, want empty", got)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	s.AppendToSetPipelined("other", nil)
+
+	want := []interface{}{"a", "b\nc"}
+	if got, err := ReadSpool(dir, "analytics"); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadSpool() = %v, %v, want %v", got, err, want)
+	}
+
+	if got, _ := ReadSpool(dir, "analytics"); len(got) != 0 {
+		t.Errorf("ReadSpool() after delete = %v, want empty", got)
+	}
+}
+
+func TestSpool_Connect_SealsLeftovers(t *testing.T) {
+	dir := t.TempDir()
+
+	// an active segment left by a crashed writer, with a truncated last record.
+	leftover := []byte{0, 0, 0, 1, 'a', 0, 0, 0, 5, 'b'}
+	if err := os.WriteFile(filepath.Join(dir, "analytics-1"+spoolActiveSuffix), leftover, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSpool(dir)
+	s.Connect()
+	defer s.Close()
+
+	want := []interface{}{"a"}
+	if got := s.GetAndDeleteSet("analytics"); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAndDeleteSet() = %v, want %v", got, want)
+	}
+
+	// the truncated segment is kept aside for inspection.
+	corrupt, _ := filepath.Glob(filepath.Join(dir, "analytics-*"+spoolSegmentSuffix+spoolCorruptSuffix))
+	if len(corrupt) != 1 {
+		t.Fatalf("corrupt segments = %v, want 1", corrupt)
+	}
+	if content, _ := os.ReadFile(corrupt[0]); !reflect.DeepEqual(content, leftover) {
+		t.Errorf("corrupt segment = %v, want %v", content, leftover)
+	}
+	if got := s.GetAndDeleteSet("analytics"); len(got) != 0 {
+		t.Errorf("GetAndDeleteSet() after delete = %v, want empty", got)
+	}
+}